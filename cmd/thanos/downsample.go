@@ -243,6 +243,10 @@ func processDownsampling(ctx context.Context, logger log.Logger, bkt objstore.Bu
 		return errors.Wrap(err, "output block index not valid")
 	}
 
+	if err := block.CreateIndexHeader(logger, resdir); err != nil {
+		return errors.Wrapf(err, "create index header of downsampled block %s", id)
+	}
+
 	begin = time.Now()
 
 	err = block.Upload(ctx, logger, bkt, resdir)
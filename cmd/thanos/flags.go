@@ -119,6 +119,30 @@ func regHTTPAddrFlag(cmd *kingpin.CmdClause) *string {
 	return cmd.Flag("http-address", "Listen host:port for HTTP endpoints.").Default("0.0.0.0:10902").String()
 }
 
+// secretFlags are flags whose values must not be exposed.
+var secretFlags = map[string]struct{}{
+	"cluster.secret-key": {},
+}
+
+// flagsMap returns the current values of the application and command flags by flag name. Values of secret flags are
+// redacted and kingpin's own flags, like help, are omitted.
+func flagsMap(app *kingpin.Application, cmd *kingpin.CmdClause) map[string]string {
+	boilerplate := kingpin.New("", "").Version("")
+
+	m := map[string]string{}
+	for _, f := range append(app.Model().Flags, cmd.Model().Flags...) {
+		if boilerplate.GetFlag(f.Name) != nil {
+			continue
+		}
+		v := f.Value.String()
+		if _, ok := secretFlags[f.Name]; ok && v != "" {
+			v = "<secret>"
+		}
+		m[f.Name] = v
+	}
+	return m
+}
+
 func modelDuration(flags *kingpin.FlagClause) *model.Duration {
 	var value = new(model.Duration)
 	flags.SetValue(value)
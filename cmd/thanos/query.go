@@ -15,6 +15,7 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
+	resultcache "github.com/improbable-eng/thanos/pkg/cache"
 	"github.com/improbable-eng/thanos/pkg/cluster"
 	"github.com/improbable-eng/thanos/pkg/discovery/cache"
 	"github.com/improbable-eng/thanos/pkg/query"
@@ -22,6 +23,7 @@ import (
 	"github.com/improbable-eng/thanos/pkg/runutil"
 	"github.com/improbable-eng/thanos/pkg/store"
 	"github.com/improbable-eng/thanos/pkg/store/storepb"
+	"github.com/improbable-eng/thanos/pkg/tenancy"
 	"github.com/improbable-eng/thanos/pkg/tracing"
 	"github.com/improbable-eng/thanos/pkg/ui"
 	"github.com/oklog/run"
@@ -59,8 +61,11 @@ func registerQuery(m map[string]setupFunc, app *kingpin.Application, name string
 	maxConcurrentQueries := cmd.Flag("query.max-concurrent", "Maximum number of queries processed concurrently by query node.").
 		Default("20").Int()
 
-	replicaLabel := cmd.Flag("query.replica-label", "Label to treat as a replica indicator along which data is deduplicated. Still you will be able to query without deduplication using 'dedup=false' parameter.").
-		String()
+	replicaLabels := cmd.Flag("query.replica-label", "Labels to treat as a replica indicator along which data is deduplicated (repeatable). Still you will be able to query without deduplication using 'dedup=false' parameter.").
+		Strings()
+
+	dedupCounters := cmd.Flag("query.dedup-counters", "Adjust deduplicated counters when switching between replicas, so that they stay monotonic and replicas restarted at different times do not appear as counter resets. Series are treated as counters if their metric name ends with _total or they are selected by rate, irate, increase or resets.").
+		Default("false").Bool()
 
 	selectorLabels := cmd.Flag("selector-label", "Query selector labels that will be exposed in info endpoint (repeated).").
 		PlaceHolder("<name>=\"<value>\"").Strings()
@@ -74,9 +79,39 @@ func registerQuery(m map[string]setupFunc, app *kingpin.Application, name string
 	fileSDInterval := modelDuration(cmd.Flag("store.sd-interval", "Refresh interval to re-read file SD files. It is used as a resync fallback.").
 		Default("5m"))
 
+	storeFirstByteTimeout := modelDuration(cmd.Flag("store.first-byte-timeout", "Maximum time to wait for the first series response of a store API. Store that exceeds it is dropped from the query with a warning. 0 disables the timeout.").
+		Default("0s"))
+
+	storeResponseTimeout := modelDuration(cmd.Flag("store.response-timeout", "Maximum time to wait for the whole series response of a store API, not counting time the store is held back by slower stores. Store that exceeds it is dropped from the query with a warning. 0 disables the timeout.").
+		Default("0s"))
+
 	enableAutodownsampling := cmd.Flag("query.auto-downsampling", "Enable automatic adjustment (step / 5) to what source of data should be used in store gateways if no max_source_resolution param is specified. ").
 		Default("false").Bool()
 
+	enablePartialResponse := cmd.Flag("query.partial-response", "Enable partial response for queries if no partial_response param is specified. If disabled, a query fails as soon as any of the queried store APIs fails.").
+		Default("true").Bool()
+
+	rangeCacheSize := cmd.Flag("query.range-cache.max-size", "Maximum size of range query results held in the in-memory results cache. 0 disables the in-memory cache.").
+		Default("0").Bytes()
+
+	rangeCacheMemcachedAddrs := cmd.Flag("query.range-cache.memcached-address", "Address of memcached server to hold range query results cache instead of the in-memory one (repeatable).").
+		PlaceHolder("<host:port>").Strings()
+
+	rangeCacheMemcachedTimeout := modelDuration(cmd.Flag("query.range-cache.memcached-timeout", "Maximum time of a single operation against memcached servers of range query results cache.").
+		Default("100ms"))
+
+	rangeCacheMaxFreshness := modelDuration(cmd.Flag("query.range-cache.max-freshness", "Results of range queries more recent than this are never cached, as they can still change.").
+		Default("10m"))
+
+	querySplitInterval := modelDuration(cmd.Flag("query.split-interval", "Split range queries into sub-queries aligned to this interval, evaluated concurrently within the limit of --query.split-max-concurrent. 0 disables splitting.").
+		Default("0s"))
+
+	querySplitConcurrency := cmd.Flag("query.split-max-concurrent", "Maximum number of sub-queries of a single split range query evaluated concurrently. It must be lower than --query.max-concurrent, so that a single range query cannot take all query slots.").
+		Default("4").Int()
+
+	tenantHeader := cmd.Flag("query.tenant-header", "HTTP header to determine tenant of query API requests. The tenant is passed to store APIs, which can restrict served data to it.").
+		Default(tenancy.DefaultTenantHeader).String()
+
 	m[name] = func(g *run.Group, logger log.Logger, reg *prometheus.Registry, tracer opentracing.Tracer, _ bool) error {
 		peer, err := newPeerFn(logger, reg, true, *httpAdvertiseAddr, true)
 		if err != nil {
@@ -122,11 +157,23 @@ func registerQuery(m map[string]setupFunc, app *kingpin.Application, name string
 			*httpBindAddr,
 			*maxConcurrentQueries,
 			time.Duration(*queryTimeout),
-			*replicaLabel,
+			*replicaLabels,
+			*dedupCounters,
 			peer,
 			selectorLset,
 			*stores,
 			*enableAutodownsampling,
+			*enablePartialResponse,
+			time.Duration(*storeFirstByteTimeout),
+			time.Duration(*storeResponseTimeout),
+			uint64(*rangeCacheSize),
+			*rangeCacheMemcachedAddrs,
+			time.Duration(*rangeCacheMemcachedTimeout),
+			time.Duration(*rangeCacheMaxFreshness),
+			time.Duration(*querySplitInterval),
+			*querySplitConcurrency,
+			*tenantHeader,
+			flagsMap(app, cmd),
 			fileSD,
 		)
 	}
@@ -232,13 +279,29 @@ func runQuery(
 	httpBindAddr string,
 	maxConcurrentQueries int,
 	queryTimeout time.Duration,
-	replicaLabel string,
+	replicaLabels []string,
+	dedupCounters bool,
 	peer *cluster.Peer,
 	selectorLset labels.Labels,
 	storeAddrs []string,
 	enableAutodownsampling bool,
+	enablePartialResponse bool,
+	storeFirstByteTimeout time.Duration,
+	storeResponseTimeout time.Duration,
+	rangeCacheSizeBytes uint64,
+	rangeCacheMemcachedAddrs []string,
+	rangeCacheMemcachedTimeout time.Duration,
+	rangeCacheMaxFreshness time.Duration,
+	querySplitInterval time.Duration,
+	querySplitConcurrency int,
+	tenantHeader string,
+	flagsMap map[string]string,
 	fileSD *file.Discovery,
 ) error {
+	if querySplitInterval > 0 && (querySplitConcurrency < 1 || querySplitConcurrency >= maxConcurrentQueries) {
+		return errors.Errorf("--query.split-max-concurrent must be between 1 and --query.max-concurrent (%d) exclusive, got %d", maxConcurrentQueries, querySplitConcurrency)
+	}
+
 	duplicatedStores := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "thanos_query_duplicated_store_address",
 		Help: "The number of times a duplicated store addresses is detected from the different configs in query",
@@ -290,10 +353,10 @@ func runQuery(
 			},
 			dialOpts,
 		)
-		proxy = store.NewProxyStore(logger, func(context.Context) ([]store.Client, error) {
+		proxy = store.NewProxyStore(logger, reg, func(context.Context) ([]store.Client, error) {
 			return stores.Get(), nil
-		}, selectorLset)
-		queryableCreator = query.NewQueryableCreator(logger, proxy, replicaLabel)
+		}, selectorLset, storeFirstByteTimeout, storeResponseTimeout)
+		queryableCreator = query.NewQueryableCreator(logger, proxy, replicaLabels, dedupCounters)
 		engine           = promql.NewEngine(logger, reg, maxConcurrentQueries, queryTimeout)
 	)
 	// Periodically update the store set with the addresses we see in our cluster.
@@ -361,9 +424,27 @@ func runQuery(
 	// Start query API + UI HTTP server.
 	{
 		router := route.New()
-		ui.NewQueryUI(logger, nil).Register(router)
+		ui.NewQueryUI(logger, flagsMap).Register(router)
+
+		var rangeCache resultcache.Cache
+		if len(rangeCacheMemcachedAddrs) > 0 {
+			c, err := resultcache.NewMemcachedCache(logger, reg, "range-query", resultcache.MemcachedConfig{
+				Addresses: rangeCacheMemcachedAddrs,
+				Timeout:   rangeCacheMemcachedTimeout,
+			})
+			if err != nil {
+				return errors.Wrap(err, "create memcached range query results cache")
+			}
+			rangeCache = c
+		} else if rangeCacheSizeBytes > 0 {
+			c, err := resultcache.NewInMemoryCache(reg, "range-query", rangeCacheSizeBytes)
+			if err != nil {
+				return errors.Wrap(err, "create in-memory range query results cache")
+			}
+			rangeCache = c
+		}
 
-		api := v1.NewAPI(logger, reg, engine, queryableCreator, enableAutodownsampling)
+		api := v1.NewAPI(logger, reg, engine, queryableCreator, enableAutodownsampling, enablePartialResponse, rangeCache, rangeCacheMaxFreshness, querySplitInterval, querySplitConcurrency, tenantHeader, stores.GetStoreStatus, flagsMap)
 		api.Register(router.WithPrefix("/api/v1"), tracer, logger)
 
 		router.Get("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
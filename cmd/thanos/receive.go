@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/improbable-eng/thanos/pkg/block"
+	"github.com/improbable-eng/thanos/pkg/cluster"
+	"github.com/improbable-eng/thanos/pkg/objstore/client"
+	"github.com/improbable-eng/thanos/pkg/receive"
+	"github.com/improbable-eng/thanos/pkg/runutil"
+	"github.com/improbable-eng/thanos/pkg/shipper"
+	"github.com/improbable-eng/thanos/pkg/store"
+	"github.com/improbable-eng/thanos/pkg/store/storepb"
+	"github.com/improbable-eng/thanos/pkg/tenancy"
+	"github.com/oklog/run"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/route"
+	"github.com/prometheus/prometheus/storage/tsdb"
+	"github.com/prometheus/tsdb/labels"
+	"google.golang.org/grpc"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// registerReceive registers a receive command.
+func registerReceive(m map[string]setupFunc, app *kingpin.Application, name string) {
+	cmd := app.Command(name, "receive Prometheus remote write requests, exposing Store API and storing blocks in bucket")
+
+	grpcBindAddr, httpBindAddr, cert, key, clientCA, newPeerFn := regCommonServerFlags(cmd)
+
+	remoteWriteAddress := cmd.Flag("remote-write.address", "Address to listen on for remote write requests.").
+		Default("0.0.0.0:19291").String()
+
+	dataDir := cmd.Flag("tsdb.path", "Data directory of TSDB.").
+		Default("./data").String()
+
+	labelStrs := cmd.Flag("label", "External labels to announce. This flag will be removed in the future when handling multiple tsdb instances is added (repeated).").
+		PlaceHolder("<name>=\"<value>\"").Strings()
+
+	objStoreConfig := regCommonObjStoreFlags(cmd, "")
+
+	tsdbBlockDuration := modelDuration(cmd.Flag("tsdb.block-duration", "Block duration for TSDB block.").
+		Default("2h"))
+	tsdbRetention := modelDuration(cmd.Flag("tsdb.retention", "How long to retain raw samples on local storage. 0d - disables this retention").
+		Default("15d"))
+
+	hashringsFile := cmd.Flag("receive.hashrings-file", "Path to file that contains the hashring configuration. Without it all received series are written locally.").
+		PlaceHolder("<path>").String()
+
+	refreshInterval := modelDuration(cmd.Flag("receive.hashrings-file-refresh-interval", "Refresh interval to re-read the hashring configuration file.").
+		Default("5m"))
+
+	localEndpoint := cmd.Flag("receive.local-endpoint", "Endpoint of the local receive node. Used to identify the local node in the hashring configuration.").
+		String()
+
+	tenantHeader := cmd.Flag("receive.tenant-header", "HTTP header to determine tenant for write requests.").
+		Default(tenancy.DefaultTenantHeader).String()
+
+	replicationFactor := cmd.Flag("receive.replication-factor", "How many times to replicate incoming write requests.").
+		Default("1").Uint64()
+
+	m[name] = func(g *run.Group, logger log.Logger, reg *prometheus.Registry, tracer opentracing.Tracer, _ bool) error {
+		lset, err := parseFlagLabels(*labelStrs)
+		if err != nil {
+			return errors.Wrap(err, "parse labels")
+		}
+		peer, err := newPeerFn(logger, reg, false, "", false)
+		if err != nil {
+			return errors.Wrap(err, "new cluster peer")
+		}
+
+		tsdbOpts := &tsdb.Options{
+			MinBlockDuration: *tsdbBlockDuration,
+			MaxBlockDuration: *tsdbBlockDuration,
+			Retention:        *tsdbRetention,
+			NoLockfile:       true,
+			WALFlushInterval: 30 * time.Second,
+		}
+
+		return runReceive(
+			g,
+			logger,
+			reg,
+			tracer,
+			*grpcBindAddr,
+			*cert,
+			*key,
+			*clientCA,
+			*httpBindAddr,
+			*remoteWriteAddress,
+			*dataDir,
+			objStoreConfig,
+			lset,
+			peer,
+			tsdbOpts,
+			name,
+			*hashringsFile,
+			time.Duration(*refreshInterval),
+			*localEndpoint,
+			*tenantHeader,
+			*replicationFactor,
+		)
+	}
+}
+
+// runReceive runs a receive component that accepts Prometheus remote write requests and appends the samples
+// into a local TSDB. The data is exposed over the Store API and completed blocks are shipped to the bucket.
+func runReceive(
+	g *run.Group,
+	logger log.Logger,
+	reg *prometheus.Registry,
+	tracer opentracing.Tracer,
+	grpcBindAddr string,
+	cert string,
+	key string,
+	clientCA string,
+	httpBindAddr string,
+	remoteWriteAddress string,
+	dataDir string,
+	objStoreConfig *pathOrContent,
+	lset labels.Labels,
+	peer *cluster.Peer,
+	tsdbOpts *tsdb.Options,
+	component string,
+	hashringsFile string,
+	refreshInterval time.Duration,
+	endpoint string,
+	tenantHeader string,
+	replicationFactor uint64,
+) error {
+	logger = log.With(logger, "component", "receive")
+	level.Warn(logger).Log("msg", "setting up receive; the Thanos receive component is EXPERIMENTAL, it may break significantly without notice")
+
+	db, err := tsdb.Open(dataDir, log.With(logger, "component", "tsdb"), reg, tsdbOpts)
+	if err != nil {
+		return errors.Wrap(err, "open TSDB")
+	}
+	{
+		done := make(chan struct{})
+		g.Add(func() error {
+			<-done
+			return db.Close()
+		}, func(error) {
+			close(done)
+		})
+	}
+
+	if err := metricHTTPListenGroup(g, logger, reg, httpBindAddr); err != nil {
+		return err
+	}
+
+	{
+		var storeLset []storepb.Label
+		for _, l := range lset {
+			storeLset = append(storeLset, storepb.Label{Name: l.Name, Value: l.Value})
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			// New gossip cluster.
+			if err = peer.Join(cluster.PeerTypeSource, cluster.PeerMetadata{
+				Labels: storeLset,
+				// Start out with the full time range. The shipper will constrain it later.
+				MinTime: 0,
+				MaxTime: math.MaxInt64,
+			}); err != nil {
+				return errors.Wrap(err, "join cluster")
+			}
+
+			<-ctx.Done()
+			return nil
+		}, func(error) {
+			cancel()
+			peer.Close(5 * time.Second)
+		})
+	}
+	// Start gRPC server.
+	{
+		l, err := net.Listen("tcp", grpcBindAddr)
+		if err != nil {
+			return errors.Wrap(err, "listen API address")
+		}
+		logger := log.With(logger, "component", "store")
+
+		store := store.NewTSDBStore(logger, reg, db, lset)
+
+		opts, err := defaultGRPCServerOpts(logger, reg, tracer, cert, key, clientCA)
+		if err != nil {
+			return errors.Wrap(err, "setup gRPC options")
+		}
+		s := grpc.NewServer(opts...)
+		storepb.RegisterStoreServer(s, store)
+
+		g.Add(func() error {
+			return errors.Wrap(s.Serve(l), "serve gRPC")
+		}, func(error) {
+			s.Stop()
+			runutil.CloseWithLogOnErr(logger, l, "store gRPC listener")
+		})
+	}
+	handler := receive.NewHandler(
+		logger,
+		reg,
+		receive.NewWriter(log.With(logger, "component", "writer"), db),
+		endpoint,
+		tenantHeader,
+		replicationFactor,
+	)
+	// Periodically reload the hashring configuration.
+	if hashringsFile != "" {
+		if endpoint == "" {
+			return errors.New("--receive.local-endpoint must be set if hashring configuration is given")
+		}
+		configSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "thanos_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful.",
+		})
+		configSuccessTime := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "thanos_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload.",
+		})
+		reg.MustRegister(configSuccess)
+		reg.MustRegister(configSuccessTime)
+
+		var last []byte
+		reloadHashring := func() error {
+			b, err := ioutil.ReadFile(hashringsFile)
+			if err != nil {
+				return errors.Wrapf(err, "read hashring configuration file %s", hashringsFile)
+			}
+			if bytes.Equal(b, last) {
+				return nil
+			}
+			cfgs, err := receive.ParseHashringConfig(b)
+			if err != nil {
+				return err
+			}
+			hashring, err := receive.NewHashring(cfgs)
+			if err != nil {
+				return errors.Wrap(err, "create hashring")
+			}
+			handler.SetHashring(hashring)
+			last = b
+
+			level.Info(logger).Log("msg", "hashring configuration reloaded", "file", hashringsFile, "hashrings", len(cfgs))
+			return nil
+		}
+		// Refuse to start with an invalid configuration rather than writing all series locally.
+		if err := reloadHashring(); err != nil {
+			return err
+		}
+		configSuccess.Set(1)
+		configSuccessTime.Set(float64(time.Now().UnixNano()) / 1e9)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return runutil.Repeat(refreshInterval, ctx.Done(), func() error {
+				if err := reloadHashring(); err != nil {
+					configSuccess.Set(0)
+					level.Error(logger).Log("msg", "reloading hashring configuration failed", "err", err)
+					return nil
+				}
+				configSuccess.Set(1)
+				configSuccessTime.Set(float64(time.Now().UnixNano()) / 1e9)
+				return nil
+			})
+		}, func(error) {
+			cancel()
+		})
+	}
+	// Start remote write HTTP server.
+	{
+		router := route.New()
+		handler.Register(router, tracer)
+
+		l, err := net.Listen("tcp", remoteWriteAddress)
+		if err != nil {
+			return errors.Wrapf(err, "listen remote write on address %s", remoteWriteAddress)
+		}
+
+		g.Add(func() error {
+			level.Info(logger).Log("msg", "Listening for remote write requests", "address", remoteWriteAddress)
+			return errors.Wrap(http.Serve(l, router), "serve remote write")
+		}, func(error) {
+			runutil.CloseWithLogOnErr(logger, l, "remote write listener")
+		})
+	}
+
+	var uploads = true
+
+	bucketConfig, err := objStoreConfig.Content()
+	if err != nil {
+		return err
+	}
+	// The background shipper continuously scans the data directory and uploads
+	// new blocks to Google Cloud Storage or an S3-compatible storage service.
+	bkt, err := client.NewBucket(logger, bucketConfig, reg, component)
+	if err != nil && err != client.ErrNotFound {
+		return err
+	}
+
+	if err == client.ErrNotFound {
+		level.Info(logger).Log("msg", "No supported bucket was configured, uploads will be disabled")
+		uploads = false
+	}
+
+	if uploads {
+		// Ensure we close up everything properly.
+		defer func() {
+			if err != nil {
+				runutil.CloseWithLogOnErr(logger, bkt, "bucket client")
+			}
+		}()
+
+		s := shipper.New(logger, nil, dataDir, bkt, func() labels.Labels { return lset }, block.ReceiveSource)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		g.Add(func() error {
+			defer runutil.CloseWithLogOnErr(logger, bkt, "bucket client")
+
+			return runutil.Repeat(30*time.Second, ctx.Done(), func() error {
+				s.Sync(ctx)
+
+				minTime, _, err := s.Timestamps()
+				if err != nil {
+					level.Warn(logger).Log("msg", "reading timestamps failed", "err", err)
+				} else {
+					peer.SetTimestamps(minTime, math.MaxInt64)
+				}
+				return nil
+			})
+		}, func(error) {
+			cancel()
+		})
+	}
+
+	level.Info(logger).Log("msg", "starting receiver", "peer", peer.Name())
+	return nil
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/improbable-eng/thanos/pkg/shipper"
 	"github.com/improbable-eng/thanos/pkg/store"
 	"github.com/improbable-eng/thanos/pkg/store/storepb"
+	"github.com/improbable-eng/thanos/pkg/tenancy"
 	"github.com/improbable-eng/thanos/pkg/tracing"
 	"github.com/improbable-eng/thanos/pkg/ui"
 	"github.com/oklog/run"
@@ -87,6 +88,12 @@ func registerRule(m map[string]setupFunc, app *kingpin.Application, name string)
 	fileSDInterval := modelDuration(cmd.Flag("query.sd-interval", "Refresh interval to re-read file SD files. (used as a fallback)").
 		Default("5m"))
 
+	queryTenant := cmd.Flag("query.tenant", "Tenant to evaluate rules as. It is sent to query nodes in the tenant header, so only data of the tenant is queried.").
+		String()
+
+	queryTenantHeader := cmd.Flag("query.tenant-header", "HTTP header to pass the tenant in to query nodes.").
+		Default(tenancy.DefaultTenantHeader).String()
+
 	m[name] = func(g *run.Group, logger log.Logger, reg *prometheus.Registry, tracer opentracing.Tracer, _ bool) error {
 		lset, err := parseFlagLabels(*labelStrs)
 		if err != nil {
@@ -148,6 +155,8 @@ func registerRule(m map[string]setupFunc, app *kingpin.Application, name string)
 			alertQueryURL,
 			*queries,
 			fileSD,
+			*queryTenant,
+			*queryTenantHeader,
 		)
 	}
 }
@@ -176,6 +185,8 @@ func runRule(
 	alertQueryURL *url.URL,
 	queryAddrs []string,
 	fileSD *file.Discovery,
+	queryTenant string,
+	queryTenantHeader string,
 ) error {
 	configSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "thanos_config_last_reload_successful",
@@ -244,7 +255,7 @@ func runRule(
 		removeDuplicateQueryAddrs(logger, duplicatedQuery, addrs)
 
 		for _, i := range rand.Perm(len(addrs)) {
-			vec, err := queryPrometheusInstant(ctx, logger, addrs[i], q, t)
+			vec, err := queryPrometheusInstant(ctx, logger, addrs[i], q, t, queryTenant, queryTenantHeader)
 			if err != nil {
 				return nil, err
 			}
@@ -564,7 +575,7 @@ func runRule(
 	return nil
 }
 
-func queryPrometheusInstant(ctx context.Context, logger log.Logger, addr, query string, t time.Time) (promql.Vector, error) {
+func queryPrometheusInstant(ctx context.Context, logger log.Logger, addr, query string, t time.Time, tenant, tenantHeader string) (promql.Vector, error) {
 	u, err := url.Parse(fmt.Sprintf("http://%s/api/v1/query", addr))
 	if err != nil {
 		return nil, err
@@ -579,6 +590,9 @@ func queryPrometheusInstant(ctx context.Context, logger log.Logger, addr, query
 	if err != nil {
 		return nil, err
 	}
+	if tenant != "" {
+		req.Header.Set(tenantHeader, tenant)
+	}
 
 	span, ctx := tracing.StartSpan(ctx, "/rule_instant_query HTTP[client]")
 	defer span.Finish()
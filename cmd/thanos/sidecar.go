@@ -50,6 +50,9 @@ func registerSidecar(m map[string]setupFunc, app *kingpin.Application, name stri
 
 	reloaderRuleDirs := cmd.Flag("reloader.rule-dir", "Rule directories for the reloader to refresh (repeated field).").Strings()
 
+	tenantLabel := cmd.Flag("tenant-label", "External label identifying the tenant of the served data. If set, only StoreAPI requests of the tenant given by this label are served and requests without tenant are rejected.").
+		PlaceHolder("<name>").String()
+
 	objStoreConfig := regCommonObjStoreFlags(cmd, "")
 
 	m[name] = func(g *run.Group, logger log.Logger, reg *prometheus.Registry, tracer opentracing.Tracer, _ bool) error {
@@ -80,6 +83,7 @@ func registerSidecar(m map[string]setupFunc, app *kingpin.Application, name stri
 			peer,
 			rl,
 			name,
+			*tenantLabel,
 		)
 	}
 }
@@ -100,6 +104,7 @@ func runSidecar(
 	peer *cluster.Peer,
 	reloader *reloader.Reloader,
 	component string,
+	tenantLabel string,
 ) error {
 	var metadata = &metadata{
 		promURL: promURL,
@@ -203,7 +208,7 @@ func runSidecar(
 		var client http.Client
 
 		promStore, err := store.NewPrometheusStore(
-			logger, &client, promURL, metadata.Labels, metadata.Timestamps)
+			logger, &client, promURL, metadata.Labels, metadata.Timestamps, tenantLabel)
 		if err != nil {
 			return errors.Wrap(err, "create Prometheus store")
 		}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"io"
 	"math"
 	"net"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/improbable-eng/thanos/pkg/cache"
 	"github.com/improbable-eng/thanos/pkg/cluster"
+	"github.com/improbable-eng/thanos/pkg/model"
 	"github.com/improbable-eng/thanos/pkg/objstore/client"
 	"github.com/improbable-eng/thanos/pkg/runutil"
 	"github.com/improbable-eng/thanos/pkg/store"
@@ -21,6 +24,9 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
+// chunkCacheQueueSize is the maximum number of chunk ranges waiting to be stored in memcached. Further ones are dropped.
+const chunkCacheQueueSize = 100
+
 // registerStore registers a store command.
 func registerStore(m map[string]setupFunc, app *kingpin.Application, name string) {
 	cmd := app.Command(name, "store node giving access to blocks in a bucket provider. Now supported GCS, S3 and Azure.")
@@ -33,9 +39,45 @@ func registerStore(m map[string]setupFunc, app *kingpin.Application, name string
 	indexCacheSize := cmd.Flag("index-cache-size", "Maximum size of items held in the index cache.").
 		Default("250MB").Bytes()
 
+	indexCacheDiskSize := cmd.Flag("index-cache-disk-size", "Maximum size of items held in the on-disk tier of the index cache under the data directory. Items evicted from memory are spilled to it and it is reloaded on startup. 0 disables the on-disk tier.").
+		Default("0").Bytes()
+
+	indexCacheMemcachedAddrs := cmd.Flag("index-cache-memcached-address", "Address of memcached server to hold the index cache instead of the in-memory one, so it is shared by all store replicas using it (repeatable).").
+		PlaceHolder("<host:port>").Strings()
+
+	indexHeaderIdleTimeout := cmd.Flag("index-header-idle-timeout", "Duration after which index headers of blocks that were not queried are unloaded from memory. Index headers are loaded on the first query of a block. 0 disables unloading.").
+		Default("1h").Duration()
+
 	chunkPoolSize := cmd.Flag("chunk-pool-size", "Maximum size of concurrently allocatable bytes for chunks.").
 		Default("2GB").Bytes()
 
+	chunkCacheSize := cmd.Flag("chunk-cache-size", "Maximum size of chunks held in the in-memory chunk cache. 0 disables the in-memory chunk cache.").
+		Default("0").Bytes()
+
+	chunkCacheMemcachedAddrs := cmd.Flag("chunk-cache-memcached-address", "Address of memcached server to hold the chunk cache instead of the in-memory one (repeatable).").
+		PlaceHolder("<host:port>").Strings()
+
+	maxSeries := cmd.Flag("store.grpc.series-limit", "Maximum number of series touched by a single Series request. The request fails if the limit is exceeded. 0 means no limit.").
+		Default("0").Uint64()
+
+	maxChunks := cmd.Flag("store.grpc.series-chunk-limit", "Maximum number of chunks fetched by a single Series request. The request fails if the limit is exceeded. A chunk holds up to 120 samples. 0 means no limit.").
+		Default("0").Uint64()
+
+	minTime := model.TimeOrDuration(cmd.Flag("min-time", "Start of the time range served by the store. Only blocks overlapping the range are loaded. It can be time in RFC3339 format or duration relative to now, such as -2w or -1d12h. Valid duration units are ms, s, m, h, d, w, y. Unrestricted if empty.").
+		PlaceHolder("<time>"))
+
+	maxTime := model.TimeOrDuration(cmd.Flag("max-time", "End of the time range served by the store. Only blocks overlapping the range are loaded. It can be time in RFC3339 format or duration relative to now, such as -2w or -1d12h. Valid duration units are ms, s, m, h, d, w, y. Unrestricted if empty.").
+		PlaceHolder("<time>"))
+
+	selectorRelabelConfigFile := cmd.Flag("selector.relabel-config-file", "Path to YAML file with a list of Prometheus relabel configs selecting blocks to serve by their external labels. Blocks whose labels are dropped by the relabelling are not served. Labels common to all selected blocks are advertised as labels of the store.").
+		PlaceHolder("<file-path>").String()
+
+	selectorRelabelConfig := cmd.Flag("selector.relabel-config", "Alternative to 'selector.relabel-config-file' flag. Relabel configs in YAML.").
+		PlaceHolder("<content>").String()
+
+	tenantLabel := cmd.Flag("tenant-label", "External label identifying the tenant of the served data. If set, only StoreAPI requests of the tenant given by this label are served and requests without tenant are rejected.").
+		PlaceHolder("<name>").String()
+
 	objStoreConfig := regCommonObjStoreFlags(cmd, "")
 
 	syncInterval := cmd.Flag("sync-block-duration", "Repeat interval for syncing the blocks between local and remote view.").
@@ -59,7 +101,22 @@ func registerStore(m map[string]setupFunc, app *kingpin.Application, name string
 			*httpBindAddr,
 			peer,
 			uint64(*indexCacheSize),
+			uint64(*indexCacheDiskSize),
+			*indexCacheMemcachedAddrs,
 			uint64(*chunkPoolSize),
+			uint64(*chunkCacheSize),
+			*chunkCacheMemcachedAddrs,
+			*maxSeries,
+			*maxChunks,
+			minTime,
+			maxTime,
+			&pathOrContent{
+				name:    "selector.relabel-config",
+				path:    selectorRelabelConfigFile,
+				content: selectorRelabelConfig,
+			},
+			*indexHeaderIdleTimeout,
+			*tenantLabel,
 			name,
 			debugLogging,
 			*syncInterval,
@@ -82,7 +139,18 @@ func runStore(
 	httpBindAddr string,
 	peer *cluster.Peer,
 	indexCacheSizeBytes uint64,
+	indexCacheDiskSizeBytes uint64,
+	indexCacheMemcachedAddrs []string,
 	chunkPoolSizeBytes uint64,
+	chunkCacheSizeBytes uint64,
+	chunkCacheMemcachedAddrs []string,
+	maxSeries uint64,
+	maxChunks uint64,
+	minTime *model.TimeOrDurationValue,
+	maxTime *model.TimeOrDurationValue,
+	selectorRelabelConfig *pathOrContent,
+	indexHeaderIdleTimeout time.Duration,
+	tenantLabel string,
 	component string,
 	verbose bool,
 	syncInterval time.Duration,
@@ -105,13 +173,54 @@ func runStore(
 			}
 		}()
 
+		var chunkCache cache.Cache
+		if len(chunkCacheMemcachedAddrs) > 0 {
+			c, err := cache.NewMemcachedCache(logger, reg, "chunks", cache.MemcachedConfig{
+				Addresses: chunkCacheMemcachedAddrs,
+			})
+			if err != nil {
+				return errors.Wrap(err, "create memcached chunk cache")
+			}
+			// Store chunks in the background, so queries are not held back by memcached.
+			chunkCache = cache.NewAsyncCache(reg, "chunks", c, chunkCacheQueueSize)
+		} else if chunkCacheSizeBytes > 0 {
+			c, err := cache.NewInMemoryCache(reg, "chunks", chunkCacheSizeBytes)
+			if err != nil {
+				return errors.Wrap(err, "create in-memory chunk cache")
+			}
+			chunkCache = c
+		}
+
+		var filterConf *store.FilterConfig
+		relabelContent, err := selectorRelabelConfig.Content()
+		if err != nil {
+			return err
+		}
+		if minTime.String() != "" || maxTime.String() != "" || len(relabelContent) > 0 {
+			if minTime.String() != "" && maxTime.String() != "" && minTime.PrometheusTimestamp() > maxTime.PrometheusTimestamp() {
+				return errors.Errorf("min-time %s is after max-time %s", minTime, maxTime)
+			}
+			filterConf = &store.FilterConfig{MinTime: *minTime, MaxTime: *maxTime}
+			if filterConf.RelabelConfigs, err = store.ParseRelabelConfigs(relabelContent); err != nil {
+				return errors.Wrap(err, "parse selector relabel config")
+			}
+		}
+
 		bs, err := store.NewBucketStore(
 			logger,
 			reg,
 			bkt,
 			dataDir,
 			indexCacheSizeBytes,
+			indexCacheDiskSizeBytes,
+			indexCacheMemcachedAddrs,
 			chunkPoolSizeBytes,
+			chunkCache,
+			maxSeries,
+			maxChunks,
+			filterConf,
+			indexHeaderIdleTimeout,
+			tenantLabel,
 			verbose,
 		)
 		if err != nil {
@@ -138,6 +247,9 @@ func runStore(
 			})
 
 			runutil.CloseWithLogOnErr(logger, bs, "bucket store")
+			if cl, ok := chunkCache.(io.Closer); ok {
+				runutil.CloseWithLogOnErr(logger, cl, "chunk cache")
+			}
 			return err
 		}, func(error) {
 			cancel()
@@ -41,6 +41,7 @@ const (
 	CompactorSource       SourceType = "compactor"
 	CompactorRepairSource SourceType = "compactor.repair"
 	RulerSource           SourceType = "ruler"
+	ReceiveSource         SourceType = "receive"
 	BucketRepairSource    SourceType = "bucket.repair"
 	TestSource            SourceType = "test"
 )
@@ -191,6 +192,15 @@ func Upload(ctx context.Context, logger log.Logger, bkt objstore.Bucket, bdir st
 		return cleanUp(bkt, id, errors.Wrap(err, "upload index"))
 	}
 
+	// Index header is optional, upload it only if it was created.
+	if _, err := os.Stat(path.Join(bdir, IndexHeaderFilename)); err == nil {
+		if err := objstore.UploadFile(ctx, logger, bkt, path.Join(bdir, IndexHeaderFilename), path.Join(id.String(), IndexHeaderFilename)); err != nil {
+			return cleanUp(bkt, id, errors.Wrap(err, "upload index header"))
+		}
+	} else if !os.IsNotExist(err) {
+		return cleanUp(bkt, id, errors.Wrap(err, "stat index header"))
+	}
+
 	// Meta.json always need to be uploaded as a last item. This will allow to assume block directories without meta file
 	// to be pending uploads.
 	if err := objstore.UploadFile(ctx, logger, bkt, path.Join(bdir, MetaFilename), path.Join(id.String(), MetaFilename)); err != nil {
@@ -216,7 +226,7 @@ func Delete(ctx context.Context, bucket objstore.Bucket, id ulid.ULID) error {
 }
 
 // DownloadMeta downloads only meta file from bucket by block ID.
-func DownloadMeta(ctx context.Context, logger log.Logger, bkt objstore.Bucket, id ulid.ULID) (Meta, error) {
+func DownloadMeta(ctx context.Context, logger log.Logger, bkt objstore.BucketReader, id ulid.ULID) (Meta, error) {
 	rc, err := bkt.Get(ctx, path.Join(id.String(), MetaFilename))
 	if err != nil {
 		return Meta{}, errors.Wrapf(err, "meta.json bkt get for %s", id.String())
@@ -0,0 +1,335 @@
+package block
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/kit/log"
+	"github.com/improbable-eng/thanos/pkg/runutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/tsdb/fileutil"
+	"github.com/prometheus/tsdb/index"
+	"github.com/prometheus/tsdb/labels"
+)
+
+// IndexHeaderFilename is the canonical name for index header files. Index headers are optional, Store Gateway
+// builds them from the index if they are not uploaded along with a block.
+const IndexHeaderFilename = "index.header"
+
+const (
+	indexHeaderMagic = 0xBAAAD792
+
+	// IndexHeaderFormatV1 is the only version of the index header format so far.
+	IndexHeaderFormatV1 = 1
+
+	indexHeaderPreambleLen = 24
+	indexHeaderSymbolLen   = 12
+	indexHeaderNameLen     = 16
+	indexHeaderPostingsLen = 24
+)
+
+// The index header is a compact binary alternative to the JSON index cache. It holds the first lookup
+// stages of an index, i.e. the symbol table and offsets of postings lists, in fixed-size sorted entries,
+// so it can be memory-mapped and queried without decoding it as a whole.
+// All numbers are big-endian:
+//
+//   magic(4) | format(1) | index version(1) | reserved(2)
+//   #symbols(4) | #names(4) | #postings(4) | len(strings)(4)
+//   symbols:  #symbols  * [ref(4) | string offset(4) | string length(4)]
+//   names:    #names    * [string offset(4) | string length(4) | first postings entry(4) | #postings entries(4)]
+//   postings: #postings * [value offset(4) | value length(4) | start(8) | end(8)]
+//   strings
+//   CRC32 Castagnoli of all the above(4)
+//
+// Names are sorted and postings entries of a single name are consecutive with sorted values.
+
+// WriteIndexHeader writes an index header file for the given index.
+func WriteIndexHeader(logger log.Logger, fn string, r *index.Reader) error {
+	pranges, err := r.PostingsRanges()
+	if err != nil {
+		return errors.Wrap(err, "read postings ranges")
+	}
+	return writeIndexHeader(fn, r.Version(), r.SymbolTable(), pranges)
+}
+
+// CreateIndexHeader writes the index header of the block in the given directory next to its index,
+// so it is uploaded along with the block.
+func CreateIndexHeader(logger log.Logger, bdir string) error {
+	indexr, err := index.NewFileReader(filepath.Join(bdir, IndexFilename))
+	if err != nil {
+		return errors.Wrap(err, "open index reader")
+	}
+	defer runutil.CloseWithLogOnErr(logger, indexr, "index header index reader")
+
+	return WriteIndexHeader(logger, filepath.Join(bdir, IndexHeaderFilename), indexr)
+}
+
+// WriteIndexHeaderFromCache writes an index header file with content of the given JSON index cache file.
+func WriteIndexHeaderFromCache(logger log.Logger, cacheFn, fn string) error {
+	version, symbols, _, postings, err := ReadIndexCache(logger, cacheFn)
+	if err != nil {
+		return errors.Wrap(err, "read index cache")
+	}
+	return writeIndexHeader(fn, version, symbols, postings)
+}
+
+func writeIndexHeader(fn string, version int, symbols map[uint32]string, pranges map[labels.Label]index.Range) error {
+	var (
+		strs    []byte
+		strOffs = map[string]uint32{}
+	)
+	addStr := func(s string) (uint32, uint32) {
+		off, ok := strOffs[s]
+		if !ok {
+			off = uint32(len(strs))
+			strs = append(strs, s...)
+			strOffs[s] = off
+		}
+		return off, uint32(len(s))
+	}
+
+	refs := make([]uint32, 0, len(symbols))
+	for ref := range symbols {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+
+	lbls := make([]labels.Label, 0, len(pranges))
+	for l := range pranges {
+		lbls = append(lbls, l)
+	}
+	sort.Slice(lbls, func(i, j int) bool {
+		if lbls[i].Name != lbls[j].Name {
+			return lbls[i].Name < lbls[j].Name
+		}
+		return lbls[i].Value < lbls[j].Value
+	})
+
+	var symbolsSec, namesSec, postingsSec []byte
+
+	for _, ref := range refs {
+		off, l := addStr(symbols[ref])
+		symbolsSec = appendUint32(symbolsSec, ref, off, l)
+	}
+	numNames := 0
+	for i := 0; i < len(lbls); {
+		j := i
+		for j < len(lbls) && lbls[j].Name == lbls[i].Name {
+			off, l := addStr(lbls[j].Value)
+			rng := pranges[lbls[j]]
+			postingsSec = appendUint32(postingsSec, off, l)
+			postingsSec = appendUint64(postingsSec, uint64(rng.Start), uint64(rng.End))
+			j++
+		}
+		off, l := addStr(lbls[i].Name)
+		namesSec = appendUint32(namesSec, off, l, uint32(i), uint32(j-i))
+		numNames++
+		i = j
+	}
+
+	buf := make([]byte, 0, indexHeaderPreambleLen+len(symbolsSec)+len(namesSec)+len(postingsSec)+len(strs)+4)
+	buf = appendUint32(buf, indexHeaderMagic)
+	buf = append(buf, IndexHeaderFormatV1, byte(version), 0, 0)
+	buf = appendUint32(buf, uint32(len(refs)), uint32(numNames), uint32(len(lbls)), uint32(len(strs)))
+	buf = append(buf, symbolsSec...)
+	buf = append(buf, namesSec...)
+	buf = append(buf, postingsSec...)
+	buf = append(buf, strs...)
+	buf = appendUint32(buf, crc32.Checksum(buf, castagnoli))
+
+	// Write to a temporary file first, so a partially written header is never read.
+	tmp := fn + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0666); err != nil {
+		return errors.Wrap(err, "write file")
+	}
+	if err := os.Rename(tmp, fn); err != nil {
+		return errors.Wrap(err, "rename file")
+	}
+	return nil
+}
+
+func appendUint32(b []byte, vs ...uint32) []byte {
+	var tmp [4]byte
+	for _, v := range vs {
+		binary.BigEndian.PutUint32(tmp[:], v)
+		b = append(b, tmp[:]...)
+	}
+	return b
+}
+
+func appendUint64(b []byte, vs ...uint64) []byte {
+	var tmp [8]byte
+	for _, v := range vs {
+		binary.BigEndian.PutUint64(tmp[:], v)
+		b = append(b, tmp[:]...)
+	}
+	return b
+}
+
+// IndexHeader is a memory-mapped index header file. It is safe to use concurrently until closed.
+type IndexHeader struct {
+	f *fileutil.MmapFile
+
+	indexVersion int
+	numSymbols   int
+	numNames     int
+	numPostings  int
+
+	symbols  []byte
+	names    []byte
+	postings []byte
+	strs     []byte
+}
+
+// OpenIndexHeader memory-maps and verifies the index header file.
+func OpenIndexHeader(fn string) (_ *IndexHeader, err error) {
+	f, err := fileutil.OpenMmapFile(fn)
+	if err != nil {
+		return nil, errors.Wrap(err, "mmap file")
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+		}
+	}()
+	b := f.Bytes()
+
+	if len(b) < indexHeaderPreambleLen+4 {
+		return nil, errors.Errorf("index header too short: %d bytes", len(b))
+	}
+	if m := binary.BigEndian.Uint32(b); m != indexHeaderMagic {
+		return nil, errors.Errorf("invalid magic number %x", m)
+	}
+	if b[4] != IndexHeaderFormatV1 {
+		return nil, errors.Errorf("unsupported index header format %d", b[4])
+	}
+	if crc := binary.BigEndian.Uint32(b[len(b)-4:]); crc != crc32.Checksum(b[:len(b)-4], castagnoli) {
+		return nil, errors.New("index header checksum mismatch")
+	}
+	h := &IndexHeader{
+		f:            f,
+		indexVersion: int(b[5]),
+		numSymbols:   int(binary.BigEndian.Uint32(b[8:])),
+		numNames:     int(binary.BigEndian.Uint32(b[12:])),
+		numPostings:  int(binary.BigEndian.Uint32(b[16:])),
+	}
+	strsLen := int(binary.BigEndian.Uint32(b[20:]))
+
+	sections := []struct {
+		dst *[]byte
+		len int
+	}{
+		{&h.symbols, h.numSymbols * indexHeaderSymbolLen},
+		{&h.names, h.numNames * indexHeaderNameLen},
+		{&h.postings, h.numPostings * indexHeaderPostingsLen},
+		{&h.strs, strsLen},
+	}
+	off := indexHeaderPreambleLen
+	for _, s := range sections {
+		if off+s.len > len(b)-4 {
+			return nil, errors.New("index header truncated")
+		}
+		*s.dst = b[off : off+s.len]
+		off += s.len
+	}
+	return h, nil
+}
+
+// Close unmaps the file. Strings returned before are still valid, as they are copied.
+func (h *IndexHeader) Close() error {
+	return h.f.Close()
+}
+
+// IndexVersion returns the version of the index the header was built from.
+func (h *IndexHeader) IndexVersion() int {
+	return h.indexVersion
+}
+
+func (h *IndexHeader) str(b []byte) []byte {
+	off, l := binary.BigEndian.Uint32(b), binary.BigEndian.Uint32(b[4:])
+	return h.strs[off : off+l]
+}
+
+// LookupSymbol returns the symbol of the index with the given reference for decoding series.
+// Symbols are looked up in the mapped file, so the symbol table is never held on the heap.
+func (h *IndexHeader) LookupSymbol(ref uint32) (string, error) {
+	i := sort.Search(h.numSymbols, func(i int) bool {
+		return binary.BigEndian.Uint32(h.symbols[i*indexHeaderSymbolLen:]) >= ref
+	})
+	if i == h.numSymbols {
+		return "", errors.Errorf("unknown symbol reference %d", ref)
+	}
+	e := h.symbols[i*indexHeaderSymbolLen:]
+	if binary.BigEndian.Uint32(e) != ref {
+		return "", errors.Errorf("unknown symbol reference %d", ref)
+	}
+	return string(h.str(e[4:])), nil
+}
+
+// LabelNames returns all label names of the index in sorted order.
+func (h *IndexHeader) LabelNames() []string {
+	res := make([]string, 0, h.numNames)
+	for i := 0; i < h.numNames; i++ {
+		n := string(h.str(h.names[i*indexHeaderNameLen:]))
+		// Skip the label of all postings.
+		if n == "" {
+			continue
+		}
+		res = append(res, n)
+	}
+	return res
+}
+
+// postingsEntries returns postings entries of the given label name.
+func (h *IndexHeader) postingsEntries(name string) []byte {
+	bname := []byte(name)
+	i := sort.Search(h.numNames, func(i int) bool {
+		return bytes.Compare(h.str(h.names[i*indexHeaderNameLen:]), bname) >= 0
+	})
+	if i == h.numNames {
+		return nil
+	}
+	e := h.names[i*indexHeaderNameLen:]
+	if !bytes.Equal(h.str(e), bname) {
+		return nil
+	}
+	first, num := binary.BigEndian.Uint32(e[8:]), binary.BigEndian.Uint32(e[12:])
+	return h.postings[first*indexHeaderPostingsLen : (first+num)*indexHeaderPostingsLen]
+}
+
+// LabelValues returns values of the given label name in sorted order.
+func (h *IndexHeader) LabelValues(name string) []string {
+	ps := h.postingsEntries(name)
+	res := make([]string, 0, len(ps)/indexHeaderPostingsLen)
+	for i := 0; i < len(ps); i += indexHeaderPostingsLen {
+		res = append(res, string(h.str(ps[i:])))
+	}
+	return res
+}
+
+// PostingsRange returns the range of the postings list of the given label pair within the index file.
+func (h *IndexHeader) PostingsRange(name, value string) (index.Range, bool) {
+	ps := h.postingsEntries(name)
+	n := len(ps) / indexHeaderPostingsLen
+	bvalue := []byte(value)
+
+	i := sort.Search(n, func(i int) bool {
+		return bytes.Compare(h.str(ps[i*indexHeaderPostingsLen:]), bvalue) >= 0
+	})
+	if i == n {
+		return index.Range{}, false
+	}
+	e := ps[i*indexHeaderPostingsLen:]
+	if !bytes.Equal(h.str(e), bvalue) {
+		return index.Range{}, false
+	}
+	return index.Range{
+		Start: int64(binary.BigEndian.Uint64(e[8:])),
+		End:   int64(binary.BigEndian.Uint64(e[16:])),
+	}, true
+}
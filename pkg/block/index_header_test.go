@@ -0,0 +1,113 @@
+package block
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/prometheus/tsdb/index"
+	"github.com/prometheus/tsdb/labels"
+)
+
+func TestIndexHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_index_header")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	symbols := map[uint32]string{5: "", 6: "a", 8: "b", 10: "job", 14: "1", 16: "2"}
+	postings := map[labels.Label]index.Range{
+		{Name: "", Value: ""}:      {Start: 100, End: 200},
+		{Name: "a", Value: "1"}:    {Start: 200, End: 220},
+		{Name: "a", Value: "2"}:    {Start: 220, End: 250},
+		{Name: "b", Value: "2"}:    {Start: 250, End: 260},
+		{Name: "job", Value: "a"}:  {Start: 260, End: 300},
+		{Name: "job", Value: "ab"}: {Start: 300, End: 1 << 40},
+	}
+	fn := filepath.Join(dir, IndexHeaderFilename)
+	testutil.Ok(t, writeIndexHeader(fn, 2, symbols, postings))
+
+	h, err := OpenIndexHeader(fn)
+	testutil.Ok(t, err)
+
+	testutil.Equals(t, 2, h.IndexVersion())
+	for ref, exp := range symbols {
+		sym, err := h.LookupSymbol(ref)
+		testutil.Ok(t, err)
+		testutil.Equals(t, exp, sym)
+	}
+	for _, ref := range []uint32{0, 7, 17} {
+		_, err := h.LookupSymbol(ref)
+		testutil.NotOk(t, err)
+	}
+	testutil.Equals(t, []string{"a", "b", "job"}, h.LabelNames())
+	testutil.Equals(t, []string{"1", "2"}, h.LabelValues("a"))
+	testutil.Equals(t, []string{"a", "ab"}, h.LabelValues("job"))
+	testutil.Equals(t, []string{}, h.LabelValues("c"))
+
+	for l, exp := range postings {
+		rng, ok := h.PostingsRange(l.Name, l.Value)
+		testutil.Assert(t, ok, "postings of %s not found", l)
+		testutil.Equals(t, exp, rng)
+	}
+	for _, l := range []labels.Label{{Name: "a", Value: "3"}, {Name: "a", Value: ""}, {Name: "c", Value: "1"}, {Name: "jo", Value: "a"}} {
+		_, ok := h.PostingsRange(l.Name, l.Value)
+		testutil.Assert(t, !ok, "unexpected postings of %s", l)
+	}
+	testutil.Ok(t, h.Close())
+
+	// Corrupted header must not be opened.
+	b, err := ioutil.ReadFile(fn)
+	testutil.Ok(t, err)
+	b[len(b)-5]++
+	testutil.Ok(t, ioutil.WriteFile(fn, b, 0666))
+
+	_, err = OpenIndexHeader(fn)
+	testutil.NotOk(t, err)
+}
+
+func TestWriteIndexHeaderFromCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_index_header_from_cache")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	cache := indexCache{
+		Version:     2,
+		Symbols:     map[uint32]string{5: "a", 7: "b", 9: "1"},
+		LabelValues: map[string][]string{"a": {"1"}, "b": {"1"}},
+		Postings: []postingsRange{
+			{Name: "", Value: "", Start: 10, End: 20},
+			{Name: "a", Value: "1", Start: 20, End: 30},
+			{Name: "b", Value: "1", Start: 30, End: 40},
+		},
+	}
+	b, err := json.Marshal(cache)
+	testutil.Ok(t, err)
+
+	cachefn := filepath.Join(dir, IndexCacheFilename)
+	testutil.Ok(t, ioutil.WriteFile(cachefn, b, 0666))
+
+	fn := filepath.Join(dir, IndexHeaderFilename)
+	testutil.Ok(t, WriteIndexHeaderFromCache(log.NewNopLogger(), cachefn, fn))
+
+	h, err := OpenIndexHeader(fn)
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, h.Close()) }()
+
+	testutil.Equals(t, 2, h.IndexVersion())
+	for ref, exp := range cache.Symbols {
+		sym, err := h.LookupSymbol(ref)
+		testutil.Ok(t, err)
+		testutil.Equals(t, exp, sym)
+	}
+	testutil.Equals(t, []string{"a", "b"}, h.LabelNames())
+
+	for _, p := range cache.Postings {
+		rng, ok := h.PostingsRange(p.Name, p.Value)
+		testutil.Assert(t, ok, "postings of %s=%s not found", p.Name, p.Value)
+		testutil.Equals(t, index.Range{Start: p.Start, End: p.End}, rng)
+	}
+}
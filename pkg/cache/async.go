@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// asyncCacheBatchSize is the maximum number of items stored in the underlying cache at once. Stores waiting
+// in the queue are merged until the batch is full.
+const asyncCacheBatchSize = 100
+
+// AsyncCache is a Cache storing values in the background, so callers are never blocked by stores
+// against a remote cache. Fetches are passed to the underlying cache directly.
+type AsyncCache struct {
+	cache Cache
+
+	queue chan map[string][]byte
+	quit  chan struct{}
+	wg    sync.WaitGroup
+
+	dropped prometheus.Counter
+}
+
+// NewAsyncCache returns an AsyncCache storing values in the given cache. At most queueSize stores wait
+// to be done, further ones are dropped. The name is used to distinguish metrics of different caches.
+// The underlying cache is closed along with the AsyncCache if it implements io.Closer.
+func NewAsyncCache(reg prometheus.Registerer, name string, c Cache, queueSize int) *AsyncCache {
+	ac := &AsyncCache{
+		cache: c,
+		queue: make(chan map[string][]byte, queueSize),
+		quit:  make(chan struct{}),
+	}
+	ac.dropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_async_stores_dropped_total",
+		Help:        "Total number of stores that were dropped because too many stores were waiting.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	if reg != nil {
+		reg.MustRegister(ac.dropped)
+	}
+
+	ac.wg.Add(1)
+	go ac.storeLoop()
+	return ac
+}
+
+func (c *AsyncCache) storeLoop() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.quit:
+			// Store what is left in the queue before exiting.
+			for len(c.queue) > 0 {
+				c.storeBatch(<-c.queue)
+			}
+			return
+		case data := <-c.queue:
+			c.storeBatch(data)
+		}
+	}
+}
+
+// storeBatch stores the given values along with values of stores currently waiting in the queue.
+func (c *AsyncCache) storeBatch(data map[string][]byte) {
+	for len(data) < asyncCacheBatchSize && len(c.queue) > 0 {
+		for k, v := range <-c.queue {
+			data[k] = v
+		}
+	}
+	c.cache.Store(context.Background(), data)
+}
+
+// Store implements Cache. Values are copied, so the caller may reuse them after return.
+func (c *AsyncCache) Store(_ context.Context, data map[string][]byte) {
+	cdata := make(map[string][]byte, len(data))
+	for k, v := range data {
+		cv := make([]byte, len(v))
+		copy(cv, v)
+		cdata[k] = cv
+	}
+
+	select {
+	case c.queue <- cdata:
+	default:
+		c.dropped.Inc()
+	}
+}
+
+// Fetch implements Cache.
+func (c *AsyncCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	return c.cache.Fetch(ctx, keys)
+}
+
+// Close waits for all queued values to be stored and closes the underlying cache.
+func (c *AsyncCache) Close() error {
+	close(c.quit)
+	c.wg.Wait()
+
+	if cl, ok := c.cache.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
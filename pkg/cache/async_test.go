@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+)
+
+type blockingCache struct {
+	Cache
+	// stores receives the number of items of each store before it is blocked until release is closed.
+	stores  chan int
+	release chan struct{}
+}
+
+func (c *blockingCache) Store(ctx context.Context, data map[string][]byte) {
+	c.stores <- len(data)
+	<-c.release
+	c.Cache.Store(ctx, data)
+}
+
+func TestAsyncCache_StoreFetch(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	inmem, err := NewInMemoryCache(nil, "test", 1e6)
+	testutil.Ok(t, err)
+	bc := &blockingCache{Cache: inmem, stores: make(chan int, 10), release: make(chan struct{})}
+	c := NewAsyncCache(nil, "test", bc, 2)
+
+	// Values are copied, so the caller can reuse them right away. Stores do not block the caller.
+	v := []byte("1")
+	c.Store(ctx, map[string][]byte{"a": v})
+	v[0] = '2'
+	testutil.Equals(t, map[string][]byte{}, c.Fetch(ctx, []string{"a"}))
+
+	// Once the first store is taken by the background loop, the next ones fill the queue and the rest is dropped.
+	testutil.Equals(t, 1, <-bc.stores)
+	c.Store(ctx, map[string][]byte{"b": []byte("1")})
+	c.Store(ctx, map[string][]byte{"c": []byte("1")})
+	c.Store(ctx, map[string][]byte{"d": []byte("1")})
+
+	// Queued values are stored on close, merged into a single store.
+	close(bc.release)
+	testutil.Ok(t, c.Close())
+	testutil.Equals(t, 2, <-bc.stores)
+	testutil.Equals(t, map[string][]byte{"a": []byte("1"), "b": []byte("1"), "c": []byte("1")}, c.Fetch(ctx, []string{"a", "b", "c", "d"}))
+}
@@ -0,0 +1,16 @@
+package cache
+
+import (
+	"context"
+)
+
+// Cache is a generic key-value cache for byte blobs. All operations are best effort: failures are
+// expected to be logged and reported as cache misses, so callers can always fall back to computing the value.
+// NOTE: It is required to be thread-safe.
+type Cache interface {
+	// Store puts the given values into the cache under the given keys, replacing any existing ones.
+	Store(ctx context.Context, data map[string][]byte)
+
+	// Fetch returns values for all given keys found in the cache. Missing keys are not included in the result.
+	Fetch(ctx context.Context, keys []string) map[string][]byte
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/simplelru"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InMemoryCache is a size-bounded LRU cache held in process memory.
+type InMemoryCache struct {
+	mtx     sync.Mutex
+	lru     *lru.LRU
+	maxSize uint64
+	curSize uint64
+
+	requests    prometheus.Counter
+	hits        prometheus.Counter
+	added       prometheus.Counter
+	evicted     prometheus.Counter
+	current     prometheus.Gauge
+	currentSize prometheus.Gauge
+}
+
+// NewInMemoryCache creates a new LRU cache that ensures the total size of stored values approximately
+// does not exceed maxBytes. The name is used to distinguish metrics of different caches.
+func NewInMemoryCache(reg prometheus.Registerer, name string, maxBytes uint64) (*InMemoryCache, error) {
+	if maxBytes == 0 {
+		return nil, errors.New("maximum size of in-memory cache must be greater than 0")
+	}
+	c := &InMemoryCache{
+		maxSize: maxBytes,
+	}
+	constLabels := prometheus.Labels{"name": name}
+
+	c.requests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_inmemory_requests_total",
+		Help:        "Total number of requests to the in-memory cache.",
+		ConstLabels: constLabels,
+	})
+	c.hits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_inmemory_hits_total",
+		Help:        "Total number of requests to the in-memory cache that were a hit.",
+		ConstLabels: constLabels,
+	})
+	c.added = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_inmemory_items_added_total",
+		Help:        "Total number of items that were added to the in-memory cache.",
+		ConstLabels: constLabels,
+	})
+	c.evicted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_inmemory_items_evicted_total",
+		Help:        "Total number of items that were evicted from the in-memory cache.",
+		ConstLabels: constLabels,
+	})
+	c.current = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "thanos_cache_inmemory_items",
+		Help:        "Current number of items in the in-memory cache.",
+		ConstLabels: constLabels,
+	})
+	c.currentSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "thanos_cache_inmemory_items_size_bytes",
+		Help:        "Current byte size of items in the in-memory cache.",
+		ConstLabels: constLabels,
+	})
+
+	// Initialize LRU cache with a high size limit since we will manage evictions ourselves
+	// based on stored size.
+	l, err := lru.NewLRU(1e12, c.onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = l
+
+	if reg != nil {
+		reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "thanos_cache_inmemory_max_size_bytes",
+			Help:        "Maximum number of bytes to be held in the in-memory cache.",
+			ConstLabels: constLabels,
+		}, func() float64 {
+			return float64(maxBytes)
+		}))
+		reg.MustRegister(c.requests, c.hits, c.added, c.evicted, c.current, c.currentSize)
+	}
+	return c, nil
+}
+
+func (c *InMemoryCache) onEvict(key, val interface{}) {
+	v := val.([]byte)
+
+	c.evicted.Inc()
+	c.current.Dec()
+	c.currentSize.Sub(float64(len(v)))
+
+	c.curSize -= uint64(len(v))
+}
+
+// Store implements Cache. Values larger than the whole cache are skipped.
+func (c *InMemoryCache) Store(_ context.Context, data map[string][]byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for k, v := range data {
+		if uint64(len(v)) > c.maxSize {
+			continue
+		}
+		// Remove the previous value first, so it is accounted properly.
+		c.lru.Remove(k)
+
+		for c.curSize+uint64(len(v)) > c.maxSize {
+			c.lru.RemoveOldest()
+		}
+
+		// The caller may be passing in a sub-slice of a huge array. Copy the data
+		// to ensure we don't waste huge amounts of space for something small.
+		cv := make([]byte, len(v))
+		copy(cv, v)
+		c.lru.Add(k, cv)
+
+		c.added.Inc()
+		c.current.Inc()
+		c.currentSize.Add(float64(len(v)))
+		c.curSize += uint64(len(v))
+	}
+}
+
+// Fetch implements Cache.
+func (c *InMemoryCache) Fetch(_ context.Context, keys []string) map[string][]byte {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	res := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		c.requests.Inc()
+
+		v, ok := c.lru.Get(k)
+		if !ok {
+			continue
+		}
+		c.hits.Inc()
+		res[k] = v.([]byte)
+	}
+	return res
+}
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/improbable-eng/thanos/pkg/testutil"
+)
+
+func TestInMemoryCache_StoreFetch(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := NewInMemoryCache(nil, "test", 0)
+	testutil.NotOk(t, err)
+
+	c, err := NewInMemoryCache(nil, "test", 10)
+	testutil.Ok(t, err)
+
+	c.Store(ctx, map[string][]byte{"a": []byte("1234"), "b": []byte("123")})
+	testutil.Equals(t, map[string][]byte{"a": []byte("1234"), "b": []byte("123")}, c.Fetch(ctx, []string{"a", "b", "c"}))
+	testutil.Equals(t, uint64(7), c.curSize)
+
+	// Replacing the value must not count the old one.
+	c.Store(ctx, map[string][]byte{"a": []byte("12")})
+	testutil.Equals(t, uint64(5), c.curSize)
+
+	// Touch "a" so "b" is the least recently used and evicted first.
+	testutil.Equals(t, map[string][]byte{"a": []byte("12")}, c.Fetch(ctx, []string{"a"}))
+	c.Store(ctx, map[string][]byte{"c": []byte("123456")})
+	testutil.Equals(t, map[string][]byte{"a": []byte("12"), "c": []byte("123456")}, c.Fetch(ctx, []string{"a", "b", "c"}))
+	testutil.Equals(t, uint64(8), c.curSize)
+
+	// Values larger than the whole cache are skipped.
+	c.Store(ctx, map[string][]byte{"d": []byte("12345678901")})
+	testutil.Equals(t, map[string][]byte{}, c.Fetch(ctx, []string{"d"}))
+	testutil.Equals(t, uint64(8), c.curSize)
+}
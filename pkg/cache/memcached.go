@@ -0,0 +1,344 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	opFetch = "fetch"
+	opStore = "store"
+
+	// maxKeyLength is the maximum length of a key accepted by memcached.
+	maxKeyLength = 250
+)
+
+// MemcachedConfig configures MemcachedCache.
+type MemcachedConfig struct {
+	// Addresses of memcached servers. Keys are distributed across them by their hash.
+	Addresses []string
+	// Timeout of a single operation against a server, including connection establishment.
+	Timeout time.Duration
+	// MaxIdleConnections is a maximum number of idle connections kept open per server.
+	MaxIdleConnections int
+	// Expiration of stored items. Zero means items never expire.
+	Expiration time.Duration
+}
+
+// MemcachedCache is a Cache backed by servers speaking memcached text protocol.
+type MemcachedCache struct {
+	logger log.Logger
+	conf   MemcachedConfig
+
+	mtx  sync.Mutex
+	idle map[string][]net.Conn
+
+	operations *prometheus.CounterVec
+	failures   *prometheus.CounterVec
+	requests   prometheus.Counter
+	hits       prometheus.Counter
+}
+
+// NewMemcachedCache returns a new MemcachedCache for the given servers. The name is used to distinguish
+// metrics of different caches.
+func NewMemcachedCache(logger log.Logger, reg prometheus.Registerer, name string, conf MemcachedConfig) (*MemcachedCache, error) {
+	if len(conf.Addresses) == 0 {
+		return nil, errors.New("no memcached addresses provided")
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = 100 * time.Millisecond
+	}
+	if conf.MaxIdleConnections <= 0 {
+		conf.MaxIdleConnections = 10
+	}
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	c := &MemcachedCache{
+		logger: logger,
+		conf:   conf,
+		idle:   map[string][]net.Conn{},
+	}
+	constLabels := prometheus.Labels{"name": name}
+
+	c.operations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "thanos_cache_memcached_operations_total",
+		Help:        "Total number of operations against memcached servers.",
+		ConstLabels: constLabels,
+	}, []string{"operation"})
+	c.failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "thanos_cache_memcached_operation_failures_total",
+		Help:        "Total number of operations against memcached servers that failed.",
+		ConstLabels: constLabels,
+	}, []string{"operation"})
+	c.requests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_memcached_requests_total",
+		Help:        "Total number of keys requested from memcached.",
+		ConstLabels: constLabels,
+	})
+	c.hits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "thanos_cache_memcached_hits_total",
+		Help:        "Total number of keys requested from memcached that were a hit.",
+		ConstLabels: constLabels,
+	})
+
+	// Initialize metrics with 0.
+	for _, op := range []string{opFetch, opStore} {
+		c.operations.WithLabelValues(op)
+		c.failures.WithLabelValues(op)
+	}
+	if reg != nil {
+		reg.MustRegister(c.operations, c.failures, c.requests, c.hits)
+	}
+	return c, nil
+}
+
+// Store implements Cache.
+func (c *MemcachedCache) Store(ctx context.Context, data map[string][]byte) {
+	byServer := map[string][]string{}
+	for k := range data {
+		if !validKey(k) {
+			level.Debug(c.logger).Log("msg", "skipping invalid memcached key", "key", k)
+			continue
+		}
+		addr := c.server(k)
+		byServer[addr] = append(byServer[addr], k)
+	}
+
+	var wg sync.WaitGroup
+	for addr, keys := range byServer {
+		wg.Add(1)
+		go func(addr string, keys []string) {
+			defer wg.Done()
+
+			c.operations.WithLabelValues(opStore).Inc()
+			if err := c.withConn(ctx, addr, func(rw *bufio.ReadWriter) error {
+				return c.store(rw, keys, data)
+			}); err != nil {
+				c.failures.WithLabelValues(opStore).Inc()
+				level.Warn(c.logger).Log("msg", "failed to store items in memcached", "server", addr, "err", err)
+			}
+		}(addr, keys)
+	}
+	wg.Wait()
+}
+
+// Fetch implements Cache.
+func (c *MemcachedCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	byServer := map[string][]string{}
+	for _, k := range keys {
+		c.requests.Inc()
+		if !validKey(k) {
+			continue
+		}
+		addr := c.server(k)
+		byServer[addr] = append(byServer[addr], k)
+	}
+
+	var (
+		res = make(map[string][]byte, len(keys))
+		mtx sync.Mutex
+		wg  sync.WaitGroup
+	)
+	for addr, keys := range byServer {
+		wg.Add(1)
+		go func(addr string, keys []string) {
+			defer wg.Done()
+
+			c.operations.WithLabelValues(opFetch).Inc()
+			if err := c.withConn(ctx, addr, func(rw *bufio.ReadWriter) error {
+				return c.fetch(rw, keys, func(k string, v []byte) {
+					mtx.Lock()
+					res[k] = v
+					mtx.Unlock()
+				})
+			}); err != nil {
+				c.failures.WithLabelValues(opFetch).Inc()
+				level.Warn(c.logger).Log("msg", "failed to fetch items from memcached", "server", addr, "err", err)
+			}
+		}(addr, keys)
+	}
+	wg.Wait()
+
+	c.hits.Add(float64(len(res)))
+	return res
+}
+
+// Close closes all idle connections.
+func (c *MemcachedCache) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for addr, conns := range c.idle {
+		for _, cn := range conns {
+			_ = cn.Close()
+		}
+		delete(c.idle, addr)
+	}
+	return nil
+}
+
+func (c *MemcachedCache) server(key string) string {
+	if len(c.conf.Addresses) == 1 {
+		return c.conf.Addresses[0]
+	}
+	return c.conf.Addresses[crc32.ChecksumIEEE([]byte(key))%uint32(len(c.conf.Addresses))]
+}
+
+// withConn runs f with a connection to the given server. The connection is reused only if f succeeded,
+// otherwise its state is unknown and it is closed.
+func (c *MemcachedCache) withConn(ctx context.Context, addr string, f func(rw *bufio.ReadWriter) error) error {
+	deadline := time.Now().Add(c.conf.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	cn, err := c.conn(addr, deadline)
+	if err != nil {
+		return err
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		_ = cn.Close()
+		return errors.Wrap(err, "set deadline")
+	}
+
+	if err := f(bufio.NewReadWriter(bufio.NewReader(cn), bufio.NewWriter(cn))); err != nil {
+		_ = cn.Close()
+		return err
+	}
+	c.release(addr, cn)
+	return nil
+}
+
+func (c *MemcachedCache) conn(addr string, deadline time.Time) (net.Conn, error) {
+	c.mtx.Lock()
+	if conns := c.idle[addr]; len(conns) > 0 {
+		cn := conns[len(conns)-1]
+		c.idle[addr] = conns[:len(conns)-1]
+		c.mtx.Unlock()
+		return cn, nil
+	}
+	c.mtx.Unlock()
+
+	cn, err := net.DialTimeout("tcp", addr, time.Until(deadline))
+	if err != nil {
+		return nil, errors.Wrapf(err, "dial %s", addr)
+	}
+	return cn, nil
+}
+
+func (c *MemcachedCache) release(addr string, cn net.Conn) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if len(c.idle[addr]) >= c.conf.MaxIdleConnections {
+		_ = cn.Close()
+		return
+	}
+	c.idle[addr] = append(c.idle[addr], cn)
+}
+
+func (c *MemcachedCache) store(rw *bufio.ReadWriter, keys []string, data map[string][]byte) error {
+	exp := int64(c.conf.Expiration / time.Second)
+
+	// Pipeline all sets and read replies afterwards.
+	for _, k := range keys {
+		v := data[k]
+		if _, err := fmt.Fprintf(rw, "set %s 0 %d %d\r\n", k, exp, len(v)); err != nil {
+			return err
+		}
+		if _, err := rw.Write(v); err != nil {
+			return err
+		}
+		if _, err := rw.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	for _, k := range keys {
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return errors.Wrap(err, "read reply")
+		}
+		if !bytes.Equal(line, []byte("STORED\r\n")) {
+			return errors.Errorf("unexpected reply for key %s: %q", k, line)
+		}
+	}
+	return nil
+}
+
+func (c *MemcachedCache) fetch(rw *bufio.ReadWriter, keys []string, found func(k string, v []byte)) error {
+	if _, err := rw.WriteString("get"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := rw.WriteString(" " + k); err != nil {
+			return err
+		}
+	}
+	if _, err := rw.WriteString("\r\n"); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	for {
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return errors.Wrap(err, "read reply")
+		}
+		if bytes.Equal(line, []byte("END\r\n")) {
+			return nil
+		}
+
+		// VALUE <key> <flags> <bytes>\r\n<data block>\r\n
+		fields := bytes.Fields(line)
+		if len(fields) != 4 || !bytes.Equal(fields[0], []byte("VALUE")) {
+			return errors.Errorf("unexpected reply: %q", line)
+		}
+		k := string(fields[1])
+		size, err := strconv.Atoi(string(fields[3]))
+		if err != nil {
+			return errors.Wrapf(err, "parse value size of key %s", k)
+		}
+
+		v := make([]byte, size+2)
+		if _, err := io.ReadFull(rw, v); err != nil {
+			return errors.Wrapf(err, "read value of key %s", k)
+		}
+		if !bytes.HasSuffix(v, []byte("\r\n")) {
+			return errors.Errorf("corrupt value of key %s", k)
+		}
+		found(k, v[:size])
+	}
+}
+
+// validKey returns true if the key can be used with memcached text protocol.
+func validKey(k string) bool {
+	if len(k) == 0 || len(k) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(k); i++ {
+		if k[i] <= ' ' || k[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
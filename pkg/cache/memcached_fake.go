@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// FakeMemcachedServer is a minimal in-process server speaking memcached text protocol. It supports only
+// get, gets, set, delete and quit commands, ignores expiration and never evicts anything.
+// It is meant for tests and local runs without a real memcached.
+type FakeMemcachedServer struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mtx    sync.Mutex
+	items  map[string][]byte
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewFakeMemcachedServer starts a new FakeMemcachedServer listening on a random local port.
+func NewFakeMemcachedServer() (*FakeMemcachedServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &FakeMemcachedServer{
+		ln:    ln,
+		items: map[string][]byte{},
+		conns: map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *FakeMemcachedServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Items returns the number of stored items.
+func (s *FakeMemcachedServer) Items() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.items)
+}
+
+// Close stops the server and closes all its connections.
+func (s *FakeMemcachedServer) Close() error {
+	err := s.ln.Close()
+
+	s.mtx.Lock()
+	s.closed = true
+	for cn := range s.conns {
+		_ = cn.Close()
+	}
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *FakeMemcachedServer) serve() {
+	defer s.wg.Done()
+	for {
+		cn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mtx.Lock()
+		if s.closed {
+			s.mtx.Unlock()
+			_ = cn.Close()
+			return
+		}
+		s.conns[cn] = struct{}{}
+		s.mtx.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(cn)
+
+			s.mtx.Lock()
+			delete(s.conns, cn)
+			s.mtx.Unlock()
+			_ = cn.Close()
+		}()
+	}
+}
+
+func (s *FakeMemcachedServer) handle(cn net.Conn) {
+	rw := bufio.NewReadWriter(bufio.NewReader(cn), bufio.NewWriter(cn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := bytes.Fields([]byte(line))
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch string(fields[0]) {
+		case "get", "gets":
+			s.mtx.Lock()
+			for _, k := range fields[1:] {
+				v, ok := s.items[string(k)]
+				if !ok {
+					continue
+				}
+				_, _ = rw.WriteString("VALUE " + string(k) + " 0 " + strconv.Itoa(len(v)) + "\r\n")
+				_, _ = rw.Write(v)
+				_, _ = rw.WriteString("\r\n")
+			}
+			s.mtx.Unlock()
+			_, _ = rw.WriteString("END\r\n")
+
+		case "set":
+			// set <key> <flags> <exptime> <bytes> [noreply]
+			if len(fields) < 5 {
+				_, _ = rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+				break
+			}
+			size, err := strconv.Atoi(string(fields[4]))
+			if err != nil || size < 0 {
+				_, _ = rw.WriteString("CLIENT_ERROR bad data chunk\r\n")
+				break
+			}
+			v := make([]byte, size+2)
+			if _, err := io.ReadFull(rw, v); err != nil {
+				return
+			}
+			s.mtx.Lock()
+			s.items[string(fields[1])] = v[:size]
+			s.mtx.Unlock()
+
+			if len(fields) < 6 || string(fields[5]) != "noreply" {
+				_, _ = rw.WriteString("STORED\r\n")
+			}
+
+		case "delete":
+			if len(fields) < 2 {
+				_, _ = rw.WriteString("ERROR\r\n")
+				break
+			}
+			s.mtx.Lock()
+			_, ok := s.items[string(fields[1])]
+			delete(s.items, string(fields[1]))
+			s.mtx.Unlock()
+
+			if ok {
+				_, _ = rw.WriteString("DELETED\r\n")
+			} else {
+				_, _ = rw.WriteString("NOT_FOUND\r\n")
+			}
+
+		case "quit":
+			return
+
+		default:
+			_, _ = rw.WriteString("ERROR\r\n")
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+)
+
+func TestMemcachedCache_StoreFetch(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	ctx := context.Background()
+
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		srv, err := NewFakeMemcachedServer()
+		testutil.Ok(t, err)
+		defer func() { testutil.Ok(t, srv.Close()) }()
+
+		addrs = append(addrs, srv.Addr())
+	}
+
+	_, err := NewMemcachedCache(nil, nil, "test", MemcachedConfig{})
+	testutil.NotOk(t, err)
+
+	c, err := NewMemcachedCache(nil, nil, "test", MemcachedConfig{Addresses: addrs, Timeout: 5 * time.Second})
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, c.Close()) }()
+
+	data := map[string][]byte{}
+	var keys []string
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprintf("key-%d", i)
+		data[k] = []byte(fmt.Sprintf("value\r\n%d", i))
+		keys = append(keys, k)
+	}
+	data["empty"] = []byte{}
+	keys = append(keys, "empty")
+
+	testutil.Equals(t, map[string][]byte{}, c.Fetch(ctx, keys))
+
+	c.Store(ctx, data)
+	testutil.Equals(t, data, c.Fetch(ctx, append(keys, "missing")))
+
+	// Invalid keys are never stored nor fetched.
+	c.Store(ctx, map[string][]byte{"invalid key": []byte("1")})
+	testutil.Equals(t, map[string][]byte{}, c.Fetch(ctx, []string{"invalid key"}))
+}
+
+func TestMemcachedCache_Unavailable(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	ctx := context.Background()
+
+	srv, err := NewFakeMemcachedServer()
+	testutil.Ok(t, err)
+	addr := srv.Addr()
+	testutil.Ok(t, srv.Close())
+
+	c, err := NewMemcachedCache(nil, nil, "test", MemcachedConfig{Addresses: []string{addr}})
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, c.Close()) }()
+
+	// Failures are reported as misses.
+	c.Store(ctx, map[string][]byte{"a": []byte("1")})
+	testutil.Equals(t, map[string][]byte{}, c.Fetch(ctx, []string{"a"}))
+}
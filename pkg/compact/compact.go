@@ -612,6 +612,10 @@ func RepairIssue347(ctx context.Context, logger log.Logger, bkt objstore.Bucket,
 		return errors.Wrapf(err, "repaired block is invalid %s", resid)
 	}
 
+	if err := block.CreateIndexHeader(logger, filepath.Join(tmpdir, resid.String())); err != nil {
+		return errors.Wrapf(err, "create index header of repaired block %s", resid)
+	}
+
 	level.Info(logger).Log("msg", "uploading repaired block", "newID", resid)
 	if err = block.Upload(ctx, logger, bkt, filepath.Join(tmpdir, resid.String())); err != nil {
 		return retry(errors.Wrapf(err, "upload of %s failed", resid))
@@ -750,6 +754,11 @@ func (cg *Group) compact(ctx context.Context, dir string, comp tsdb.Compactor) (
 		return compID, halt(errors.Wrapf(err, "resulted compacted block %s overlaps with something", bdir))
 	}
 
+	// Upload the index header along with the block, so store gateways do not need to build it.
+	if err := block.CreateIndexHeader(cg.logger, bdir); err != nil {
+		return compID, errors.Wrapf(err, "create index header of block %s", bdir)
+	}
+
 	begin = time.Now()
 
 	if err := block.Upload(ctx, cg.logger, cg.bkt, bdir); err != nil {
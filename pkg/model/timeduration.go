@@ -0,0 +1,72 @@
+package model
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// TimeOrDurationValue is a flag value holding either an absolute time or a duration relative to now.
+type TimeOrDurationValue struct {
+	Time *time.Time
+	Dur  *model.Duration
+}
+
+// Set parses the value as RFC3339 time or, if that fails, as a duration relative to now, e.g. -2w.
+func (tdv *TimeOrDurationValue) Set(s string) error {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		tdv.Time, tdv.Dur = &t, nil
+		return nil
+	}
+
+	var minus bool
+	if len(s) > 0 && s[0] == '-' {
+		minus = true
+		s = s[1:]
+	}
+	dur, err := model.ParseDuration(s)
+	if err != nil {
+		return errors.Errorf("%q is neither RFC3339 time nor duration", s)
+	}
+	if minus {
+		dur = -dur
+	}
+	tdv.Time, tdv.Dur = nil, &dur
+	return nil
+}
+
+// String returns the value as it was set.
+func (tdv *TimeOrDurationValue) String() string {
+	switch {
+	case tdv.Time != nil:
+		return tdv.Time.Format(time.RFC3339)
+	case tdv.Dur == nil:
+		return ""
+	case *tdv.Dur < 0:
+		return "-" + (-*tdv.Dur).String()
+	}
+	return tdv.Dur.String()
+}
+
+// PrometheusTimestamp returns the value in milliseconds. Durations are evaluated relative to the current time.
+func (tdv *TimeOrDurationValue) PrometheusTimestamp() int64 {
+	if tdv.Time != nil {
+		return timestamp.FromTime(*tdv.Time)
+	}
+	var d time.Duration
+	if tdv.Dur != nil {
+		d = time.Duration(*tdv.Dur)
+	}
+	return timestamp.FromTime(time.Now().Add(d))
+}
+
+// TimeOrDuration registers the flag as TimeOrDurationValue.
+func TimeOrDuration(flags *kingpin.FlagClause) *TimeOrDurationValue {
+	value := new(TimeOrDurationValue)
+	flags.SetValue(value)
+	return value
+}
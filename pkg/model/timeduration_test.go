@@ -0,0 +1,27 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+)
+
+func TestTimeOrDurationValue(t *testing.T) {
+	var v TimeOrDurationValue
+
+	testutil.Ok(t, v.Set("2018-09-01T12:00:00Z"))
+	testutil.Equals(t, "2018-09-01T12:00:00Z", v.String())
+	testutil.Equals(t, timestamp.FromTime(time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)), v.PrometheusTimestamp())
+
+	testutil.Ok(t, v.Set("-2w"))
+	testutil.Equals(t, "-2w", v.String())
+	exp := timestamp.FromTime(time.Now().Add(-14 * 24 * time.Hour))
+	testutil.Assert(t, v.PrometheusTimestamp()-exp < 1000, "unexpected timestamp of relative time")
+
+	testutil.Ok(t, v.Set("1h"))
+	testutil.Equals(t, "1h", v.String())
+
+	testutil.NotOk(t, v.Set("yesterday"))
+}
@@ -9,8 +9,10 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/improbable-eng/thanos/pkg/objstore"
 	"github.com/improbable-eng/thanos/pkg/objstore/azure"
+	"github.com/improbable-eng/thanos/pkg/objstore/filesystem"
 	"github.com/improbable-eng/thanos/pkg/objstore/gcs"
 	"github.com/improbable-eng/thanos/pkg/objstore/s3"
+	"github.com/improbable-eng/thanos/pkg/objstore/swift"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"gopkg.in/yaml.v2"
@@ -19,9 +21,11 @@ import (
 type objProvider string
 
 const (
-	GCS   objProvider = "GCS"
-	S3    objProvider = "S3"
-	AZURE objProvider = "AZURE"
+	GCS        objProvider = "GCS"
+	S3         objProvider = "S3"
+	AZURE      objProvider = "AZURE"
+	SWIFT      objProvider = "SWIFT"
+	FILESYSTEM objProvider = "FILESYSTEM"
 )
 
 type BucketConfig struct {
@@ -57,6 +61,10 @@ func NewBucket(logger log.Logger, confContentYaml []byte, reg *prometheus.Regist
 		bucket, err = s3.NewBucket(logger, config, component)
 	case string(AZURE):
 		bucket, err = azure.NewBucket(logger, config, component)
+	case string(SWIFT):
+		bucket, err = swift.NewBucket(logger, config, component)
+	case string(FILESYSTEM):
+		bucket, err = filesystem.NewBucket(logger, config)
 	default:
 		return nil, errors.Errorf("bucket with type %s is not supported", bucketConf.Type)
 	}
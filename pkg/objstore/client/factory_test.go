@@ -1,6 +1,9 @@
 package client
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/improbable-eng/thanos/pkg/testutil"
@@ -31,3 +34,21 @@ func TestNewBucketNoConfig(t *testing.T) {
 	testutil.NotOk(t, err)
 	testutil.Assert(t, err == ErrNotFound, "it should error with not found")
 }
+
+func TestNewBucketFilesystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bkt-client-test")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	bkt, err := NewBucket(log.NewNopLogger(), []byte(fmt.Sprintf("type: FILESYSTEM\nconfig:\n  directory: %s", dir)), nil, "bkt-client-test")
+	testutil.Ok(t, err)
+	testutil.Equals(t, dir, bkt.Name())
+}
+
+const blankFilesystemConfig = `type: FILESYSTEM`
+
+func TestNewBucketFilesystemBlankConfig(t *testing.T) {
+	_, err := NewBucket(log.NewNopLogger(), []byte(blankFilesystemConfig), nil, "bkt-client-test")
+	testutil.NotOk(t, err)
+	testutil.Assert(t, err != ErrNotFound, "it should not error with not found")
+}
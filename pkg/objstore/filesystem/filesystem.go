@@ -0,0 +1,324 @@
+// Package filesystem implements common object storage abstractions against a directory on the local filesystem.
+package filesystem
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/improbable-eng/thanos/pkg/objstore"
+	"github.com/improbable-eng/thanos/pkg/runutil"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// tmpPrefix is the prefix of files being uploaded. They are renamed to their object name once written,
+// so objects are never seen partially written, and are not listed until then.
+const tmpPrefix = ".tmp-upload-"
+
+// Config stores the configuration for filesystem bucket.
+type Config struct {
+	Directory string `yaml:"directory"`
+}
+
+// Bucket implements the store.Bucket and shipper.Bucket interfaces against the local filesystem.
+// Objects are stored as regular files under the root directory, with object name delimiters mapped to directories.
+// NOTE: Objects are assumed to be immutable once uploaded. Symbolic links are not followed.
+type Bucket struct {
+	logger  log.Logger
+	rootDir string
+}
+
+// NewBucket returns a new Bucket using the provided filesystem config values.
+func NewBucket(logger log.Logger, conf []byte) (*Bucket, error) {
+	var config Config
+	if err := yaml.Unmarshal(conf, &config); err != nil {
+		return nil, err
+	}
+	if config.Directory == "" {
+		return nil, errors.New("missing directory for filesystem bucket")
+	}
+	return NewBucketWithDir(logger, config.Directory)
+}
+
+// NewBucketWithDir returns a new Bucket rooted in the given directory. The directory is created if it does not exist.
+func NewBucketWithDir(logger log.Logger, dir string) (*Bucket, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "absolute path of %s", dir)
+	}
+	if err := os.MkdirAll(absDir, 0777); err != nil {
+		return nil, errors.Wrapf(err, "create dir %s", absDir)
+	}
+	return &Bucket{logger: logger, rootDir: absDir}, nil
+}
+
+// Name returns the bucket name, which is the root directory.
+func (b *Bucket) Name() string {
+	return b.rootDir
+}
+
+// Iter calls f for each entry in the given directory. The argument to f is the full
+// object name including the prefix of the inspected directory.
+func (b *Bucket) Iter(ctx context.Context, dir string, f func(string) error) error {
+	// Ensure the object name actually ends with a dir suffix, the same as for remote providers.
+	if dir != "" {
+		dir = strings.TrimSuffix(dir, objstore.DirDelim) + objstore.DirDelim
+	}
+
+	absDir, err := b.path(dir)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(absDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "stat %s", absDir)
+	}
+	if !fi.IsDir() {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(absDir)
+	if err != nil {
+		return errors.Wrapf(err, "read dir %s", absDir)
+	}
+
+	var keys []string
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), tmpPrefix) {
+			continue
+		}
+		name := dir + file.Name()
+		if file.IsDir() {
+			empty, err := isDirEmpty(filepath.Join(absDir, file.Name()))
+			if err != nil {
+				return err
+			}
+			// Empty directories are not objects nor prefixes of any object.
+			if empty {
+				continue
+			}
+			name += objstore.DirDelim
+		}
+		keys = append(keys, name)
+	}
+
+	// Return objects first and then directories, the same way as other providers do.
+	sort.Slice(keys, func(i, j int) bool {
+		iDir, jDir := strings.HasSuffix(keys[i], objstore.DirDelim), strings.HasSuffix(keys[j], objstore.DirDelim)
+		if iDir != jDir {
+			return jDir
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := f(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns a reader for the given object name.
+func (b *Bucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.GetRange(ctx, name, 0, -1)
+}
+
+type rangeReadCloser struct {
+	io.Reader
+	f *os.File
+}
+
+func (r *rangeReadCloser) Close() error {
+	return r.f.Close()
+}
+
+// GetRange returns a new range reader for the given object name and range.
+func (b *Bucket) GetRange(_ context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	if name == "" {
+		return nil, errors.New("filesystem: object name is empty")
+	}
+
+	file, err := b.path(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "stat %s", file)
+	}
+	if fi.IsDir() {
+		return nil, errors.Errorf("filesystem: %s is a directory", name)
+	}
+	if fi.Size() < off {
+		return nil, errors.Errorf("filesystem: offset larger than content length. Len %d. Offset: %v", fi.Size(), off)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", file)
+	}
+	if off > 0 {
+		if _, err := f.Seek(off, io.SeekStart); err != nil {
+			runutil.CloseWithLogOnErr(b.logger, f, "close file %s", file)
+			return nil, errors.Wrapf(err, "seek %s to %d", file, off)
+		}
+	}
+	if length == -1 {
+		return f, nil
+	}
+	return &rangeReadCloser{Reader: io.LimitReader(f, length), f: f}, nil
+}
+
+// Exists checks if the given object exists.
+func (b *Bucket) Exists(_ context.Context, name string) (bool, error) {
+	file, err := b.path(name)
+	if err != nil {
+		return false, err
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "stat %s", file)
+	}
+	return !fi.IsDir(), nil
+}
+
+// Upload the contents of the reader as an object into the bucket. The object is written to a temporary file
+// in the same directory first and renamed once complete, so readers never see a partially written object.
+func (b *Bucket) Upload(_ context.Context, name string, r io.Reader) (err error) {
+	file, err := b.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return errors.Wrapf(err, "create dir for %s", file)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(file), tmpPrefix)
+	if err != nil {
+		return errors.Wrapf(err, "create temporary file for %s", file)
+	}
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			if rerr := os.Remove(tmp); rerr != nil && !os.IsNotExist(rerr) {
+				level.Warn(b.logger).Log("msg", "failed to remove temporary file", "file", tmp, "err", rerr)
+			}
+		}
+	}()
+
+	// Temporary files are only readable by the owner, unlike files created with os.Create.
+	if err := f.Chmod(0644); err != nil {
+		runutil.CloseWithLogOnErr(b.logger, f, "close file %s", tmp)
+		return errors.Wrapf(err, "chmod %s", tmp)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		runutil.CloseWithLogOnErr(b.logger, f, "close file %s", tmp)
+		return errors.Wrapf(err, "copy to %s", tmp)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "close %s", tmp)
+	}
+	return errors.Wrapf(os.Rename(tmp, file), "rename %s to %s", tmp, file)
+}
+
+// Delete removes the object with the given name. Directories left empty after removal are deleted as well.
+func (b *Bucket) Delete(_ context.Context, name string) error {
+	file, err := b.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil {
+		return errors.Wrapf(err, "remove %s", file)
+	}
+
+	for dir := filepath.Dir(file); dir != b.rootDir && strings.HasPrefix(dir, b.rootDir); dir = filepath.Dir(dir) {
+		empty, err := isDirEmpty(dir)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			break
+		}
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "remove empty dir %s", dir)
+		}
+	}
+	return nil
+}
+
+// IsObjNotFoundErr returns true if error means that object is not found. Relevant to Get operations.
+func (b *Bucket) IsObjNotFoundErr(err error) bool {
+	return os.IsNotExist(errors.Cause(err))
+}
+
+func (b *Bucket) Close() error { return nil }
+
+// path returns the path of the object with the given name. Names resolving outside of the root directory,
+// e.g. by containing "..", are rejected.
+func (b *Bucket) path(name string) (string, error) {
+	p := filepath.Join(b.rootDir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(b.rootDir, p)
+	if err != nil {
+		return "", errors.Wrapf(err, "filesystem: resolve object name %s", name)
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("filesystem: object name %s resolves outside of the bucket directory", name)
+	}
+	return p, nil
+}
+
+func isDirEmpty(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, errors.Wrapf(err, "open dir %s", name)
+	}
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+// NewTestBucket creates test bkt client backed by a temporary directory.
+// In a close function it removes the directory.
+func NewTestBucket(t testing.TB) (objstore.Bucket, func(), error) {
+	dir, err := ioutil.TempDir("", "test_"+strings.Replace(strings.ToLower(t.Name()), "/", "_", -1))
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := NewBucketWithDir(log.NewNopLogger(), dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Logf("deleting directory %s failed: %s", dir, err)
+		}
+	}, nil
+}
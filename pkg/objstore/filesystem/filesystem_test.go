@@ -0,0 +1,58 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/pkg/errors"
+)
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestBucket_Upload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_filesystem_upload")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	ctx := context.Background()
+	b, err := NewBucketWithDir(nil, filepath.Join(dir, "bucket"))
+	testutil.Ok(t, err)
+
+	testutil.Ok(t, b.Upload(ctx, "a/b", bytes.NewReader([]byte("content"))))
+	c, err := ioutil.ReadFile(filepath.Join(dir, "bucket", "a", "b"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, "content", string(c))
+
+	// A failed upload leaves neither the object nor a temporary file behind.
+	testutil.NotOk(t, b.Upload(ctx, "a/c", errReader{}))
+	ok, err := b.Exists(ctx, "a/c")
+	testutil.Ok(t, err)
+	testutil.Assert(t, !ok, "object of failed upload exists")
+	files, err := ioutil.ReadDir(filepath.Join(dir, "bucket", "a"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(files))
+
+	// Names must not escape the bucket directory.
+	for _, name := range []string{"../outside", "a/../../outside", ".."} {
+		testutil.NotOk(t, b.Upload(ctx, name, bytes.NewReader([]byte("content"))))
+		_, err := b.Get(ctx, name)
+		testutil.NotOk(t, err)
+		testutil.NotOk(t, b.Delete(ctx, name))
+		testutil.NotOk(t, b.Iter(ctx, name, func(string) error { return nil }))
+	}
+	_, err = os.Stat(filepath.Join(dir, "outside"))
+	testutil.Assert(t, os.IsNotExist(err), "object written outside of the bucket directory")
+
+	// Names resolving within the bucket directory are fine.
+	testutil.Ok(t, b.Upload(ctx, "a/../d", bytes.NewReader([]byte("content"))))
+	ok, err = b.Exists(ctx, "d")
+	testutil.Ok(t, err)
+	testutil.Assert(t, ok, "object d does not exist")
+}
@@ -8,9 +8,12 @@ import (
 	"github.com/fortytw2/leaktest"
 	"github.com/improbable-eng/thanos/pkg/objstore"
 	"github.com/improbable-eng/thanos/pkg/objstore/azure"
+	"github.com/improbable-eng/thanos/pkg/objstore/filesystem"
 	"github.com/improbable-eng/thanos/pkg/objstore/gcs"
 	"github.com/improbable-eng/thanos/pkg/objstore/inmem"
 	"github.com/improbable-eng/thanos/pkg/objstore/s3"
+	"github.com/improbable-eng/thanos/pkg/objstore/swift"
+	"github.com/improbable-eng/thanos/pkg/objstore/swift/swifttest"
 	"github.com/improbable-eng/thanos/pkg/testutil"
 )
 
@@ -29,6 +32,39 @@ func ForeachStore(t *testing.T, testFn func(t testing.TB, bkt objstore.Bucket))
 		return
 	}
 
+	// Mandatory Filesystem.
+	bkt, closeFn, err := filesystem.NewTestBucket(t)
+	testutil.Ok(t, err)
+
+	ok := t.Run("filesystem", func(t *testing.T) {
+		defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+		testFn(t, bkt)
+	})
+	closeFn()
+	if !ok {
+		return
+	}
+
+	// Mandatory Swift against in-process fake server.
+	srv := swifttest.NewServer()
+	bkt, closeFn, err = swift.NewTestBucketFromConfig(t, swift.Config{
+		AuthURL:     srv.URL + swifttest.AuthPath,
+		AuthVersion: 1,
+		Username:    "test",
+		Password:    "test",
+	})
+	testutil.Ok(t, err)
+
+	ok = t.Run("swift fake", func(t *testing.T) {
+		testFn(t, bkt)
+	})
+	closeFn()
+	srv.Close()
+	if !ok {
+		return
+	}
+
 	// Optional GCS.
 	if _, ok := os.LookupEnv("THANOS_SKIP_GCS_TESTS"); !ok {
 		bkt, closeFn, err := gcs.NewTestBucket(t, os.Getenv("GCP_PROJECT"))
@@ -84,4 +120,19 @@ func ForeachStore(t *testing.T, testFn func(t testing.TB, bkt objstore.Bucket))
 		t.Log("THANOS_SKIP_AZURE_TESTS envvar present. Skipping test against Azure.")
 	}
 
+	// Optional Swift.
+	if _, ok := os.LookupEnv("THANOS_SKIP_SWIFT_TESTS"); !ok {
+		bkt, closeFn, err := swift.NewTestBucket(t)
+		testutil.Ok(t, err)
+
+		ok := t.Run("swift", func(t *testing.T) {
+			testFn(t, bkt)
+		})
+		closeFn()
+		if !ok {
+			return
+		}
+	} else {
+		t.Log("THANOS_SKIP_SWIFT_TESTS envvar present. Skipping test against Swift.")
+	}
 }
@@ -29,10 +29,19 @@ import (
 // DirDelim is the delimiter used to model a directory structure in an object store bucket.
 const DirDelim = "/"
 
+// Bucket lookup styles supported by S3-compatible object stores.
+const (
+	BucketLookupAuto        = "auto"
+	BucketLookupPath        = "path"
+	BucketLookupVirtualHost = "virtual-host"
+)
+
 // Config stores the configuration for s3 bucket.
 type Config struct {
 	Bucket        string `yaml:"bucket"`
 	Endpoint      string `yaml:"endpoint"`
+	Region        string `yaml:"region"`
+	BucketLookup  string `yaml:"bucket_lookup_type"`
 	AccessKey     string `yaml:"access_key"`
 	Insecure      bool   `yaml:"insecure"`
 	SignatureV2   bool   `yaml:"signature_version2"`
@@ -90,7 +99,24 @@ func NewBucketWithConfig(logger log.Logger, config Config, component string) (*B
 		}
 	}
 
-	client, err := minio.NewWithCredentials(config.Endpoint, credentials.NewChainCredentials(chain), !config.Insecure, "")
+	// Ceph RGW and MinIO deployments usually sit behind a custom endpoint without DNS entries per bucket and
+	// commonly answer location requests with an empty or non-AWS region, so allow to pin both.
+	var lookup minio.BucketLookupType
+	switch config.BucketLookup {
+	case "", BucketLookupAuto:
+		lookup = minio.BucketLookupAuto
+	case BucketLookupPath:
+		lookup = minio.BucketLookupPath
+	case BucketLookupVirtualHost:
+		lookup = minio.BucketLookupDNS
+	}
+
+	client, err := minio.NewWithOptions(config.Endpoint, &minio.Options{
+		Creds:        credentials.NewChainCredentials(chain),
+		Secure:       !config.Insecure,
+		Region:       config.Region,
+		BucketLookup: lookup,
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "initialize s3 client")
 	}
@@ -145,6 +171,12 @@ func Validate(conf Config) error {
 		(conf.AccessKey != "" && conf.SecretKey == "") {
 		return errors.New("insufficient s3 test configuration information")
 	}
+	switch conf.BucketLookup {
+	case "", BucketLookupAuto, BucketLookupPath, BucketLookupVirtualHost:
+	default:
+		return errors.Errorf("unsupported s3 bucket_lookup_type %q, supported: %s, %s, %s",
+			conf.BucketLookup, BucketLookupAuto, BucketLookupPath, BucketLookupVirtualHost)
+	}
 	return nil
 }
 
@@ -176,6 +208,11 @@ func (b *Bucket) Iter(ctx context.Context, dir string, f func(string) error) err
 		if object.Key == "" {
 			continue
 		}
+		// Ceph RGW lists zero-sized directory marker objects (e.g. created by s3fs or other tools) as the
+		// directory itself. They are not objects stored by Thanos.
+		if object.Key == dir {
+			continue
+		}
 		if err := f(object.Key); err != nil {
 			return err
 		}
@@ -256,6 +293,7 @@ func configFromEnv() Config {
 	c := Config{
 		Bucket:    os.Getenv("S3_BUCKET"),
 		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    os.Getenv("S3_REGION"),
 		AccessKey: os.Getenv("S3_ACCESS_KEY"),
 		SecretKey: os.Getenv("S3_SECRET_KEY"),
 	}
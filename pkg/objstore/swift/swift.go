@@ -0,0 +1,640 @@
+// Package swift implements common object storage abstractions against OpenStack Swift APIs.
+package swift
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/improbable-eng/thanos/pkg/objstore"
+	"github.com/improbable-eng/thanos/pkg/runutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/version"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DirDelim is the delimiter used to model a directory structure in an object store bucket.
+const DirDelim = "/"
+
+// listLimit is the maximum number of entries returned by a single container listing request.
+const listLimit = 10000
+
+// Config stores the configuration for Swift container.
+type Config struct {
+	AuthURL           string `yaml:"auth_url"`
+	AuthVersion       int    `yaml:"auth_version"`
+	Username          string `yaml:"username"`
+	UserDomainName    string `yaml:"user_domain_name"`
+	Password          string `yaml:"password"`
+	ProjectName       string `yaml:"project_name"`
+	ProjectDomainName string `yaml:"project_domain_name"`
+	RegionName        string `yaml:"region_name"`
+	ContainerName     string `yaml:"container_name"`
+}
+
+// Validate checks to see the config options are set.
+func (conf Config) Validate() error {
+	if conf.AuthURL == "" || conf.Username == "" || conf.Password == "" {
+		return errors.New("insufficient swift configuration information: auth_url, username and password are required")
+	}
+	if conf.ContainerName == "" {
+		return errors.New("missing swift container name for stored blocks")
+	}
+	switch conf.AuthVersion {
+	case 0, 1, 3:
+	default:
+		return errors.Errorf("unsupported swift auth version %d, supported: 1, 3", conf.AuthVersion)
+	}
+	return nil
+}
+
+// Bucket implements the store.Bucket interface against OpenStack Swift APIs.
+type Bucket struct {
+	logger    log.Logger
+	config    Config
+	client    *http.Client
+	userAgent string
+
+	mtx        sync.Mutex
+	storageURL string
+	token      string
+}
+
+// NewBucket returns a new Bucket using the provided Swift config values.
+func NewBucket(logger log.Logger, conf []byte, component string) (*Bucket, error) {
+	var config Config
+	if err := yaml.Unmarshal(conf, &config); err != nil {
+		return nil, err
+	}
+	return NewBucketWithConfig(logger, config, component)
+}
+
+// NewBucketWithConfig returns a new Bucket using the provided Swift config values.
+func NewBucketWithConfig(logger log.Logger, config Config, component string) (*Bucket, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.AuthVersion == 0 {
+		config.AuthVersion = 3
+	}
+
+	b := &Bucket{
+		logger:    logger,
+		config:    config,
+		userAgent: fmt.Sprintf("thanos-%s/%s (%s)", component, version.Version, runtime.Version()),
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				DialContext: (&net.Dialer{
+					Timeout:   30 * time.Second,
+					KeepAlive: 30 * time.Second,
+				}).DialContext,
+				MaxIdleConns:          100,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ExpectContinueTimeout: 1 * time.Second,
+				ResponseHeaderTimeout: 15 * time.Second,
+			},
+		},
+	}
+	if err := b.authenticate(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "authenticate against swift")
+	}
+	return b, nil
+}
+
+// Name returns the container name for swift.
+func (b *Bucket) Name() string {
+	return b.config.ContainerName
+}
+
+// authenticate fetches a new token and storage URL for the configured auth version.
+func (b *Bucket) authenticate(ctx context.Context) error {
+	var (
+		storageURL, token string
+		err               error
+	)
+	switch b.config.AuthVersion {
+	case 1:
+		storageURL, token, err = b.authV1(ctx)
+	default:
+		storageURL, token, err = b.authV3(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	b.mtx.Lock()
+	b.storageURL, b.token = strings.TrimSuffix(storageURL, "/"), token
+	b.mtx.Unlock()
+	return nil
+}
+
+func (b *Bucket) authV1(ctx context.Context) (string, string, error) {
+	req, err := http.NewRequest(http.MethodGet, b.config.AuthURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("X-Auth-User", b.config.Username)
+	req.Header.Set("X-Auth-Key", b.config.Password)
+	req.Header.Set("User-Agent", b.userAgent)
+
+	resp, err := b.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", "", err
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift auth response body")
+
+	if resp.StatusCode/100 != 2 {
+		return "", "", errors.Errorf("auth v1 request failed with status %s", resp.Status)
+	}
+	storageURL, token := resp.Header.Get("X-Storage-Url"), resp.Header.Get("X-Auth-Token")
+	if storageURL == "" || token == "" {
+		return "", "", errors.New("auth v1 response misses storage URL or token")
+	}
+	return storageURL, token, nil
+}
+
+type keystoneAuthResponse struct {
+	Token struct {
+		Catalog []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				Region    string `json:"region"`
+				URL       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+func (b *Bucket) authV3(ctx context.Context) (string, string, error) {
+	type domain struct {
+		Name string `json:"name"`
+	}
+	userDomain, projectDomain := b.config.UserDomainName, b.config.ProjectDomainName
+	if userDomain == "" {
+		userDomain = "Default"
+	}
+	if projectDomain == "" {
+		projectDomain = userDomain
+	}
+
+	body := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []string{"password"},
+				"password": map[string]interface{}{
+					"user": map[string]interface{}{
+						"name":     b.config.Username,
+						"password": b.config.Password,
+						"domain":   domain{Name: userDomain},
+					},
+				},
+			},
+		},
+	}
+	if b.config.ProjectName != "" {
+		body["auth"].(map[string]interface{})["scope"] = map[string]interface{}{
+			"project": map[string]interface{}{
+				"name":   b.config.ProjectName,
+				"domain": domain{Name: projectDomain},
+			},
+		}
+	}
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return "", "", errors.Wrap(err, "marshal keystone auth request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(b.config.AuthURL, "/")+"/auth/tokens", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", b.userAgent)
+
+	resp, err := b.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", "", err
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift auth response body")
+
+	if resp.StatusCode/100 != 2 {
+		return "", "", errors.Errorf("auth v3 request failed with status %s", resp.Status)
+	}
+	token := resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return "", "", errors.New("auth v3 response misses X-Subject-Token header")
+	}
+
+	var ar keystoneAuthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ar); err != nil {
+		return "", "", errors.Wrap(err, "decode keystone auth response")
+	}
+	for _, svc := range ar.Token.Catalog {
+		if svc.Type != "object-store" {
+			continue
+		}
+		for _, ep := range svc.Endpoints {
+			if ep.Interface != "public" {
+				continue
+			}
+			if b.config.RegionName != "" && ep.Region != b.config.RegionName {
+				continue
+			}
+			return ep.URL, token, nil
+		}
+	}
+	return "", "", errors.Errorf("no public object-store endpoint found in keystone catalog for region %q", b.config.RegionName)
+}
+
+// do executes the request against the container, re-authenticating once if the token has expired.
+// The body is sent with chunked transfer encoding. As a consumed body cannot be resent, seekable bodies are rewound
+// for the retry, while for other bodies the token is checked before sending them.
+func (b *Bucket) do(ctx context.Context, method, object string, query url.Values, header http.Header, body io.Reader) (*http.Response, error) {
+	var (
+		seeker io.Seeker
+		start  int64
+	)
+	if body != nil {
+		var ok bool
+		if seeker, ok = body.(io.Seeker); ok {
+			var err error
+			if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+				return nil, errors.Wrap(err, "get body offset")
+			}
+		} else if err := b.checkToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for retried := false; ; retried = true {
+		b.mtx.Lock()
+		storageURL, token := b.storageURL, b.token
+		b.mtx.Unlock()
+
+		u := storageURL + "/" + url.PathEscape(b.config.ContainerName)
+		if object != "" {
+			u += "/" + escapeObjectName(object)
+		}
+		if len(query) > 0 {
+			u += "?" + query.Encode()
+		}
+
+		var reqBody io.Reader
+		if body != nil {
+			// Hide the reader type, so that net/http does not size it and uses chunked transfer encoding.
+			reqBody = ioutil.NopCloser(body)
+		}
+		req, err := http.NewRequest(method, u, reqBody)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("X-Auth-Token", token)
+		req.Header.Set("User-Agent", b.userAgent)
+
+		resp, err := b.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || retried || (body != nil && seeker == nil) {
+			return resp, nil
+		}
+		runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift unauthorized response body")
+
+		if err := b.authenticate(ctx); err != nil {
+			return nil, errors.Wrap(err, "re-authenticate against swift")
+		}
+		if seeker != nil {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, errors.Wrap(err, "rewind body")
+			}
+		}
+	}
+}
+
+// checkToken re-authenticates if the token has expired, by sending a request without body to the container.
+func (b *Bucket) checkToken(ctx context.Context) error {
+	resp, err := b.do(ctx, http.MethodHead, "", nil, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "head swift container")
+	}
+	runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift head response body")
+	return nil
+}
+
+// statusErr is returned for unexpected Swift API responses.
+type statusErr struct {
+	op     string
+	code   int
+	status string
+}
+
+func (e statusErr) Error() string {
+	return fmt.Sprintf("swift %s: unexpected response status %s", e.op, e.status)
+}
+
+func checkResponse(op string, resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	return statusErr{op: op, code: resp.StatusCode, status: resp.Status}
+}
+
+type listEntry struct {
+	Name   string `json:"name"`
+	Subdir string `json:"subdir"`
+}
+
+// Iter calls f for each entry in the given directory. The argument to f is the full
+// object name including the prefix of the inspected directory.
+func (b *Bucket) Iter(ctx context.Context, dir string, f func(string) error) error {
+	// Ensure the object name actually ends with a dir suffix. Otherwise we'll just iterate the
+	// object itself as one prefix item.
+	if dir != "" {
+		dir = strings.TrimSuffix(dir, DirDelim) + DirDelim
+	}
+
+	marker := ""
+	for {
+		q := url.Values{}
+		q.Set("format", "json")
+		q.Set("delimiter", DirDelim)
+		q.Set("prefix", dir)
+		q.Set("limit", fmt.Sprintf("%d", listLimit))
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+
+		entries, err := b.list(ctx, q)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		// Swift lists objects and pseudo-directories interleaved. Report objects first, the same as other providers.
+		var dirs []string
+		for _, e := range entries {
+			if e.Subdir != "" {
+				dirs = append(dirs, e.Subdir)
+				continue
+			}
+			if e.Name == dir {
+				// Directory marker objects are not objects stored by Thanos.
+				continue
+			}
+			if err := f(e.Name); err != nil {
+				return err
+			}
+		}
+		for _, d := range dirs {
+			if err := f(d); err != nil {
+				return err
+			}
+		}
+
+		if len(entries) < listLimit {
+			return nil
+		}
+		last := entries[len(entries)-1]
+		marker = last.Name
+		if last.Subdir != "" {
+			marker = last.Subdir
+		}
+	}
+}
+
+func (b *Bucket) list(ctx context.Context, q url.Values) ([]listEntry, error) {
+	resp, err := b.do(ctx, http.MethodGet, "", q, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "list swift container")
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift list response body")
+
+	// Not existing container is returned as 404, we treat it the same as an empty one.
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if err := checkResponse("list", resp); err != nil {
+		return nil, err
+	}
+
+	var entries []listEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, errors.Wrap(err, "decode swift list response")
+	}
+	return entries, nil
+}
+
+func (b *Bucket) getRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	if name == "" {
+		return nil, errors.New("swift: object name is empty")
+	}
+
+	if length == 0 {
+		// Empty range cannot be expressed by a Range header, only check that the object exists.
+		ok, err := b.Exists(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, statusErr{op: "get", code: http.StatusNotFound, status: http.StatusText(http.StatusNotFound)}
+		}
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	header := http.Header{}
+	if length != -1 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	} else if off > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", off))
+	}
+
+	resp, err := b.do(ctx, http.MethodGet, name, nil, header, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "get swift object")
+	}
+	if err := checkResponse("get", resp); err != nil {
+		runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift get response body")
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Get returns a reader for the given object name.
+func (b *Bucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.getRange(ctx, name, 0, -1)
+}
+
+// GetRange returns a new range reader for the given object name and range.
+func (b *Bucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	return b.getRange(ctx, name, off, length)
+}
+
+// Exists checks if the given object exists.
+func (b *Bucket) Exists(ctx context.Context, name string) (bool, error) {
+	resp, err := b.do(ctx, http.MethodHead, name, nil, nil, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "head swift object")
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift head response body")
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err := checkResponse("head", resp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Upload the contents of the reader as an object into the bucket.
+func (b *Bucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	resp, err := b.do(ctx, http.MethodPut, name, nil, nil, r)
+	if err != nil {
+		return errors.Wrap(err, "upload swift object")
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift upload response body")
+
+	return checkResponse("upload", resp)
+}
+
+// Delete removes the object with the given name.
+func (b *Bucket) Delete(ctx context.Context, name string) error {
+	resp, err := b.do(ctx, http.MethodDelete, name, nil, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "delete swift object")
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift delete response body")
+
+	return checkResponse("delete", resp)
+}
+
+// IsObjNotFoundErr returns true if error means that object is not found. Relevant to Get operations.
+func (b *Bucket) IsObjNotFoundErr(err error) bool {
+	serr, ok := errors.Cause(err).(statusErr)
+	return ok && serr.code == http.StatusNotFound
+}
+
+func (b *Bucket) Close() error { return nil }
+
+// createContainer creates the configured container. Creating already existing container is not an error.
+func (b *Bucket) createContainer(ctx context.Context) error {
+	resp, err := b.do(ctx, http.MethodPut, "", nil, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "create swift container")
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift create container response body")
+
+	return checkResponse("create container", resp)
+}
+
+// deleteContainer deletes the configured container. The container has to be empty.
+func (b *Bucket) deleteContainer(ctx context.Context) error {
+	resp, err := b.do(ctx, http.MethodDelete, "", nil, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "delete swift container")
+	}
+	defer runutil.CloseWithLogOnErr(b.logger, resp.Body, "swift delete container response body")
+
+	return checkResponse("delete container", resp)
+}
+
+// escapeObjectName escapes every segment of the object name, keeping the delimiters intact.
+func escapeObjectName(name string) string {
+	parts := strings.Split(name, DirDelim)
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, DirDelim)
+}
+
+func configFromEnv() Config {
+	c := Config{
+		AuthURL:           os.Getenv("OS_AUTH_URL"),
+		Username:          os.Getenv("OS_USERNAME"),
+		Password:          os.Getenv("OS_PASSWORD"),
+		UserDomainName:    os.Getenv("OS_USER_DOMAIN_NAME"),
+		ProjectName:       os.Getenv("OS_PROJECT_NAME"),
+		ProjectDomainName: os.Getenv("OS_PROJECT_DOMAIN_NAME"),
+		RegionName:        os.Getenv("OS_REGION_NAME"),
+		ContainerName:     os.Getenv("OS_CONTAINER_NAME"),
+	}
+	if os.Getenv("OS_AUTH_VERSION") == "1" {
+		c.AuthVersion = 1
+	}
+	return c
+}
+
+// NewTestBucket creates test bkt client that before returning creates temporary container.
+// In a close function it empties and deletes the container.
+func NewTestBucket(t testing.TB) (objstore.Bucket, func(), error) {
+	c := configFromEnv()
+	if c.ContainerName != "" && os.Getenv("THANOS_ALLOW_EXISTING_BUCKET_USE") == "" {
+		return nil, nil, errors.New("OS_CONTAINER_NAME is defined. Normally this tests will create temporary container " +
+			"and delete it after test. Unset OS_CONTAINER_NAME env variable to use default logic. If you really want to run " +
+			"tests against provided (NOT USED!) container, set THANOS_ALLOW_EXISTING_BUCKET_USE=true. WARNING: That container " +
+			"needs to be manually cleared. This means that it is only useful to run one test in a time. This is due " +
+			"to safety (accidentally pointing prod container for test).")
+	}
+	return NewTestBucketFromConfig(t, c)
+}
+
+// NewTestBucketFromConfig creates test bkt client for the given config. If no container name is given, a temporary
+// one is created and deleted together with all objects in a close function.
+func NewTestBucketFromConfig(t testing.TB, c Config) (objstore.Bucket, func(), error) {
+	reuseContainer := c.ContainerName != ""
+	if !reuseContainer {
+		src := rand.NewSource(time.Now().UnixNano())
+		c.ContainerName = strings.Replace(fmt.Sprintf("test_%s_%x", strings.ToLower(t.Name()), src.Int63()), "/", "_", -1)
+	}
+
+	b, err := NewBucketWithConfig(log.NewNopLogger(), c, "thanos-e2e-test")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	if reuseContainer {
+		if err := b.Iter(ctx, "", func(f string) error {
+			return errors.Errorf("container %s is not empty", c.ContainerName)
+		}); err != nil {
+			return nil, nil, errors.Wrapf(err, "swift check container %s", c.ContainerName)
+		}
+
+		t.Log("WARNING. Reusing", c.ContainerName, "Swift container for Swift tests. Manual cleanup afterwards is required")
+		return b, func() {}, nil
+	}
+
+	if err := b.createContainer(ctx); err != nil {
+		return nil, nil, err
+	}
+	t.Log("created temporary Swift container for Swift tests with name", c.ContainerName)
+
+	return b, func() {
+		objstore.EmptyBucket(t, ctx, b)
+		if err := b.deleteContainer(ctx); err != nil {
+			t.Logf("deleting container %s failed: %s", c.ContainerName, err)
+		}
+	}, nil
+}
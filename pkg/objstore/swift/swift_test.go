@@ -0,0 +1,58 @@
+package swift_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/improbable-eng/thanos/pkg/objstore/swift"
+	"github.com/improbable-eng/thanos/pkg/objstore/swift/swifttest"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+)
+
+func TestBucket_UploadAfterTokenExpiry(t *testing.T) {
+	srv := swifttest.NewServer()
+	defer srv.Close()
+
+	bkt, closeFn, err := swift.NewTestBucketFromConfig(t, swift.Config{
+		AuthURL:     srv.URL + swifttest.AuthPath,
+		AuthVersion: 1,
+		Username:    "test",
+		Password:    "test",
+	})
+	testutil.Ok(t, err)
+	defer closeFn()
+
+	ctx := context.Background()
+
+	// Seekable body is rewound and resent after re-authentication.
+	srv.ExpireToken()
+	testutil.Ok(t, bkt.Upload(ctx, "id1/obj_1.some", bytes.NewReader([]byte("@test-data@"))))
+
+	// Token is checked before sending a body that cannot be rewound.
+	srv.ExpireToken()
+	testutil.Ok(t, bkt.Upload(ctx, "id1/obj_2.some", ioutil.NopCloser(strings.NewReader("@test-data2@"))))
+
+	for name, exp := range map[string]string{"id1/obj_1.some": "@test-data@", "id1/obj_2.some": "@test-data2@"} {
+		rc, err := bkt.Get(ctx, name)
+		testutil.Ok(t, err)
+		content, err := ioutil.ReadAll(rc)
+		testutil.Ok(t, err)
+		testutil.Ok(t, rc.Close())
+		testutil.Equals(t, exp, string(content))
+	}
+
+	// Empty range returns an empty reader for existing objects only.
+	rc, err := bkt.GetRange(ctx, "id1/obj_1.some", 3, 0)
+	testutil.Ok(t, err)
+	content, err := ioutil.ReadAll(rc)
+	testutil.Ok(t, err)
+	testutil.Ok(t, rc.Close())
+	testutil.Equals(t, "", string(content))
+
+	_, err = bkt.GetRange(ctx, "id1/not-existing", 3, 0)
+	testutil.NotOk(t, err)
+	testutil.Assert(t, bkt.IsObjNotFoundErr(err), "expected not found error, got %s", err)
+}
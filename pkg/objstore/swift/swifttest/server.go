@@ -0,0 +1,258 @@
+// Package swifttest provides an in-process fake Swift server for tests.
+package swifttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// AuthPath is the path of the v1 authentication endpoint of the fake server.
+	AuthPath = "/auth/v1.0"
+
+	fakeStoragePath = "/v1/AUTH_test"
+	defaultLimit    = 10000
+)
+
+type listEntry struct {
+	Name   string `json:"name,omitempty"`
+	Subdir string `json:"subdir,omitempty"`
+}
+
+// fakeServer is a minimal in-memory stand-in for Swift API with v1 authentication. It implements only the subset
+// of the API used by swift.Bucket.
+type fakeServer struct {
+	mtx        sync.Mutex
+	tokens     int
+	containers map[string]map[string][]byte
+}
+
+// Server is a started fake Swift server.
+type Server struct {
+	*httptest.Server
+	fake *fakeServer
+}
+
+// NewServer returns a new started fake Swift server. Use AuthVersion 1 and URL+AuthPath as AuthURL
+// with any username and password to access it.
+func NewServer() *Server {
+	fake := &fakeServer{containers: map[string]map[string][]byte{}}
+	return &Server{Server: httptest.NewServer(fake), fake: fake}
+}
+
+// ExpireToken invalidates the issued authentication token, so that clients have to re-authenticate.
+func (s *Server) ExpireToken() {
+	s.fake.mtx.Lock()
+	defer s.fake.mtx.Unlock()
+
+	s.fake.tokens++
+}
+
+func (s *fakeServer) token() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return fmt.Sprintf("fake-token-%d", s.tokens)
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == AuthPath {
+		if r.Header.Get("X-Auth-User") == "" || r.Header.Get("X-Auth-Key") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Storage-Url", "http://"+r.Host+fakeStoragePath)
+		w.Header().Set("X-Auth-Token", s.token())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Header.Get("X-Auth-Token") != s.token() {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, fakeStoragePath+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, fakeStoragePath+"/"), "/", 2)
+	container := parts[0]
+	if len(parts) == 1 || parts[1] == "" {
+		s.serveContainer(w, r, container)
+		return
+	}
+	s.serveObject(w, r, container, parts[1])
+}
+
+func (s *fakeServer) serveContainer(w http.ResponseWriter, r *http.Request, container string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	objects, ok := s.containers[container]
+	switch r.Method {
+	case http.MethodPut:
+		if !ok {
+			s.containers[container] = map[string][]byte{}
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodDelete:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if len(objects) > 0 {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		delete(s.containers, container)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.list(w, r, objects)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list lists objects and pseudo-directories sorted lexicographically, the same way as Swift does.
+func (s *fakeServer) list(w http.ResponseWriter, r *http.Request, objects map[string][]byte) {
+	q := r.URL.Query()
+	prefix, delim, marker := q.Get("prefix"), q.Get("delimiter"), q.Get("marker")
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+
+	unique := map[string]listEntry{}
+	for name := range objects {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if delim != "" {
+			if i := strings.Index(name[len(prefix):], delim); i >= 0 {
+				subdir := name[:len(prefix)+i+len(delim)]
+				unique[subdir] = listEntry{Subdir: subdir}
+				continue
+			}
+		}
+		unique[name] = listEntry{Name: name}
+	}
+
+	keys := make([]string, 0, len(unique))
+	for k := range unique {
+		if k > marker {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	entries := make([]listEntry, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, unique[k])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *fakeServer) serveObject(w http.ResponseWriter, r *http.Request, container, name string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	objects, ok := s.containers[container]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		objects[name] = b
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodHead:
+		b, ok := objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		b, ok := objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rng := r.Header.Get("Range")
+		if rng == "" {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(b)
+			return
+		}
+
+		start, end, err := parseRange(rng, int64(len(b)))
+		if err != nil {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(b)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(b[start : end+1])
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// parseRange parses single "bytes=start-[end]" range and trims it to the content size.
+func parseRange(rng string, size int64) (int64, int64, error) {
+	parts := strings.SplitN(strings.TrimPrefix(rng, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %s", rng)
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	end := size - 1
+	if parts[1] != "" {
+		if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("unsatisfiable range %s", rng)
+	}
+	return start, end, nil
+}
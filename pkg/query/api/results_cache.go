@@ -0,0 +1,274 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/improbable-eng/thanos/pkg/cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/prometheus/prometheus/promql"
+)
+
+// rangeCacheSplitInterval is the interval range queries are split by. Each interval is cached separately.
+const rangeCacheSplitInterval = int64(24 * time.Hour / time.Millisecond)
+
+// rangeQueryKey identifies all range queries that produce the same results for the same timestamps.
+type rangeQueryKey struct {
+	tenant              string
+	query               string
+	step                int64
+	dedup               bool
+	partialResponse     bool
+	maxSourceResolution time.Duration
+}
+
+// cacheKey returns the cache key of the given split interval of the query. Offset of the query start
+// from the step grid is part of the key, since cached points are reusable only on the same grid.
+func (k rangeQueryKey) cacheKey(start, interval int64) string {
+	offset := (start%k.step + k.step) % k.step
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%t\x00%t\x00%d",
+		k.tenant, k.query, k.step, offset, k.dedup, k.partialResponse, k.maxSourceResolution)))
+	return fmt.Sprintf("range:%s:%d", hex.EncodeToString(h[:]), interval)
+}
+
+// extent is the result of a query for the [Start, End] time range (both inclusive) within a single split interval.
+type extent struct {
+	Start, End int64
+	Matrix     promql.Matrix
+}
+
+func (e extent) covers(start, end int64) bool {
+	return e.Start <= start && end <= e.End
+}
+
+// rangeEvalFunc evaluates the range query with the original step for the given time range.
+// It returns true if the result is partial, i.e. some warnings were reported during evaluation.
+type rangeEvalFunc func(ctx context.Context, start, end int64) (promql.Matrix, bool, *apiError)
+
+// rangeQueryCache caches results of range queries. Queries are split by step-aligned day boundaries and
+// each day keeps the extent of already computed results, so only the missing parts are evaluated.
+// Results newer than maxFreshness or partial results are never cached, as they can still change.
+type rangeQueryCache struct {
+	logger       log.Logger
+	cache        cache.Cache
+	maxFreshness time.Duration
+	now          func() time.Time
+
+	requests prometheus.Counter
+	hits     prometheus.Counter
+}
+
+func newRangeQueryCache(logger log.Logger, reg prometheus.Registerer, c cache.Cache, maxFreshness time.Duration) *rangeQueryCache {
+	rc := &rangeQueryCache{
+		logger:       logger,
+		cache:        c,
+		maxFreshness: maxFreshness,
+		now:          time.Now,
+	}
+	rc.requests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "thanos_query_api_range_cache_extents_requested_total",
+		Help: "Total number of per day extents of range queries looked up in the results cache.",
+	})
+	rc.hits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "thanos_query_api_range_cache_extents_hit_total",
+		Help: "Total number of per day extents of range queries fully served from the results cache.",
+	})
+	if reg != nil {
+		reg.MustRegister(rc.requests, rc.hits)
+	}
+	return rc
+}
+
+// splitByInterval splits the step grid of the given range into ranges not crossing boundaries of the given interval.
+func splitByInterval(start, end, step, interval int64) (res [][2]int64) {
+	for s := start; s <= end; {
+		intervalEnd := (floorDiv(s, interval) + 1) * interval
+		e := s + (intervalEnd-1-s)/step*step
+		if e > end {
+			e = s + (end-s)/step*step
+		}
+		res = append(res, [2]int64{s, e})
+		s = e + step
+	}
+	return res
+}
+
+func floorDiv(a, b int64) int64 {
+	if a < 0 && a%b != 0 {
+		return a/b - 1
+	}
+	return a / b
+}
+
+// eval returns the result of the range query using cached extents where possible.
+func (c *rangeQueryCache) eval(ctx context.Context, key rangeQueryKey, start, end int64, evalFn rangeEvalFunc) (promql.Matrix, *apiError) {
+	ranges := splitByInterval(start, end, key.step, rangeCacheSplitInterval)
+
+	keys := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		keys = append(keys, key.cacheKey(r[0], floorDiv(r[0], rangeCacheSplitInterval)))
+	}
+	found := c.cache.Fetch(ctx, keys)
+
+	var (
+		results  []promql.Matrix
+		toStore  = map[string][]byte{}
+		maxCache = timestamp.FromTime(c.now().Add(-c.maxFreshness))
+	)
+	for i, r := range ranges {
+		c.requests.Inc()
+
+		var (
+			cached    extent
+			hasCached bool
+		)
+		if b, ok := found[keys[i]]; ok {
+			if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&cached); err != nil {
+				level.Warn(c.logger).Log("msg", "failed to decode cached range query extent", "err", err)
+			} else {
+				hasCached = true
+			}
+		}
+		if hasCached && cached.covers(r[0], r[1]) {
+			c.hits.Inc()
+			results = append(results, sliceMatrix(cached.Matrix, r[0], r[1]))
+			continue
+		}
+
+		parts := []promql.Matrix{}
+		gaps := [][2]int64{r}
+		if hasCached && cached.Start <= r[1] && cached.End >= r[0] {
+			parts = append(parts, sliceMatrix(cached.Matrix, r[0], r[1]))
+			gaps = gaps[:0]
+			if r[0] < cached.Start {
+				gaps = append(gaps, [2]int64{r[0], cached.Start - key.step})
+			}
+			if r[1] > cached.End {
+				gaps = append(gaps, [2]int64{cached.End + key.step, r[1]})
+			}
+		}
+
+		partial := false
+		for _, g := range gaps {
+			m, p, apiErr := evalFn(ctx, g[0], g[1])
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			parts = append(parts, m)
+			partial = partial || p
+		}
+		res := mergeMatrices(parts...)
+		results = append(results, res)
+
+		if partial {
+			continue
+		}
+		ext := extent{Start: r[0], End: r[1], Matrix: res}
+		// Extend the cached extent if the new one overlaps or is adjacent to it.
+		if hasCached && cached.Start <= r[1]+key.step && cached.End >= r[0]-key.step {
+			ext = extent{
+				Start:  minInt64(cached.Start, r[0]),
+				End:    maxInt64(cached.End, r[1]),
+				Matrix: mergeMatrices(cached.Matrix, res),
+			}
+		}
+		if ext.End > maxCache {
+			if maxCache < ext.Start {
+				continue
+			}
+			ext.End = ext.Start + (maxCache-ext.Start)/key.step*key.step
+			ext.Matrix = sliceMatrix(ext.Matrix, ext.Start, ext.End)
+		}
+		if hasCached && ext.Start == cached.Start && ext.End == cached.End {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(ext); err != nil {
+			level.Warn(c.logger).Log("msg", "failed to encode range query extent", "err", err)
+			continue
+		}
+		toStore[keys[i]] = buf.Bytes()
+	}
+	if len(toStore) > 0 {
+		c.cache.Store(ctx, toStore)
+	}
+	return mergeMatrices(results...), nil
+}
+
+// sliceMatrix returns series of the matrix with points within [mint, maxt]. Series without any points are dropped.
+func sliceMatrix(m promql.Matrix, mint, maxt int64) promql.Matrix {
+	res := make(promql.Matrix, 0, len(m))
+	for _, s := range m {
+		i := sort.Search(len(s.Points), func(i int) bool { return s.Points[i].T >= mint })
+		j := sort.Search(len(s.Points), func(j int) bool { return s.Points[j].T > maxt })
+		if i >= j {
+			continue
+		}
+		res = append(res, promql.Series{Metric: s.Metric, Points: s.Points[i:j]})
+	}
+	return res
+}
+
+// mergeMatrices merges series with the same labels across all given matrices. Points are sorted by timestamp
+// and deduplicated. The result is sorted by series labels.
+func mergeMatrices(ms ...promql.Matrix) promql.Matrix {
+	if len(ms) == 1 {
+		return ms[0]
+	}
+	var (
+		res    = promql.Matrix{}
+		byLset = map[string]int{}
+	)
+	for _, m := range ms {
+		for _, s := range m {
+			k := s.Metric.String()
+			i, ok := byLset[k]
+			if !ok {
+				byLset[k] = len(res)
+				res = append(res, promql.Series{Metric: s.Metric, Points: append([]promql.Point(nil), s.Points...)})
+				continue
+			}
+			res[i].Points = append(res[i].Points, s.Points...)
+		}
+	}
+	for i := range res {
+		pts := res[i].Points
+		sort.SliceStable(pts, func(a, b int) bool { return pts[a].T < pts[b].T })
+
+		dedup := pts[:0]
+		for j, p := range pts {
+			if j > 0 && p.T == pts[j-1].T {
+				continue
+			}
+			dedup = append(dedup, p)
+		}
+		res[i].Points = dedup
+	}
+	sort.Slice(res, func(i, j int) bool { return labels.Compare(res[i].Metric, res[j].Metric) < 0 })
+	return res
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
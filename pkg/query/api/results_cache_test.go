@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/improbable-eng/thanos/pkg/cache"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+)
+
+func TestSplitByInterval(t *testing.T) {
+	day := rangeCacheSplitInterval
+	for _, tcase := range []struct {
+		start, end, step int64
+		expected         [][2]int64
+	}{
+		{start: 0, end: 0, step: 10, expected: [][2]int64{{0, 0}}},
+		{start: 5, end: 100, step: 10, expected: [][2]int64{{5, 95}}},
+		{start: day - 15, end: day + 20, step: 10, expected: [][2]int64{{day - 15, day - 5}, {day + 5, day + 15}}},
+		{start: day - 10, end: day + 20, step: 10, expected: [][2]int64{{day - 10, day - 10}, {day, day + 20}}},
+		{start: 0, end: 3 * day, step: 2 * day, expected: [][2]int64{{0, 0}, {2 * day, 2 * day}}},
+		{start: -15, end: 15, step: 10, expected: [][2]int64{{-15, -5}, {5, 15}}},
+	} {
+		testutil.Equals(t, tcase.expected, splitByInterval(tcase.start, tcase.end, tcase.step, day))
+	}
+}
+
+func TestMergeMatrices(t *testing.T) {
+	a := labels.FromStrings("a", "1")
+	b := labels.FromStrings("a", "2")
+
+	res := mergeMatrices(
+		promql.Matrix{
+			{Metric: b, Points: []promql.Point{{T: 3, V: 3}}},
+			{Metric: a, Points: []promql.Point{{T: 1, V: 1}, {T: 2, V: 2}}},
+		},
+		promql.Matrix{
+			{Metric: a, Points: []promql.Point{{T: 2, V: 2}, {T: 3, V: 3}}},
+		},
+		promql.Matrix{
+			{Metric: b, Points: []promql.Point{{T: 1, V: 1}}},
+		},
+	)
+	testutil.Equals(t, promql.Matrix{
+		{Metric: a, Points: []promql.Point{{T: 1, V: 1}, {T: 2, V: 2}, {T: 3, V: 3}}},
+		{Metric: b, Points: []promql.Point{{T: 1, V: 1}, {T: 3, V: 3}}},
+	}, res)
+}
+
+type evalCall struct {
+	start, end int64
+}
+
+func TestRangeQueryCache_Eval(t *testing.T) {
+	const step = int64(time.Hour / time.Millisecond)
+	day := rangeCacheSplitInterval
+
+	c, err := cache.NewInMemoryCache(nil, "test", 1e6)
+	testutil.Ok(t, err)
+
+	rc := newRangeQueryCache(log.NewNopLogger(), nil, c, 10*time.Minute)
+	rc.now = func() time.Time { return time.Unix(0, 0).Add(3 * 24 * time.Hour) }
+
+	var (
+		calls   []evalCall
+		partial bool
+	)
+	expected := func(start, end int64) promql.Matrix {
+		s := promql.Series{Metric: labels.FromStrings("a", "1")}
+		for ts := start; ts <= end; ts += step {
+			s.Points = append(s.Points, promql.Point{T: ts, V: float64(ts)})
+		}
+		return promql.Matrix{s}
+	}
+	evalFn := func(_ context.Context, start, end int64) (promql.Matrix, bool, *apiError) {
+		calls = append(calls, evalCall{start: start, end: end})
+		return expected(start, end), partial, nil
+	}
+	key := rangeQueryKey{query: "up", step: step}
+
+	// Nothing is cached yet, each day is evaluated separately.
+	res, apiErr := rc.eval(context.Background(), key, day-2*step, day+2*step, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, []evalCall{{day - 2*step, day - step}, {day, day + 2*step}}, calls)
+	testutil.Equals(t, expected(day-2*step, day+2*step), res)
+
+	// Fully cached.
+	calls = calls[:0]
+	res, apiErr = rc.eval(context.Background(), key, day-step, day+step, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, 0, len(calls))
+	testutil.Equals(t, expected(day-step, day+step), res)
+
+	// Only missing parts around the cached extent are evaluated.
+	res, apiErr = rc.eval(context.Background(), key, day-4*step, day+4*step, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, []evalCall{{day - 4*step, day - 3*step}, {day + 3*step, day + 4*step}}, calls)
+	testutil.Equals(t, expected(day-4*step, day+4*step), res)
+
+	// Extents were extended in the cache.
+	calls = calls[:0]
+	_, apiErr = rc.eval(context.Background(), key, day-4*step, day+4*step, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, 0, len(calls))
+
+	// Different step grid does not reuse cached results.
+	_, apiErr = rc.eval(context.Background(), key, day+1, day+step+1, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, []evalCall{{day + 1, day + step + 1}}, calls)
+
+	// Partial results are not cached.
+	calls, partial = calls[:0], true
+	_, apiErr = rc.eval(context.Background(), key, 2*day, 2*day+step, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	partial = false
+	_, apiErr = rc.eval(context.Background(), key, 2*day, 2*day+step, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, []evalCall{{2 * day, 2*day + step}, {2 * day, 2*day + step}}, calls)
+
+	// Results more recent than max freshness are not cached.
+	calls = calls[:0]
+	now := 3 * day
+	_, apiErr = rc.eval(context.Background(), key, now-2*step, now, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	res, apiErr = rc.eval(context.Background(), key, now-2*step, now, evalFn)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, []evalCall{{now - 2*step, now - step}, {now, now}, {now, now}}, calls)
+	testutil.Equals(t, expected(now-2*step, now), res)
+}
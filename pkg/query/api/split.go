@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"golang.org/x/sync/errgroup"
+)
+
+// rangeEvalTimeFunc evaluates the range query with the original step for the given time range.
+type rangeEvalTimeFunc func(ctx context.Context, start, end time.Time) (promql.Value, *apiError)
+
+// evalSplitByInterval evaluates the range query split into sub-ranges aligned to the given interval and merges their
+// results. Each sub-range is a separate PromQL query taking a slot of the engine's concurrent queries, so at most
+// maxConcurrent sub-ranges are evaluated at the same time to leave slots to other queries.
+func evalSplitByInterval(ctx context.Context, start, end time.Time, step, interval time.Duration, maxConcurrent int, eval rangeEvalTimeFunc) (promql.Value, *apiError) {
+	// Splitting needs timestamps of the step grid to be exact in milliseconds.
+	if step%time.Millisecond != 0 || interval < time.Millisecond {
+		return eval(ctx, start, end)
+	}
+	mint := timestamp.FromTime(start)
+	ranges := splitByInterval(mint, timestamp.FromTime(end), int64(step/time.Millisecond), int64(interval/time.Millisecond))
+	if len(ranges) < 2 {
+		return eval(ctx, start, end)
+	}
+
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	var (
+		g, gctx = errgroup.WithContext(ctx)
+		results = make([]promql.Matrix, len(ranges))
+		sem     = make(chan struct{}, maxConcurrent)
+	)
+	for i, r := range ranges {
+		select {
+		case sem <- struct{}{}:
+		case <-gctx.Done():
+		}
+		if gctx.Err() != nil {
+			break
+		}
+
+		i := i
+		// Keep the original start to not lose its sub-millisecond precision.
+		s := start.Add(time.Duration(r[0]-mint) * time.Millisecond)
+		e := start.Add(time.Duration(r[1]-mint) * time.Millisecond)
+
+		g.Go(func() error {
+			defer func() { <-sem }()
+
+			v, apiErr := eval(gctx, s, e)
+			if apiErr != nil {
+				return apiErr
+			}
+			m, ok := v.(promql.Matrix)
+			if !ok {
+				return &apiError{errorInternal, errors.Errorf("unexpected range query result type %s", v.Type())}
+			}
+			results[i] = m
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err.(*apiError)
+	}
+	// Not all sub-ranges were evaluated if the query was cancelled in the meantime.
+	if err := ctx.Err(); err != nil {
+		if err == context.DeadlineExceeded {
+			return nil, &apiError{errorTimeout, err}
+		}
+		return nil, &apiError{errorCanceled, err}
+	}
+	return mergeMatrices(results...), nil
+}
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/prometheus/prometheus/promql"
+)
+
+func TestEvalSplitByInterval(t *testing.T) {
+	const step = time.Hour
+	start := time.Unix(0, 0).Add(-2 * step)
+	end := time.Unix(0, 0).Add(48*time.Hour + 2*step)
+
+	var (
+		mtx   sync.Mutex
+		calls []evalCall
+	)
+	expected := func(start, end time.Time) promql.Matrix {
+		s := promql.Series{Metric: labels.FromStrings("a", "1")}
+		for ts := start; !ts.After(end); ts = ts.Add(step) {
+			s.Points = append(s.Points, promql.Point{T: timestamp.FromTime(ts), V: float64(ts.Unix())})
+		}
+		return promql.Matrix{s}
+	}
+	eval := func(_ context.Context, start, end time.Time) (promql.Value, *apiError) {
+		mtx.Lock()
+		calls = append(calls, evalCall{start: timestamp.FromTime(start), end: timestamp.FromTime(end)})
+		mtx.Unlock()
+		return expected(start, end), nil
+	}
+
+	res, apiErr := evalSplitByInterval(context.Background(), start, end, step, 24*time.Hour, 4, eval)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, expected(start, end), res)
+
+	sort.Slice(calls, func(i, j int) bool { return calls[i].start < calls[j].start })
+	h := int64(step / time.Millisecond)
+	testutil.Equals(t, []evalCall{
+		{-2 * h, -h},
+		{0, 23 * h},
+		{24 * h, 47 * h},
+		{48 * h, 50 * h},
+	}, calls)
+
+	// Range within a single interval is not split.
+	calls = calls[:0]
+	_, apiErr = evalSplitByInterval(context.Background(), start, start.Add(step), step, 24*time.Hour, 4, eval)
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Equals(t, []evalCall{{-2 * h, -h}}, calls)
+
+	// Any failed sub-query fails the whole query.
+	_, apiErr = evalSplitByInterval(context.Background(), start, end, step, 24*time.Hour, 4, func(ctx context.Context, start, end time.Time) (promql.Value, *apiError) {
+		if start.After(time.Unix(0, 0)) {
+			return nil, &apiError{errorExec, errors.New("failed")}
+		}
+		return eval(ctx, start, end)
+	})
+	testutil.Assert(t, apiErr != nil, "expected error")
+	testutil.Equals(t, errorType(errorExec), apiErr.typ)
+
+	// Number of sub-queries evaluated at the same time is limited.
+	var running, maxRunning int
+	_, apiErr = evalSplitByInterval(context.Background(), start, start.Add(30*24*time.Hour), step, time.Hour, 3, func(ctx context.Context, start, end time.Time) (promql.Value, *apiError) {
+		mtx.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mtx.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mtx.Lock()
+		running--
+		mtx.Unlock()
+		return expected(start, end), nil
+	})
+	testutil.Assert(t, apiErr == nil, "unexpected error %v", apiErr)
+	testutil.Assert(t, maxRunning <= 3, "too many concurrent sub-queries %d", maxRunning)
+
+	// Cancelled query does not return partial result.
+	ctx, cancel := context.WithCancel(context.Background())
+	_, apiErr = evalSplitByInterval(ctx, start, start.Add(30*24*time.Hour), step, time.Hour, 1, func(_ context.Context, start, end time.Time) (promql.Value, *apiError) {
+		cancel()
+		return expected(start, end), nil
+	})
+	testutil.Assert(t, apiErr != nil, "expected error")
+	testutil.Equals(t, errorType(errorCanceled), apiErr.typ)
+}
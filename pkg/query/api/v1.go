@@ -29,14 +29,18 @@ import (
 	"github.com/NYTimes/gziphandler"
 
 	"github.com/go-kit/kit/log"
+	"github.com/improbable-eng/thanos/pkg/cache"
 	"github.com/improbable-eng/thanos/pkg/query"
 	"github.com/improbable-eng/thanos/pkg/runutil"
+	"github.com/improbable-eng/thanos/pkg/strutil"
+	"github.com/improbable-eng/thanos/pkg/tenancy"
 	"github.com/improbable-eng/thanos/pkg/tracing"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/common/route"
+	"github.com/prometheus/common/version"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/timestamp"
 	"github.com/prometheus/prometheus/promql"
@@ -104,6 +108,13 @@ type API struct {
 	instantQueryDuration   prometheus.Histogram
 	rangeQueryDuration     prometheus.Histogram
 	enableAutodownsampling bool
+	enablePartialResponse  bool
+	rangeCache             *rangeQueryCache
+	querySplitInterval     time.Duration
+	querySplitConcurrency  int
+	tenantHeader           string
+	storeStatus            func() []query.StoreStatus
+	flagsMap               map[string]string
 	now                    func() time.Time
 }
 
@@ -114,6 +125,14 @@ func NewAPI(
 	qe *promql.Engine,
 	c query.QueryableCreator,
 	enableAutodownsampling bool,
+	enablePartialResponse bool,
+	rangeCache cache.Cache,
+	rangeCacheMaxFreshness time.Duration,
+	querySplitInterval time.Duration,
+	querySplitConcurrency int,
+	tenantHeader string,
+	storeStatus func() []query.StoreStatus,
+	flagsMap map[string]string,
 ) *API {
 	instantQueryDuration := prometheus.NewHistogram(prometheus.HistogramOpts{
 		Name: "thanos_query_api_instant_query_duration_seconds",
@@ -134,15 +153,25 @@ func NewAPI(
 		instantQueryDuration,
 		rangeQueryDuration,
 	)
-	return &API{
+	api := &API{
 		logger:                 logger,
 		queryEngine:            qe,
 		queryableCreate:        c,
 		instantQueryDuration:   instantQueryDuration,
 		rangeQueryDuration:     rangeQueryDuration,
 		enableAutodownsampling: enableAutodownsampling,
+		enablePartialResponse:  enablePartialResponse,
+		querySplitInterval:     querySplitInterval,
+		querySplitConcurrency:  querySplitConcurrency,
+		tenantHeader:           tenantHeader,
+		storeStatus:            storeStatus,
+		flagsMap:               flagsMap,
 		now:                    time.Now,
 	}
+	if rangeCache != nil {
+		api.rangeCache = newRangeQueryCache(logger, reg, rangeCache, rangeCacheMaxFreshness)
+	}
+	return api
 }
 
 // Register the API's endpoints in the given router.
@@ -150,6 +179,9 @@ func (api *API) Register(r *route.Router, tracer opentracing.Tracer, logger log.
 	instr := func(name string, f apiFunc) http.HandlerFunc {
 		hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			setCORS(w)
+			if tenant := r.Header.Get(api.tenantHeader); tenant != "" {
+				r = r.WithContext(tenancy.ContextWithTenant(r.Context(), tenant))
+			}
 			if data, warnings, err := f(r); err != nil {
 				respondError(w, err, data)
 			} else if data != nil {
@@ -167,8 +199,13 @@ func (api *API) Register(r *route.Router, tracer opentracing.Tracer, logger log.
 	r.Get("/query_range", instr("query_range", api.queryRange))
 
 	r.Get("/label/:name/values", instr("label_values", api.labelValues))
+	r.Get("/labels", instr("label_names", api.labelNames))
 
 	r.Get("/series", instr("series", api.series))
+
+	r.Get("/stores", instr("stores", api.stores))
+	r.Get("/status/flags", instr("status_flags", api.serveFlags))
+	r.Get("/status/buildinfo", instr("status_buildinfo", api.serveBuildInfo))
 }
 
 type queryData struct {
@@ -225,12 +262,17 @@ func (api *API) query(r *http.Request) (interface{}, []error, *apiError) {
 		}
 	}
 
+	enablePartialResponse, apiErr := api.parsePartialResponseParam(r)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
 	// We are starting promQL tracing span here, because we have no control over promQL code.
 	span, ctx := tracing.StartSpan(r.Context(), "promql_instant_query")
 	defer span.Finish()
 
 	begin := api.now()
-	qry, err := api.queryEngine.NewInstantQuery(api.queryableCreate(enableDeduplication, 0, partialErrReporter), r.FormValue("query"), ts)
+	qry, err := api.queryEngine.NewInstantQuery(api.queryableCreate(enableDeduplication, 0, enablePartialResponse, partialErrReporter), r.FormValue("query"), ts)
 	if err != nil {
 		return nil, nil, &apiError{errorBadData, err}
 	}
@@ -334,40 +376,147 @@ func (api *API) queryRange(r *http.Request) (interface{}, []error, *apiError) {
 		}
 	}
 
+	enablePartialResponse, apiErr := api.parsePartialResponseParam(r)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
 	// We are starting promQL tracing span here, because we have no control over promQL code.
 	span, ctx := tracing.StartSpan(r.Context(), "promql_range_query")
 	defer span.Finish()
 
 	begin := api.now()
-	qry, err := api.queryEngine.NewRangeQuery(
-		api.queryableCreate(enableDeduplication, maxSourceResolution, partialErrReporter),
-		r.FormValue("query"),
-		start,
-		end,
-		step,
-	)
-	if err != nil {
-		return nil, nil, &apiError{errorBadData, err}
+	var eval rangeEvalTimeFunc = func(ctx context.Context, start, end time.Time) (promql.Value, *apiError) {
+		qry, err := api.queryEngine.NewRangeQuery(
+			api.queryableCreate(enableDeduplication, maxSourceResolution, enablePartialResponse, partialErrReporter),
+			r.FormValue("query"),
+			start,
+			end,
+			step,
+		)
+		if err != nil {
+			return nil, &apiError{errorBadData, err}
+		}
+
+		res := qry.Exec(ctx)
+		if res.Err != nil {
+			switch res.Err.(type) {
+			case promql.ErrQueryCanceled:
+				return nil, &apiError{errorCanceled, res.Err}
+			case promql.ErrQueryTimeout:
+				return nil, &apiError{errorTimeout, res.Err}
+			}
+			return nil, &apiError{errorExec, res.Err}
+		}
+		return res.Value, nil
+	}
+	if api.querySplitInterval > 0 {
+		evalRange := eval
+		eval = func(ctx context.Context, start, end time.Time) (promql.Value, *apiError) {
+			return evalSplitByInterval(ctx, start, end, step, api.querySplitInterval, api.querySplitConcurrency, evalRange)
+		}
 	}
 
-	res := qry.Exec(ctx)
-	if res.Err != nil {
-		switch res.Err.(type) {
-		case promql.ErrQueryCanceled:
-			return nil, nil, &apiError{errorCanceled, res.Err}
-		case promql.ErrQueryTimeout:
-			return nil, nil, &apiError{errorTimeout, res.Err}
+	var val promql.Value
+	// Cached results are reusable only for steps aligned to milliseconds, as timestamps are stored as such.
+	if api.rangeCache != nil && step%time.Millisecond == 0 {
+		tenant, _ := tenancy.FromContext(r.Context())
+		key := rangeQueryKey{
+			tenant:              tenant,
+			query:               r.FormValue("query"),
+			step:                int64(step / time.Millisecond),
+			dedup:               enableDeduplication,
+			partialResponse:     enablePartialResponse,
+			maxSourceResolution: maxSourceResolution,
 		}
-		return nil, nil, &apiError{errorExec, res.Err}
+		val, apiErr = api.rangeCache.eval(ctx, key, timestamp.FromTime(start), timestamp.FromTime(end),
+			func(ctx context.Context, mint, maxt int64) (promql.Matrix, bool, *apiError) {
+				warnmtx.Lock()
+				warnsBefore := len(warnings)
+				warnmtx.Unlock()
+
+				v, apiErr := eval(ctx, timestamp.Time(mint), timestamp.Time(maxt))
+				if apiErr != nil {
+					return nil, false, apiErr
+				}
+				m, ok := v.(promql.Matrix)
+				if !ok {
+					return nil, false, &apiError{errorInternal, errors.Errorf("unexpected range query result type %s", v.Type())}
+				}
+
+				warnmtx.Lock()
+				defer warnmtx.Unlock()
+				return m, len(warnings) > warnsBefore, nil
+			},
+		)
+	} else {
+		val, apiErr = eval(ctx, start, end)
+	}
+	if apiErr != nil {
+		return nil, nil, apiErr
 	}
 	api.rangeQueryDuration.Observe(time.Since(begin).Seconds())
 
 	return &queryData{
-		ResultType: res.Value.Type(),
-		Result:     res.Value,
+		ResultType: val.Type(),
+		Result:     val,
 	}, warnings, nil
 }
 
+// labelsQuerier is implemented by queriers able to list all label names and to scope label lookups by matchers.
+// Prometheus storage.Querier does not expose it yet.
+type labelsQuerier interface {
+	LabelNames(matchers ...*labels.Matcher) ([]string, error)
+	LabelValuesWithMatchers(name string, matchers ...*labels.Matcher) ([]string, error)
+}
+
+// parseLabelsParams parses optional start, end and match[] parameters of label names and values requests.
+// Missing start or end result in the time range unrestricted on that side.
+func parseLabelsParams(r *http.Request) (mint int64, maxt int64, matcherSets [][]*labels.Matcher, apiErr *apiError) {
+	if err := r.ParseForm(); err != nil {
+		return 0, 0, nil, &apiError{errorInternal, errors.Wrap(err, "parse form")}
+	}
+
+	mint, maxt = math.MinInt64, math.MaxInt64
+	if t := r.FormValue("start"); t != "" {
+		start, err := parseTime(t)
+		if err != nil {
+			return 0, 0, nil, &apiError{errorBadData, err}
+		}
+		mint = timestamp.FromTime(start)
+	}
+	if t := r.FormValue("end"); t != "" {
+		end, err := parseTime(t)
+		if err != nil {
+			return 0, 0, nil, &apiError{errorBadData, err}
+		}
+		maxt = timestamp.FromTime(end)
+	}
+
+	for _, s := range r.Form["match[]"] {
+		matchers, err := promql.ParseMetricSelector(s)
+		if err != nil {
+			return 0, 0, nil, &apiError{errorBadData, err}
+		}
+		matcherSets = append(matcherSets, matchers)
+	}
+	return mint, maxt, matcherSets, nil
+}
+
+// parsePartialResponseParam returns whether partial response is enabled for the request. It defaults to
+// the API configuration if no partial_response parameter is given.
+func (api *API) parsePartialResponseParam(r *http.Request) (bool, *apiError) {
+	enablePartialResponse := api.enablePartialResponse
+	if val := r.FormValue("partial_response"); val != "" {
+		var err error
+		enablePartialResponse, err = strconv.ParseBool(val)
+		if err != nil {
+			return false, &apiError{errorBadData, errors.Wrap(err, "'partial_response' parameter")}
+		}
+	}
+	return enablePartialResponse, nil
+}
+
 func (api *API) labelValues(r *http.Request) (interface{}, []error, *apiError) {
 	ctx := r.Context()
 	name := route.Param(ctx, "name")
@@ -376,6 +525,11 @@ func (api *API) labelValues(r *http.Request) (interface{}, []error, *apiError) {
 		return nil, nil, &apiError{errorBadData, fmt.Errorf("invalid label name: %q", name)}
 	}
 
+	mint, maxt, matcherSets, apiErr := parseLabelsParams(r)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
 	var (
 		warnmtx  sync.Mutex
 		warnings []error
@@ -386,20 +540,84 @@ func (api *API) labelValues(r *http.Request) (interface{}, []error, *apiError) {
 		warnmtx.Unlock()
 	}
 
-	q, err := api.queryableCreate(true, 0, partialErrReporter).Querier(ctx, math.MinInt64, math.MaxInt64)
+	q, err := api.queryableCreate(true, 0, api.enablePartialResponse, partialErrReporter).Querier(ctx, mint, maxt)
 	if err != nil {
 		return nil, nil, &apiError{errorExec, err}
 	}
 	defer runutil.CloseWithLogOnErr(api.logger, q, "queryable labelValues")
 
-	// TODO(fabxc): add back request context.
+	if len(matcherSets) == 0 {
+		vals, err := q.LabelValues(name)
+		if err != nil {
+			return nil, nil, &apiError{errorExec, err}
+		}
+		return vals, warnings, nil
+	}
+
+	lq, ok := q.(labelsQuerier)
+	if !ok {
+		return nil, nil, &apiError{errorInternal, errors.New("querier does not support label values matchers")}
+	}
 
-	vals, err := q.LabelValues(name)
+	var sets [][]string
+	for _, ms := range matcherSets {
+		vals, err := lq.LabelValuesWithMatchers(name, ms...)
+		if err != nil {
+			return nil, nil, &apiError{errorExec, err}
+		}
+		sets = append(sets, vals)
+	}
+
+	return strutil.MergeUnsortedSlices(sets...), warnings, nil
+}
+
+func (api *API) labelNames(r *http.Request) (interface{}, []error, *apiError) {
+	ctx := r.Context()
+
+	mint, maxt, matcherSets, apiErr := parseLabelsParams(r)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	var (
+		warnmtx  sync.Mutex
+		warnings []error
+	)
+	partialErrReporter := func(err error) {
+		warnmtx.Lock()
+		warnings = append(warnings, err)
+		warnmtx.Unlock()
+	}
+
+	q, err := api.queryableCreate(true, 0, api.enablePartialResponse, partialErrReporter).Querier(ctx, mint, maxt)
 	if err != nil {
 		return nil, nil, &apiError{errorExec, err}
 	}
+	defer runutil.CloseWithLogOnErr(api.logger, q, "queryable labelNames")
 
-	return vals, warnings, nil
+	lq, ok := q.(labelsQuerier)
+	if !ok {
+		return nil, nil, &apiError{errorInternal, errors.New("querier does not support listing label names")}
+	}
+
+	if len(matcherSets) == 0 {
+		names, err := lq.LabelNames()
+		if err != nil {
+			return nil, nil, &apiError{errorExec, err}
+		}
+		return names, warnings, nil
+	}
+
+	var sets [][]string
+	for _, ms := range matcherSets {
+		names, err := lq.LabelNames(ms...)
+		if err != nil {
+			return nil, nil, &apiError{errorExec, err}
+		}
+		sets = append(sets, names)
+	}
+
+	return strutil.MergeUnsortedSlices(sets...), warnings, nil
 }
 
 var (
@@ -467,7 +685,7 @@ func (api *API) series(r *http.Request) (interface{}, []error, *apiError) {
 		}
 	}
 
-	q, err := api.queryableCreate(enableDeduplication, 0, partialErrReporter).Querier(r.Context(), timestamp.FromTime(start), timestamp.FromTime(end))
+	q, err := api.queryableCreate(enableDeduplication, 0, api.enablePartialResponse, partialErrReporter).Querier(r.Context(), timestamp.FromTime(start), timestamp.FromTime(end))
 	if err != nil {
 		return nil, nil, &apiError{errorExec, err}
 	}
@@ -475,7 +693,16 @@ func (api *API) series(r *http.Request) (interface{}, []error, *apiError) {
 
 	var sets []storage.SeriesSet
 	for _, mset := range matcherSets {
-		s, err := q.Select(&storage.SelectParams{}, mset...)
+		var (
+			s   storage.SeriesSet
+			err error
+		)
+		// Only label sets are returned, so stores can skip reading chunks.
+		if ls, ok := q.(query.LabelSetsSelector); ok {
+			s, err = ls.SelectLabelSets(mset...)
+		} else {
+			s, err = q.Select(&storage.SelectParams{}, mset...)
+		}
 		if err != nil {
 			return nil, nil, &apiError{errorExec, err}
 		}
@@ -494,6 +721,70 @@ func (api *API) series(r *http.Request) (interface{}, []error, *apiError) {
 	return metrics, warnings, nil
 }
 
+type storeData struct {
+	Name      string        `json:"name"`
+	Labels    labels.Labels `json:"labels"`
+	MinTime   int64         `json:"minTime"`
+	MaxTime   int64         `json:"maxTime"`
+	LastCheck time.Time     `json:"lastCheck"`
+	LastError string        `json:"lastError"`
+	Health    string        `json:"health"`
+}
+
+func (api *API) stores(r *http.Request) (interface{}, []error, *apiError) {
+	stores := []storeData{}
+	if api.storeStatus == nil {
+		return stores, nil, nil
+	}
+	for _, s := range api.storeStatus() {
+		lset := make(labels.Labels, 0, len(s.Labels))
+		for _, l := range s.Labels {
+			lset = append(lset, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		st := storeData{
+			Name:      s.Name,
+			Labels:    lset,
+			MinTime:   s.MinTime,
+			MaxTime:   s.MaxTime,
+			LastCheck: s.LastCheck,
+			Health:    "up",
+		}
+		if s.LastError != nil {
+			st.LastError = s.LastError.Error()
+			st.Health = "down"
+		}
+		stores = append(stores, st)
+	}
+	return stores, nil, nil
+}
+
+func (api *API) serveFlags(r *http.Request) (interface{}, []error, *apiError) {
+	if api.flagsMap == nil {
+		return map[string]string{}, nil, nil
+	}
+	return api.flagsMap, nil, nil
+}
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	BuildUser string `json:"buildUser"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+func (api *API) serveBuildInfo(r *http.Request) (interface{}, []error, *apiError) {
+	return buildInfo{
+		Version:   version.Version,
+		Revision:  version.Revision,
+		Branch:    version.Branch,
+		BuildUser: version.BuildUser,
+		BuildDate: version.BuildDate,
+		GoVersion: version.GoVersion,
+	}, nil, nil
+}
+
 func respond(w http.ResponseWriter, data interface{}, warnings []error) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -31,6 +31,7 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/improbable-eng/thanos/pkg/query"
+	"github.com/improbable-eng/thanos/pkg/store/storepb"
 	"github.com/improbable-eng/thanos/pkg/testutil"
 	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
@@ -41,7 +42,7 @@ import (
 )
 
 func testQueryableCreator(queryable storage.Queryable) query.QueryableCreator {
-	return func(_ bool, _ time.Duration, _ query.PartialErrReporter) storage.Queryable {
+	return func(_ bool, _ time.Duration, _ bool, _ query.PartialErrReporter) storage.Queryable {
 		return queryable
 	}
 }
@@ -71,6 +72,14 @@ func TestEndpoints(t *testing.T) {
 		instantQueryDuration: prometheus.NewHistogram(prometheus.HistogramOpts{}),
 		rangeQueryDuration:   prometheus.NewHistogram(prometheus.HistogramOpts{}),
 
+		storeStatus: func() []query.StoreStatus {
+			return []query.StoreStatus{
+				{Name: "store-0", LastCheck: now, Labels: []storepb.Label{{Name: "a", Value: "1"}}, MinTime: 10, MaxTime: 20},
+				{Name: "store-1", LastCheck: now, LastError: errors.New("unreachable")},
+			}
+		},
+		flagsMap: map[string]string{"query.timeout": "2m"},
+
 		now: func() time.Time { return now },
 	}
 
@@ -147,6 +156,15 @@ func TestEndpoints(t *testing.T) {
 			},
 			errType: errorBadData,
 		},
+		// Bad partial_response parameter.
+		{
+			endpoint: api.query,
+			query: url.Values{
+				"query":            []string{"0.333"},
+				"partial_response": []string{"sdfsf"},
+			},
+			errType: errorBadData,
+		},
 		{
 			endpoint: api.queryRange,
 			query: url.Values{
@@ -261,6 +279,18 @@ func TestEndpoints(t *testing.T) {
 			},
 			errType: errorBadData,
 		},
+		// Bad partial_response parameter.
+		{
+			endpoint: api.queryRange,
+			query: url.Values{
+				"query":            []string{"time()"},
+				"start":            []string{"0"},
+				"end":              []string{"2"},
+				"step":             []string{"1"},
+				"partial_response": []string{"sdfsf-range"},
+			},
+			errType: errorBadData,
+		},
 		{
 			endpoint: api.labelValues,
 			params: map[string]string{
@@ -281,6 +311,20 @@ func TestEndpoints(t *testing.T) {
 				"boo",
 			},
 		},
+		{
+			endpoint: api.labelValues,
+			params: map[string]string{
+				"name": "foo",
+			},
+			query: url.Values{
+				"start": []string{"0"},
+				"end":   []string{"100"},
+			},
+			response: []string{
+				"bar",
+				"boo",
+			},
+		},
 		// Bad name parameter.
 		{
 			endpoint: api.labelValues,
@@ -289,6 +333,28 @@ func TestEndpoints(t *testing.T) {
 			},
 			errType: errorBadData,
 		},
+		// Bad time range parameters.
+		{
+			endpoint: api.labelValues,
+			params: map[string]string{
+				"name": "foo",
+			},
+			query: url.Values{
+				"start": []string{"boo"},
+			},
+			errType: errorBadData,
+		},
+		// Bad match[] parameter.
+		{
+			endpoint: api.labelValues,
+			params: map[string]string{
+				"name": "foo",
+			},
+			query: url.Values{
+				"match[]": []string{`{foo=~"}`},
+			},
+			errType: errorBadData,
+		},
 		{
 			endpoint: api.series,
 			query: url.Values{
@@ -406,6 +472,17 @@ func TestEndpoints(t *testing.T) {
 			},
 			errType: errorBadData,
 		},
+		{
+			endpoint: api.stores,
+			response: []storeData{
+				{Name: "store-0", Labels: labels.FromStrings("a", "1"), MinTime: 10, MaxTime: 20, LastCheck: now, Health: "up"},
+				{Name: "store-1", Labels: labels.Labels{}, LastCheck: now, LastError: "unreachable", Health: "down"},
+			},
+		},
+		{
+			endpoint: api.serveFlags,
+			response: map[string]string{"query.timeout": "2m"},
+		},
 	}
 
 	for _, test := range tests {
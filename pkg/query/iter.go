@@ -292,8 +292,11 @@ func (it *chunkSeriesIterator) Err() error {
 }
 
 type dedupSeriesSet struct {
-	set          storage.SeriesSet
-	replicaLabel string
+	set           storage.SeriesSet
+	replicaLabels map[string]struct{}
+	// isCounter returns true for series whose values are adjusted to stay monotonic when switching
+	// between replicas. No series are adjusted if it is nil.
+	isCounter func(labels.Labels) bool
 
 	replicas []storage.Series
 	lset     labels.Labels
@@ -301,8 +304,8 @@ type dedupSeriesSet struct {
 	ok       bool
 }
 
-func newDedupSeriesSet(set storage.SeriesSet, replicaLabel string) storage.SeriesSet {
-	s := &dedupSeriesSet{set: set, replicaLabel: replicaLabel}
+func newDedupSeriesSet(set storage.SeriesSet, replicaLabels map[string]struct{}, isCounter func(labels.Labels) bool) storage.SeriesSet {
+	s := &dedupSeriesSet{set: set, replicaLabels: replicaLabels, isCounter: isCounter}
 	s.ok = s.set.Next()
 	if s.ok {
 		s.peek = s.set.At()
@@ -315,20 +318,24 @@ func (s *dedupSeriesSet) Next() bool {
 		return false
 	}
 	// Set the label set we are currently gathering to the peek element
-	// without the replica label if it exists.
+	// without the replica labels if they exist.
 	s.lset = s.peekLset()
 	s.replicas = append(s.replicas[:0], s.peek)
 	return s.next()
 }
 
 // peekLset returns the label set of the current peek element stripped from the
-// replica label if it exists
+// replica labels if they exist. Replica labels are expected to be sorted last.
 func (s *dedupSeriesSet) peekLset() labels.Labels {
 	lset := s.peek.Labels()
-	if lset[len(lset)-1].Name != s.replicaLabel {
-		return lset
+	i := len(lset)
+	for i > 0 {
+		if _, ok := s.replicaLabels[lset[i-1].Name]; !ok {
+			break
+		}
+		i--
 	}
-	return lset[:len(lset)-1]
+	return lset[:i]
 }
 
 func (s *dedupSeriesSet) next() bool {
@@ -341,7 +348,7 @@ func (s *dedupSeriesSet) next() bool {
 	s.peek = s.set.At()
 	nextLset := s.peekLset()
 
-	// If the label set modulo the replica label is equal to the current label set
+	// If the label set modulo the replica labels is equal to the current label set
 	// look for more replicas, otherwise a series is complete.
 	if !labels.Equal(s.lset, nextLset) {
 		return true
@@ -358,7 +365,7 @@ func (s *dedupSeriesSet) At() storage.Series {
 	// before advancing.
 	repl := make([]storage.Series, len(s.replicas))
 	copy(repl, s.replicas)
-	return newDedupSeries(s.lset, repl...)
+	return newDedupSeries(s.lset, s.isCounter != nil && s.isCounter(s.lset), repl...)
 }
 
 func (s *dedupSeriesSet) Err() error {
@@ -374,128 +381,191 @@ func (s seriesWithLabels) Labels() labels.Labels { return s.lset }
 
 type dedupSeries struct {
 	lset     labels.Labels
+	counter  bool
 	replicas []storage.Series
 }
 
-func newDedupSeries(lset labels.Labels, replicas ...storage.Series) *dedupSeries {
-	return &dedupSeries{lset: lset, replicas: replicas}
+func newDedupSeries(lset labels.Labels, counter bool, replicas ...storage.Series) *dedupSeries {
+	return &dedupSeries{lset: lset, counter: counter, replicas: replicas}
 }
 
 func (s *dedupSeries) Labels() labels.Labels {
 	return s.lset
 }
 
-func (s *dedupSeries) Iterator() (it storage.SeriesIterator) {
-	it = s.replicas[0].Iterator()
-	for _, o := range s.replicas[1:] {
-		it = newDedupSeriesIterator(it, o.Iterator())
+func (s *dedupSeries) Iterator() storage.SeriesIterator {
+	its := make([]storage.SeriesIterator, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		its = append(its, r.Iterator())
 	}
-	return it
+	if s.counter {
+		return newCounterDedupSeriesIterator(its...)
+	}
+	return newDedupSeriesIterator(its...)
 }
 
+// dedupSeriesIterator merges the samples of any number of replicas of a series. It follows a single
+// replica and only switches to another one once the followed replica ends or has a gap larger than
+// its penalty window. The gap is then filled with samples of the other replicas.
 type dedupSeriesIterator struct {
-	a, b storage.SeriesIterator
-	i    int
+	its []storage.SeriesIterator
+	oks []bool
+	// gapTs holds for each replica the timestamp of its last sample before it was abandoned due to a gap.
+	gapTs []int64
+	// adjusts holds the offset added to the values of each replica of a counter.
+	adjusts []float64
+
+	counter bool
+	cur     int
+	lastT   int64
+	lastV   float64
+	// delta is the sampling interval of the followed replica, zero if unknown yet.
+	delta int64
+}
+
+func newDedupSeriesIterator(its ...storage.SeriesIterator) *dedupSeriesIterator {
+	it := &dedupSeriesIterator{
+		its:     its,
+		oks:     make([]bool, len(its)),
+		gapTs:   make([]int64, len(its)),
+		adjusts: make([]float64, len(its)),
+		cur:     -1,
+		lastT:   math.MinInt64,
+	}
+	for i := range its {
+		it.oks[i] = true
+		it.gapTs[i] = math.MinInt64
+	}
+	return it
+}
 
-	aok, bok   bool
-	lastT      int64
-	penA, penB int64
-	useA       bool
+// newCounterDedupSeriesIterator returns a dedupSeriesIterator for replicas of a counter. Replicas restarted
+// at different times report different values, so whenever the iterator switches to a replica reporting
+// a lower value than the last returned one, it raises the values of that replica by the difference.
+// This keeps the merged counter from appearing to reset on a switch, which rate() would turn into a spike.
+// Resets within a single replica are kept.
+func newCounterDedupSeriesIterator(its ...storage.SeriesIterator) *dedupSeriesIterator {
+	it := newDedupSeriesIterator(its...)
+	it.counter = true
+	return it
 }
 
-func newDedupSeriesIterator(a, b storage.SeriesIterator) *dedupSeriesIterator {
-	return &dedupSeriesIterator{
-		a:     a,
-		b:     b,
-		lastT: math.MinInt64,
-		aok:   true,
-		bok:   true,
+// If we don't know the sampling interval yet, we pick 5000 as a constant for the penalty window, which is
+// based on the knowledge that timestamps are in milliseconds and sampling frequencies typically multiple seconds long.
+const initialPenalty = 5000
+
+// penalty returns the penalty window of the followed replica, which is twice its sampling interval.
+// A gap in the followed replica smaller than the window, e.g. a single missed scrape, does not cause a switch.
+// The interval is only measured between samples of the same replica, so that the gap which caused a switch
+// does not inflate the window.
+func (it *dedupSeriesIterator) penalty() int64 {
+	if it.delta > 0 {
+		return 2 * it.delta
 	}
+	return initialPenalty
 }
 
 func (it *dedupSeriesIterator) Next() bool {
-	// Advance both iterators to at least the next highest timestamp plus the potential penalty.
-	if it.aok {
-		it.aok = it.a.Seek(it.lastT + 1 + it.penA)
-	}
-	if it.bok {
-		it.bok = it.b.Seek(it.lastT + 1 + it.penB)
-	}
-	// Handle basic cases where one iterator is exhausted before the other.
-	if !it.aok {
-		it.useA = false
-		if it.bok {
-			it.lastT, _ = it.b.At()
-			it.penB = 0
+	pen := it.penalty()
+
+	next, nextT := -1, int64(math.MaxInt64)
+	if it.cur != -1 && it.oks[it.cur] {
+		if it.oks[it.cur] = it.its[it.cur].Seek(it.lastT + 1); it.oks[it.cur] {
+			next = it.cur
+			nextT, _ = it.its[it.cur].At()
 		}
-		return it.bok
 	}
-	if !it.bok {
-		it.useA = true
-		it.lastT, _ = it.a.At()
-		it.penA = 0
-		return true
+	if next == -1 || nextT > it.lastT+pen {
+		// The followed replica ended or has a gap. Pick the earliest sample of all replicas following the last
+		// returned one, so no sample within the gap is dropped. The followed replica wins ties.
+		// Samples within half of the sampling interval after the last returned one are skipped, as they are
+		// just copies of it scraped by other replicas, which would increase the overall sample frequency.
+		// This also guards against clock drift and inaccuracies during timestamp assignment.
+		minT := it.lastT + 1 + initialPenalty
+		if it.delta > 0 {
+			minT = it.lastT + 1 + it.delta/2
+		}
+		for i, sit := range it.its {
+			if i == it.cur || !it.oks[i] {
+				continue
+			}
+			if it.oks[i] = sit.Seek(minT); !it.oks[i] {
+				continue
+			}
+			if t, _ := sit.At(); t < nextT {
+				next, nextT = i, t
+			}
+		}
+	}
+	if next == -1 {
+		return false
 	}
-	// General case where both iterators still have data. We pick the one
-	// with the smaller timestamp.
-	// The applied penalty potentially already skipped potential samples already
-	// that would have resulted in exaggerated sampling frequency.
-	ta, _ := it.a.At()
-	tb, _ := it.b.At()
-
-	it.useA = ta <= tb
-
-	// For the series we didn't pick, add a penalty twice as high as the delta of the last two
-	// samples to the next seek against it.
-	// This ensures that we don't pick a sample too close, which would increase the overall
-	// sample frequency. It also guards against clock drift and inaccuracies during
-	// timestamp assignment.
-	// If we don't know a delta yet, we pick 5000 as a constant, which is based on the knowledge
-	// that timestamps are in milliseconds and sampling frequencies typically multiple seconds long.
-	const initialPenality = 5000
-
-	if it.useA {
-		if it.lastT != math.MinInt64 {
-			it.penB = 2 * (ta - it.lastT)
-		} else {
-			it.penB = initialPenality
+	if next == it.cur {
+		it.delta = nextT - it.lastT
+	} else if it.cur != -1 {
+		if it.oks[it.cur] {
+			// The followed replica has a gap.
+			it.gapTs[it.cur] = it.lastT
 		}
-		it.penA = 0
-		it.lastT = ta
-		return true
+		next = it.mostComplete(next, nextT, pen)
+		nextT, _ = it.its[next].At()
 	}
-	if it.lastT != math.MinInt64 {
-		it.penA = 2 * (tb - it.lastT)
-	} else {
-		it.penA = initialPenality
+
+	if it.counter {
+		_, v := it.its[next].At()
+		v += it.adjusts[next]
+		if next != it.cur && it.cur != -1 && v < it.lastV {
+			it.adjusts[next] += it.lastV - v
+			v = it.lastV
+		}
+		if !math.IsNaN(v) {
+			it.lastV = v
+		}
 	}
-	it.penB = 0
-	it.lastT = tb
+	it.cur = next
+	it.lastT = nextT
 	return true
 }
 
-func (it *dedupSeriesIterator) Seek(t int64) bool {
-	for {
-		ts, _ := it.At()
-		if ts > 0 && ts >= t {
-			return true
+// mostComplete returns the replica to switch to, given the replica with the earliest sample.
+// Of the replicas with a sample within half of the penalty window of the earliest one, it prefers
+// the one whose last gap is the longest ago.
+func (it *dedupSeriesIterator) mostComplete(next int, nextT int64, pen int64) int {
+	maxT := nextT + pen/2
+	for i, sit := range it.its {
+		if i == it.cur || !it.oks[i] {
+			continue
 		}
+		if t, _ := sit.At(); t <= maxT && it.gapTs[i] < it.gapTs[next] {
+			next = i
+		}
+	}
+	return next
+}
+
+func (it *dedupSeriesIterator) Seek(t int64) bool {
+	for it.cur == -1 || it.lastT < t {
 		if !it.Next() {
 			return false
 		}
 	}
+	return true
 }
 
 func (it *dedupSeriesIterator) At() (int64, float64) {
-	if it.useA {
-		return it.a.At()
+	t, v := it.its[it.cur].At()
+	// Keep NaN values as is so that staleness markers are preserved.
+	if it.adjusts[it.cur] != 0 && !math.IsNaN(v) {
+		v += it.adjusts[it.cur]
 	}
-	return it.b.At()
+	return t, v
 }
 
 func (it *dedupSeriesIterator) Err() error {
-	if it.a.Err() != nil {
-		return it.a.Err()
+	for _, sit := range it.its {
+		if err := sit.Err(); err != nil {
+			return err
+		}
 	}
-	return it.b.Err()
+	return nil
 }
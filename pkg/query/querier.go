@@ -21,19 +21,23 @@ import (
 type PartialErrReporter func(error)
 
 // QueryableCreator returns implementation of promql.Queryable that fetches data from the proxy store API endpoints.
-// If deduplication is enabled, all data retrieved from it will be deduplicated along the replicaLabel by default.
+// If deduplication is enabled, all data retrieved from it will be deduplicated along all replicaLabels by default.
+// If dedupCounters is enabled, deduplicated counters are adjusted to stay monotonic when switching between replicas.
 // maxSourceResolution controls downsampling resolution that is allowed.
-type QueryableCreator func(deduplicate bool, maxSourceResolution time.Duration, p PartialErrReporter) storage.Queryable
+// If partial response is disabled, any store failure results in a query error instead of partial data reported via p.
+type QueryableCreator func(deduplicate bool, maxSourceResolution time.Duration, partialResponse bool, p PartialErrReporter) storage.Queryable
 
 // NewQueryableCreator creates QueryableCreator.
-func NewQueryableCreator(logger log.Logger, proxy storepb.StoreServer, replicaLabel string) QueryableCreator {
-	return func(deduplicate bool, maxSourceResolution time.Duration, p PartialErrReporter) storage.Queryable {
+func NewQueryableCreator(logger log.Logger, proxy storepb.StoreServer, replicaLabels []string, dedupCounters bool) QueryableCreator {
+	return func(deduplicate bool, maxSourceResolution time.Duration, partialResponse bool, p PartialErrReporter) storage.Queryable {
 		return &queryable{
 			logger:              logger,
-			replicaLabel:        replicaLabel,
+			replicaLabels:       replicaLabels,
+			dedupCounters:       dedupCounters,
 			proxy:               proxy,
 			deduplicate:         deduplicate,
 			maxSourceResolution: maxSourceResolution,
+			partialResponse:     partialResponse,
 			partialErrReport:    p,
 		}
 	}
@@ -41,16 +45,18 @@ func NewQueryableCreator(logger log.Logger, proxy storepb.StoreServer, replicaLa
 
 type queryable struct {
 	logger              log.Logger
-	replicaLabel        string
+	replicaLabels       []string
+	dedupCounters       bool
 	proxy               storepb.StoreServer
 	deduplicate         bool
+	partialResponse     bool
 	partialErrReport    PartialErrReporter
 	maxSourceResolution time.Duration
 }
 
 // Querier returns a new storage querier against the underlying proxy store API.
 func (q *queryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
-	return newQuerier(ctx, q.logger, mint, maxt, q.replicaLabel, q.proxy, q.deduplicate, int64(q.maxSourceResolution/time.Millisecond), q.partialErrReport), nil
+	return newQuerier(ctx, q.logger, mint, maxt, q.replicaLabels, q.dedupCounters, q.proxy, q.deduplicate, int64(q.maxSourceResolution/time.Millisecond), q.partialResponse, q.partialErrReport), nil
 }
 
 type querier struct {
@@ -58,9 +64,11 @@ type querier struct {
 	logger              log.Logger
 	cancel              func()
 	mint, maxt          int64
-	replicaLabel        string
+	replicaLabels       map[string]struct{}
+	dedupCounters       bool
 	proxy               storepb.StoreServer
 	deduplicate         bool
+	partialResponse     bool
 	partialErrReport    PartialErrReporter
 	maxSourceResolution int64
 }
@@ -71,10 +79,12 @@ func newQuerier(
 	ctx context.Context,
 	logger log.Logger,
 	mint, maxt int64,
-	replicaLabel string,
+	replicaLabels []string,
+	dedupCounters bool,
 	proxy storepb.StoreServer,
 	deduplicate bool,
 	maxSourceResolution int64,
+	partialResponse bool,
 	partialErrReport PartialErrReporter,
 ) *querier {
 	if logger == nil {
@@ -83,6 +93,10 @@ func newQuerier(
 	if partialErrReport == nil {
 		partialErrReport = func(error) {}
 	}
+	rl := make(map[string]struct{}, len(replicaLabels))
+	for _, l := range replicaLabels {
+		rl[l] = struct{}{}
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	return &querier{
 		ctx:                 ctx,
@@ -90,16 +104,18 @@ func newQuerier(
 		cancel:              cancel,
 		mint:                mint,
 		maxt:                maxt,
-		replicaLabel:        replicaLabel,
+		replicaLabels:       rl,
+		dedupCounters:       dedupCounters,
 		proxy:               proxy,
 		deduplicate:         deduplicate,
 		maxSourceResolution: maxSourceResolution,
+		partialResponse:     partialResponse,
 		partialErrReport:    partialErrReport,
 	}
 }
 
 func (q *querier) isDedupEnabled() bool {
-	return q.deduplicate && q.replicaLabel != ""
+	return q.deduplicate && len(q.replicaLabels) > 0
 }
 
 type seriesServer struct {
@@ -161,7 +177,21 @@ func aggrsFromFunc(f string) ([]storepb.Aggr, resAggr) {
 	return []storepb.Aggr{storepb.Aggr_COUNT, storepb.Aggr_SUM}, resAggrAvg
 }
 
+// LabelSetsSelector selects series whose label sets are needed only, such as those of the series API.
+type LabelSetsSelector interface {
+	SelectLabelSets(ms ...*labels.Matcher) (storage.SeriesSet, error)
+}
+
 func (q *querier) Select(params *storage.SelectParams, ms ...*labels.Matcher) (storage.SeriesSet, error) {
+	return q.selectFn(params, false, ms...)
+}
+
+// SelectLabelSets returns series matching the matchers without their samples. Stores are asked to skip their chunks.
+func (q *querier) SelectLabelSets(ms ...*labels.Matcher) (storage.SeriesSet, error) {
+	return q.selectFn(&storage.SelectParams{}, true, ms...)
+}
+
+func (q *querier) selectFn(params *storage.SelectParams, skipChunks bool, ms ...*labels.Matcher) (storage.SeriesSet, error) {
 	span, ctx := tracing.StartSpan(q.ctx, "querier_select")
 	defer span.Finish()
 
@@ -174,11 +204,13 @@ func (q *querier) Select(params *storage.SelectParams, ms ...*labels.Matcher) (s
 
 	resp := &seriesServer{ctx: ctx}
 	if err := q.proxy.Series(&storepb.SeriesRequest{
-		MinTime:             q.mint,
-		MaxTime:             q.maxt,
-		Matchers:            sms,
-		MaxResolutionWindow: q.maxSourceResolution,
-		Aggregates:          queryAggrs,
+		MinTime:                 q.mint,
+		MaxTime:                 q.maxt,
+		Matchers:                sms,
+		MaxResolutionWindow:     q.maxSourceResolution,
+		Aggregates:              queryAggrs,
+		PartialResponseDisabled: !q.partialResponse,
+		SkipChunks:              skipChunks,
 	}, resp); err != nil {
 		return nil, errors.Wrap(err, "proxy Series()")
 	}
@@ -199,7 +231,7 @@ func (q *querier) Select(params *storage.SelectParams, ms ...*labels.Matcher) (s
 
 	// TODO(fabxc): this could potentially pushed further down into the store API
 	// to make true streaming possible.
-	sortDedupLabels(resp.seriesSet, q.replicaLabel)
+	sortDedupLabels(resp.seriesSet, q.replicaLabels)
 
 	set := promSeriesSet{
 		mint: q.mint,
@@ -211,36 +243,82 @@ func (q *querier) Select(params *storage.SelectParams, ms ...*labels.Matcher) (s
 	// The merged series set assembles all potentially-overlapping time ranges
 	// of the same series into a single one. The series are ordered so that equal series
 	// from different replicas are sequential. We can now deduplicate those.
-	return newDedupSeriesSet(set, q.replicaLabel), nil
+	var isCounter func(labels.Labels) bool
+	if q.dedupCounters {
+		counterFunc := isCounterFunc(params.Func)
+		isCounter = func(lset labels.Labels) bool {
+			return counterFunc || strings.HasSuffix(lset.Get(labels.MetricName), "_total")
+		}
+	}
+	return newDedupSeriesSet(set, q.replicaLabels, isCounter), nil
+}
+
+// isCounterFunc returns true if the wrapping function of a series selection only
+// applies to counters.
+func isCounterFunc(f string) bool {
+	return f == "rate" || f == "irate" || f == "increase" || f == "resets"
 }
 
 // sortDedupLabels resorts the set so that the same series with different replica
 // labels are coming right after each other.
-func sortDedupLabels(set []storepb.Series, replicaLabel string) {
+func sortDedupLabels(set []storepb.Series, replicaLabels map[string]struct{}) {
 	for _, s := range set {
-		// Move the replica label to the very end.
+		// Move the replica labels to the very end, sorted by name among themselves.
 		sort.Slice(s.Labels, func(i, j int) bool {
-			if s.Labels[i].Name == replicaLabel {
-				return false
-			}
-			if s.Labels[j].Name == replicaLabel {
-				return true
+			_, ri := replicaLabels[s.Labels[i].Name]
+			_, rj := replicaLabels[s.Labels[j].Name]
+			if ri != rj {
+				return rj
 			}
 			return s.Labels[i].Name < s.Labels[j].Name
 		})
 	}
 	// With the re-ordered label sets, re-sorting all series aligns the same series
-	// from different replicas sequentially.
+	// from different replicas sequentially. Label sets are compared without replica labels
+	// first, as replicas of a series may carry different replica labels.
 	sort.Slice(set, func(i, j int) bool {
+		if d := storepb.CompareLabels(
+			withoutReplicaLabels(set[i].Labels, replicaLabels),
+			withoutReplicaLabels(set[j].Labels, replicaLabels),
+		); d != 0 {
+			return d < 0
+		}
 		return storepb.CompareLabels(set[i].Labels, set[j].Labels) < 0
 	})
 }
 
+// withoutReplicaLabels returns the label set without the replica labels sorted to its end.
+func withoutReplicaLabels(lset []storepb.Label, replicaLabels map[string]struct{}) []storepb.Label {
+	i := len(lset)
+	for i > 0 {
+		if _, ok := replicaLabels[lset[i-1].Name]; !ok {
+			break
+		}
+		i--
+	}
+	return lset[:i]
+}
+
 func (q *querier) LabelValues(name string) ([]string, error) {
+	return q.LabelValuesWithMatchers(name)
+}
+
+// LabelValuesWithMatchers returns all values of the given label name within the querier time range. Only stores
+// and blocks that may hold series matching the given matchers are asked.
+func (q *querier) LabelValuesWithMatchers(name string, ms ...*labels.Matcher) ([]string, error) {
 	span, ctx := tracing.StartSpan(q.ctx, "querier_label_values")
 	defer span.Finish()
 
-	resp, err := q.proxy.LabelValues(ctx, &storepb.LabelValuesRequest{Label: name})
+	sms, err := translateMatchers(ms...)
+	if err != nil {
+		return nil, errors.Wrap(err, "convert matchers")
+	}
+
+	resp, err := q.proxy.LabelValues(ctx, &storepb.LabelValuesRequest{
+		Label:     name,
+		Matchers:  sms,
+		TimeRange: &storepb.TimeRange{MinTime: q.mint, MaxTime: q.maxt},
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "proxy LabelValues()")
 	}
@@ -252,6 +330,32 @@ func (q *querier) LabelValues(name string) ([]string, error) {
 	return resp.Values, nil
 }
 
+// LabelNames returns all the unique label names present in the underlying stores within the querier time range.
+// Only stores and blocks that may hold series matching the given matchers are asked.
+func (q *querier) LabelNames(ms ...*labels.Matcher) ([]string, error) {
+	span, ctx := tracing.StartSpan(q.ctx, "querier_label_names")
+	defer span.Finish()
+
+	sms, err := translateMatchers(ms...)
+	if err != nil {
+		return nil, errors.Wrap(err, "convert matchers")
+	}
+
+	resp, err := q.proxy.LabelNames(ctx, &storepb.LabelNamesRequest{
+		Matchers:  sms,
+		TimeRange: &storepb.TimeRange{MinTime: q.mint, MaxTime: q.maxt},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "proxy LabelNames()")
+	}
+
+	for _, w := range resp.Warnings {
+		q.partialErrReport(errors.New(w))
+	}
+
+	return resp.Names, nil
+}
+
 func (q *querier) Close() error {
 	q.cancel()
 	return nil
@@ -33,7 +33,7 @@ func TestQuerier_Series(t *testing.T) {
 
 	// Querier clamps the range to [1,300], which should drop some samples of the result above.
 	// The store API allows endpoints to send more data then initially requested.
-	q := newQuerier(context.Background(), nil, 1, 300, "", testProxy, false, 0, nil)
+	q := newQuerier(context.Background(), nil, 1, 300, nil, false, testProxy, false, 0, true, nil)
 	defer func() { testutil.Ok(t, q.Close()) }()
 
 	res, err := q.Select(&storage.SelectParams{})
@@ -72,6 +72,85 @@ func TestQuerier_Series(t *testing.T) {
 	testutil.Ok(t, res.Err())
 
 	testutil.Equals(t, len(expected), i)
+	testutil.Assert(t, !testProxy.seriesReq.PartialResponseDisabled, "expected partial response to be enabled")
+	testutil.Assert(t, !testProxy.seriesReq.SkipChunks, "expected chunks to be requested")
+}
+
+func TestQuerier_SelectLabelSets(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	testProxy := &storeServer{
+		resps: []*storepb.SeriesResponse{
+			storepb.NewSeriesResponse(&storepb.Series{Labels: []storepb.Label{{Name: "a", Value: "a"}}}),
+			storepb.NewSeriesResponse(&storepb.Series{Labels: []storepb.Label{{Name: "a", Value: "b"}}}),
+		},
+	}
+
+	q := newQuerier(context.Background(), nil, 1, 300, nil, false, testProxy, false, 0, true, nil)
+	defer func() { testutil.Ok(t, q.Close()) }()
+
+	var _ LabelSetsSelector = q
+
+	res, err := q.SelectLabelSets()
+	testutil.Ok(t, err)
+	testutil.Assert(t, testProxy.seriesReq.SkipChunks, "expected chunks to be skipped")
+
+	var lsets []labels.Labels
+	for res.Next() {
+		lsets = append(lsets, res.At().Labels())
+	}
+	testutil.Ok(t, res.Err())
+	testutil.Equals(t, []labels.Labels{labels.FromStrings("a", "a"), labels.FromStrings("a", "b")}, lsets)
+}
+
+func TestQuerier_Series_PartialResponseDisabled(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	testProxy := &storeServer{}
+
+	q := newQuerier(context.Background(), nil, 1, 300, nil, false, testProxy, false, 0, false, nil)
+	defer func() { testutil.Ok(t, q.Close()) }()
+
+	_, err := q.Select(&storage.SelectParams{})
+	testutil.Ok(t, err)
+	testutil.Assert(t, testProxy.seriesReq.PartialResponseDisabled, "expected partial response to be disabled")
+}
+
+func TestQuerier_LabelValues(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	testProxy := &storeServer{}
+
+	var warnings []error
+	q := newQuerier(context.Background(), nil, 1, 300, nil, false, testProxy, false, 0, true, func(err error) {
+		warnings = append(warnings, err)
+	})
+	defer func() { testutil.Ok(t, q.Close()) }()
+
+	m, err := labels.NewMatcher(labels.MatchRegexp, "b", "1|2")
+	testutil.Ok(t, err)
+
+	vals, err := q.LabelValuesWithMatchers("a", m)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"1", "2"}, vals)
+	testutil.Equals(t, 1, len(warnings))
+	testutil.Equals(t, &storepb.LabelValuesRequest{
+		Label:     "a",
+		Matchers:  []storepb.LabelMatcher{{Type: storepb.LabelMatcher_RE, Name: "b", Value: "1|2"}},
+		TimeRange: &storepb.TimeRange{MinTime: 1, MaxTime: 300},
+	}, testProxy.labelValuesReq)
+
+	// Range starting at epoch is passed as it is.
+	q = newQuerier(context.Background(), nil, 0, 300, nil, false, testProxy, false, 0, true, nil)
+	defer func() { testutil.Ok(t, q.Close()) }()
+
+	_, err = q.LabelValues("a")
+	testutil.Ok(t, err)
+	testutil.Equals(t, &storepb.LabelValuesRequest{
+		Label:     "a",
+		Matchers:  []storepb.LabelMatcher{},
+		TimeRange: &storepb.TimeRange{MinTime: 0, MaxTime: 300},
+	}, testProxy.labelValuesReq)
 }
 
 func TestSortReplicaLabel(t *testing.T) {
@@ -99,9 +178,20 @@ func TestSortReplicaLabel(t *testing.T) {
 			{"b", "replica-2"},
 			{"c", "3"},
 		}},
+		{Labels: []storepb.Label{
+			{"a", "1"},
+			{"b", "replica-1"},
+			{"c", "3"},
+			{"e", "ruler-1"},
+		}},
+		{Labels: []storepb.Label{
+			{"a", "1"},
+			{"c", "3"},
+			{"e", "ruler-2"},
+		}},
 	}
 
-	sortDedupLabels(set, "b")
+	sortDedupLabels(set, map[string]struct{}{"b": {}, "e": {}})
 
 	exp := []storepb.Series{
 		{Labels: []storepb.Label{
@@ -109,11 +199,22 @@ func TestSortReplicaLabel(t *testing.T) {
 			{"c", "3"},
 			{"b", "replica-1"},
 		}},
+		{Labels: []storepb.Label{
+			{"a", "1"},
+			{"c", "3"},
+			{"b", "replica-1"},
+			{"e", "ruler-1"},
+		}},
 		{Labels: []storepb.Label{
 			{"a", "1"},
 			{"c", "3"},
 			{"b", "replica-2"},
 		}},
+		{Labels: []storepb.Label{
+			{"a", "1"},
+			{"c", "3"},
+			{"e", "ruler-2"},
+		}},
 		{Labels: []storepb.Label{
 			{"a", "1"},
 			{"c", "3"},
@@ -163,6 +264,12 @@ func TestDedupSeriesSet(t *testing.T) {
 		}, {
 			lset: []storepb.Label{{"a", "1"}, {"c", "4"}, {"replica", "replica-1"}},
 			vals: []sample{{10000, 1}, {20000, 2}},
+		}, {
+			lset: []storepb.Label{{"a", "1"}, {"c", "5"}, {"replica", "replica-1"}, {"rule_replica", "ruler-1"}},
+			vals: []sample{{10000, 1}, {20000, 2}},
+		}, {
+			lset: []storepb.Label{{"a", "1"}, {"c", "5"}, {"rule_replica", "ruler-2"}},
+			vals: []sample{{60000, 3}, {70000, 4}},
 		}, {
 			lset: []storepb.Label{{"a", "2"}, {"c", "3"}, {"replica", "replica-3"}},
 			vals: []sample{{10000, 1}, {20000, 2}},
@@ -191,6 +298,10 @@ func TestDedupSeriesSet(t *testing.T) {
 			lset: labels.Labels{{"a", "1"}, {"c", "4"}},
 			vals: []sample{{10000, 1}, {20000, 2}},
 		},
+		{
+			lset: labels.Labels{{"a", "1"}, {"c", "5"}},
+			vals: []sample{{10000, 1}, {20000, 2}, {60000, 3}, {70000, 4}},
+		},
 		{
 			lset: labels.Labels{{"a", "2"}, {"c", "3"}},
 			vals: []sample{{10000, 1}, {20000, 2}, {60000, 3}, {70000, 4}},
@@ -215,7 +326,7 @@ func TestDedupSeriesSet(t *testing.T) {
 		maxt: math.MaxInt64,
 		set:  newStoreSeriesSet(series),
 	}
-	dedupSet := newDedupSeriesSet(set, "replica")
+	dedupSet := newDedupSeriesSet(set, map[string]struct{}{"replica": {}, "rule_replica": {}}, nil)
 
 	i := 0
 	for dedupSet.Next() {
@@ -232,45 +343,162 @@ func TestDedupSeriesIterator(t *testing.T) {
 	defer leaktest.CheckTimeout(t, 10*time.Second)()
 
 	// The deltas between timestamps should be at least 10000 to not be affected
-	// by the initial penalty of 5000, that will cause the second iterator to seek
+	// by the initial penalty of 5000, that will cause the other iterators to seek
 	// ahead this far at least once.
 	cases := []struct {
-		a, b, exp []sample
+		name     string
+		replicas [][]sample
+		exp      []sample
 	}{
-		{ // Generally prefer the first series.
-			a:   []sample{{10000, 10}, {20000, 11}, {30000, 12}, {40000, 13}},
-			b:   []sample{{10000, 20}, {20000, 21}, {30000, 22}, {40000, 23}},
+		{
+			name: "generally prefer the first series",
+			replicas: [][]sample{
+				{{10000, 10}, {20000, 11}, {30000, 12}, {40000, 13}},
+				{{10000, 20}, {20000, 21}, {30000, 22}, {40000, 23}},
+			},
 			exp: []sample{{10000, 10}, {20000, 11}, {30000, 12}, {40000, 13}},
 		},
-		{ // Prefer b if it starts earlier.
-			a:   []sample{{10100, 1}, {20100, 1}, {30100, 1}, {40100, 1}},
-			b:   []sample{{10000, 2}, {20000, 2}, {30000, 2}, {40000, 2}},
+		{
+			name: "prefer the series starting earlier",
+			replicas: [][]sample{
+				{{10100, 1}, {20100, 1}, {30100, 1}, {40100, 1}},
+				{{10000, 2}, {20000, 2}, {30000, 2}, {40000, 2}},
+			},
 			exp: []sample{{10000, 2}, {20000, 2}, {30000, 2}, {40000, 2}},
 		},
-		{ // Don't switch series on a single delta sized gap.
-			a:   []sample{{10000, 1}, {20000, 1}, {40000, 1}},
-			b:   []sample{{10000, 2}, {20000, 2}, {30000, 2}, {40000, 2}},
+		{
+			name: "don't switch series on a single delta sized gap",
+			replicas: [][]sample{
+				{{10000, 1}, {20000, 1}, {40000, 1}},
+				{{10000, 2}, {20000, 2}, {30000, 2}, {40000, 2}},
+			},
 			exp: []sample{{10000, 1}, {20000, 1}, {40000, 1}},
 		},
 		{
-			a:   []sample{{10000, 1}, {20000, 1}, {40000, 1}},
-			b:   []sample{{15000, 2}, {25000, 2}, {35000, 2}, {45000, 2}},
+			name: "don't switch series on a single delta sized gap with drifting replica",
+			replicas: [][]sample{
+				{{10000, 1}, {20000, 1}, {40000, 1}},
+				{{15000, 2}, {25000, 2}, {35000, 2}, {45000, 2}},
+			},
 			exp: []sample{{10000, 1}, {20000, 1}, {40000, 1}},
 		},
-		{ // Once the gap gets bigger than 2 deltas, switch and stay with the new series.
-			a:   []sample{{10000, 1}, {20000, 1}, {30000, 1}, {60000, 1}, {70000, 1}},
-			b:   []sample{{10100, 2}, {20100, 2}, {30100, 2}, {40100, 2}, {50100, 2}, {60100, 2}},
-			exp: []sample{{10000, 1}, {20000, 1}, {30000, 1}, {50100, 2}, {60100, 2}},
+		{
+			name: "once the gap gets bigger than 2 deltas, switch and stay with the new series",
+			replicas: [][]sample{
+				{{10000, 1}, {20000, 1}, {30000, 1}, {60000, 1}, {70000, 1}},
+				{{10100, 2}, {20100, 2}, {30100, 2}, {40100, 2}, {50100, 2}, {60100, 2}},
+			},
+			exp: []sample{{10000, 1}, {20000, 1}, {30000, 1}, {40100, 2}, {50100, 2}, {60100, 2}, {70000, 1}},
+		},
+		{
+			name: "three drifting replicas with missed scrapes in the followed one",
+			replicas: [][]sample{
+				{{10000, 1}, {20000, 1}, {40000, 1}, {50000, 1}, {70000, 1}, {80000, 1}},
+				{{10100, 2}, {20100, 2}, {30100, 2}, {40100, 2}, {50100, 2}, {60100, 2}, {70100, 2}, {80100, 2}},
+				{{10200, 3}, {20200, 3}, {30200, 3}, {40200, 3}, {50200, 3}, {60200, 3}, {70200, 3}, {80200, 3}},
+			},
+			exp: []sample{{10000, 1}, {20000, 1}, {40000, 1}, {50000, 1}, {70000, 1}, {80000, 1}},
+		},
+		{
+			name: "rolling restart of replicas",
+			replicas: [][]sample{
+				{{10000, 1}, {20000, 1}, {50000, 1}, {60000, 1}, {70000, 1}, {80000, 1}, {90000, 1}, {100000, 1}},
+				{{10100, 2}, {20100, 2}, {30100, 2}, {40100, 2}, {50100, 2}, {80100, 2}, {90100, 2}, {100100, 2}},
+				{{10200, 3}, {20200, 3}, {30200, 3}, {40200, 3}, {50200, 3}, {60200, 3}, {70200, 3}, {80200, 3}, {90200, 3}, {100200, 3}},
+			},
+			exp: []sample{{10000, 1}, {20000, 1}, {30100, 2}, {40100, 2}, {50100, 2}, {60200, 3}, {70200, 3}, {80200, 3}, {90200, 3}, {100200, 3}},
+		},
+		{
+			name: "followed replica shuts down",
+			replicas: [][]sample{
+				{{10000, 1}, {20000, 1}, {30000, 1}},
+				{{10100, 2}, {20100, 2}, {30100, 2}, {40100, 2}, {50100, 2}, {60100, 2}},
+				{{10200, 3}, {20200, 3}, {30200, 3}, {40200, 3}},
+			},
+			exp: []sample{{10000, 1}, {20000, 1}, {30000, 1}, {40100, 2}, {50100, 2}, {60100, 2}},
+		},
+		{
+			name: "replica joins late",
+			replicas: [][]sample{
+				{{40000, 1}, {50000, 1}, {60000, 1}},
+				{{10100, 2}, {20100, 2}, {50100, 2}, {60100, 2}},
+				{{10200, 3}, {20200, 3}},
+			},
+			exp: []sample{{10100, 2}, {20100, 2}, {40000, 1}, {50000, 1}, {60000, 1}},
+		},
+		{
+			name: "on switch prefer the replica without recent gaps over a slightly earlier one",
+			replicas: [][]sample{
+				{{10000, 1}, {20000, 1}, {30000, 1}, {60000, 1}, {70000, 1}, {80000, 1}, {90000, 1}, {100000, 1}, {110000, 1}, {120000, 1}},
+				{{10050, 2}, {20050, 2}, {30050, 2}, {40050, 2}, {50050, 2}, {60050, 2}, {70050, 2}, {120050, 2}},
+				{{60010, 3}, {70010, 3}, {80010, 3}, {90010, 3}, {100010, 3}, {110010, 3}, {120010, 3}},
+			},
+			exp: []sample{{10000, 1}, {20000, 1}, {30000, 1}, {40050, 2}, {50050, 2}, {60050, 2}, {70050, 2}, {80010, 3}, {90010, 3}, {100010, 3}, {110010, 3}, {120010, 3}},
 		},
 	}
-	for i, c := range cases {
-		t.Logf("case %d:", i)
-		it := newDedupSeriesIterator(
-			&SampleIterator{l: c.a, i: -1},
-			&SampleIterator{l: c.b, i: -1},
-		)
-		res := expandSeries(t, it)
-		testutil.Equals(t, c.exp, res)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var its []storage.SeriesIterator
+			for _, r := range c.replicas {
+				its = append(its, &SampleIterator{l: r, i: -1})
+			}
+			res := expandSeries(t, newDedupSeriesIterator(its...))
+			testutil.Equals(t, c.exp, res)
+		})
+	}
+}
+
+func TestCounterDedupSeriesIterator(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	cases := []struct {
+		name     string
+		replicas [][]sample
+		exp      []sample
+	}{
+		{
+			name: "switch to a replica restarted later",
+			replicas: [][]sample{
+				{{10000, 10}, {20000, 20}, {30000, 30}, {60000, 60}, {70000, 70}},
+				{{10100, 1}, {20100, 2}, {30100, 3}, {40100, 4}, {50100, 5}, {60100, 6}},
+			},
+			exp: []sample{{10000, 10}, {20000, 20}, {30000, 30}, {40100, 30}, {50100, 31}, {60100, 32}, {70000, 70}},
+		},
+		{
+			name: "don't adjust a replica ahead of the followed one",
+			replicas: [][]sample{
+				{{10000, 1}, {20000, 2}, {30000, 3}},
+				{{10100, 10}, {20100, 20}, {30100, 30}, {40100, 40}, {50100, 50}, {60100, 60}},
+			},
+			exp: []sample{{10000, 1}, {20000, 2}, {30000, 3}, {40100, 40}, {50100, 50}, {60100, 60}},
+		},
+		{
+			name: "keep resets within a replica",
+			replicas: [][]sample{
+				{{10000, 10}, {20000, 20}, {30000, 0}, {40000, 10}},
+				{{10100, 10}, {20100, 20}, {30100, 30}, {40100, 40}},
+			},
+			exp: []sample{{10000, 10}, {20000, 20}, {30000, 0}, {40000, 10}},
+		},
+		{
+			name: "accumulate adjustments across rolling restarts",
+			replicas: [][]sample{
+				{{10000, 10}, {20000, 20}, {50000, 1}, {60000, 2}, {70000, 3}, {80000, 4}, {90000, 5}, {100000, 6}},
+				{{10100, 10}, {20100, 20}, {30100, 30}, {40100, 40}, {50100, 50}, {80100, 1}, {90100, 2}, {100100, 3}},
+				{{10200, 5}, {20200, 6}, {30200, 7}, {40200, 8}, {50200, 9}, {60200, 10}, {70200, 11}, {80200, 12}, {90200, 13}, {100200, 14}},
+			},
+			exp: []sample{{10000, 10}, {20000, 20}, {30100, 30}, {40100, 40}, {50100, 50}, {60200, 50}, {70200, 51}, {80200, 52}, {90200, 53}, {100200, 54}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var its []storage.SeriesIterator
+			for _, r := range c.replicas {
+				its = append(its, &SampleIterator{l: r, i: -1})
+			}
+			res := expandSeries(t, newCounterDedupSeriesIterator(its...))
+			testutil.Equals(t, c.exp, res)
+		})
 	}
 }
 
@@ -370,9 +598,18 @@ type storeServer struct {
 	storepb.StoreServer
 
 	resps []*storepb.SeriesResponse
+
+	seriesReq      *storepb.SeriesRequest
+	labelValuesReq *storepb.LabelValuesRequest
+}
+
+func (s *storeServer) LabelValues(_ context.Context, r *storepb.LabelValuesRequest) (*storepb.LabelValuesResponse, error) {
+	s.labelValuesReq = r
+	return &storepb.LabelValuesResponse{Values: []string{"1", "2"}, Warnings: []string{"partial error"}}, nil
 }
 
 func (s *storeServer) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesServer) error {
+	s.seriesReq = r
 	for _, resp := range s.resps {
 		err := srv.Send(resp)
 		if err != nil {
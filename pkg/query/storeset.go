@@ -67,10 +67,21 @@ type StoreSet struct {
 
 	mtx                  sync.RWMutex
 	stores               map[string]*storeRef
+	storeStatuses        map[string]*StoreStatus
 	storeNodeConnections prometheus.Gauge
 	externalLabelStores  map[string]int
 }
 
+// StoreStatus is the result of the last check of a store from the store specifications.
+type StoreStatus struct {
+	Name      string
+	LastCheck time.Time
+	LastError error
+	Labels    []storepb.Label
+	MinTime   int64
+	MaxTime   int64
+}
+
 type storeSetNodeCollector struct {
 	externalLabelOccurrences func() map[string]int
 }
@@ -124,6 +135,7 @@ func NewStoreSet(
 		gRPCInfoCallTimeout:  10 * time.Second,
 		externalLabelStores:  map[string]int{},
 		stores:               make(map[string]*storeRef),
+		storeStatuses:        make(map[string]*StoreStatus),
 	}
 
 	storeNodeCollector := &storeSetNodeCollector{externalLabelOccurrences: ss.externalLabelOccurrences}
@@ -171,6 +183,10 @@ func (s *storeRef) TimeRange() (int64, int64) {
 	return s.minTime, s.maxTime
 }
 
+func (s *storeRef) Addr() string {
+	return s.addr
+}
+
 func (s *storeRef) String() string {
 	mint, maxt := s.TimeRange()
 	return fmt.Sprintf("Addr: %s Labels: %v Mint: %d Maxt: %d", s.addr, s.Labels(), mint, maxt)
@@ -183,7 +199,7 @@ func (s *storeRef) close() {
 // Update updates the store set. It fetches current list of store specs from function and updates the fresh metadata
 // from all stores.
 func (s *StoreSet) Update(ctx context.Context) {
-	healthyStores := s.getHealthyStores(ctx)
+	healthyStores, statuses := s.getHealthyStores(ctx)
 
 	// Record the number of occurrences of external label combinations for current store slice.
 	externalLabelStores := map[string]int{}
@@ -219,6 +235,7 @@ func (s *StoreSet) Update(ctx context.Context) {
 		// Sidecar will error out if it will be configured with empty external labels.
 		if len(st.Labels()) > 0 && externalLabelStores[externalLabelsFromStore(st)] != 1 {
 			st.close()
+			statuses[addr].LastError = errors.New("external labels are not unique")
 			level.Warn(s.logger).Log("msg", "dropping store, external labels are not unique", "address", addr)
 			continue
 		}
@@ -227,15 +244,26 @@ func (s *StoreSet) Update(ctx context.Context) {
 		level.Info(s.logger).Log("msg", "adding new store to query storeset", "address", addr)
 	}
 
+	// Keep the last known metadata of stores that failed the check.
+	for addr, status := range statuses {
+		prev, ok := s.storeStatuses[addr]
+		if !ok || status.LastError == nil || status.Labels != nil {
+			continue
+		}
+		status.Labels, status.MinTime, status.MaxTime = prev.Labels, prev.MinTime, prev.MaxTime
+	}
+
 	s.externalLabelStores = externalLabelStores
+	s.storeStatuses = statuses
 	s.storeNodeConnections.Set(float64(len(s.stores)))
 }
 
-func (s *StoreSet) getHealthyStores(ctx context.Context) map[string]*storeRef {
+func (s *StoreSet) getHealthyStores(ctx context.Context) (map[string]*storeRef, map[string]*StoreStatus) {
 	var (
 		unique = make(map[string]struct{})
 
 		healthyStores = make(map[string]*storeRef, len(s.stores))
+		statuses      = make(map[string]*StoreStatus, len(s.stores))
 		mtx           sync.Mutex
 		wg            sync.WaitGroup
 	)
@@ -257,12 +285,21 @@ func (s *StoreSet) getHealthyStores(ctx context.Context) map[string]*storeRef {
 			ctx, cancel := context.WithTimeout(ctx, s.gRPCInfoCallTimeout)
 			defer cancel()
 
+			status := &StoreStatus{Name: addr, LastCheck: time.Now()}
+			defer func() {
+				mtx.Lock()
+				defer mtx.Unlock()
+
+				statuses[addr] = status
+			}()
+
 			st, ok := s.stores[addr]
 			if ok {
 				// Check existing store. Is it healthy? What are current metadata?
 				labels, minTime, maxTime, err := spec.Metadata(ctx, st.StoreClient)
 				if err != nil {
 					// Peer unhealthy. Do not include in healthy stores.
+					status.LastError = err
 					level.Warn(s.logger).Log("msg", "update of store node failed", "err", err, "address", addr)
 					return
 				}
@@ -271,7 +308,8 @@ func (s *StoreSet) getHealthyStores(ctx context.Context) map[string]*storeRef {
 				// New store or was unhealthy and was removed in the past - create new one.
 				conn, err := grpc.DialContext(ctx, addr, s.dialOpts...)
 				if err != nil {
-					level.Warn(s.logger).Log("msg", "update of store node failed", "err", errors.Wrap(err, "dialing connection"), "address", addr)
+					status.LastError = errors.Wrap(err, "dialing connection")
+					level.Warn(s.logger).Log("msg", "update of store node failed", "err", status.LastError, "address", addr)
 					return
 				}
 				st = &storeRef{StoreClient: storepb.NewStoreClient(conn), cc: conn, addr: addr, logger: s.logger}
@@ -280,11 +318,14 @@ func (s *StoreSet) getHealthyStores(ctx context.Context) map[string]*storeRef {
 				resp, err := st.StoreClient.Info(ctx, &storepb.InfoRequest{}, grpc.FailFast(false))
 				if err != nil {
 					st.close()
-					level.Warn(s.logger).Log("msg", "update of store node failed", "err", errors.Wrap(err, "initial store client info fetch"), "address", addr)
+					status.LastError = errors.Wrap(err, "initial store client info fetch")
+					level.Warn(s.logger).Log("msg", "update of store node failed", "err", status.LastError, "address", addr)
 					return
 				}
 				st.Update(resp.Labels, resp.MinTime, resp.MaxTime)
 			}
+			status.Labels = st.Labels()
+			status.MinTime, status.MaxTime = st.TimeRange()
 
 			mtx.Lock()
 			defer mtx.Unlock()
@@ -295,7 +336,7 @@ func (s *StoreSet) getHealthyStores(ctx context.Context) map[string]*storeRef {
 
 	wg.Wait()
 
-	return healthyStores
+	return healthyStores, statuses
 }
 
 func externalLabelsFromStore(st *storeRef) string {
@@ -335,6 +376,19 @@ func (s *StoreSet) Get() []store.Client {
 	return stores
 }
 
+// GetStoreStatus returns the status of all stores from the store specifications, sorted by name.
+func (s *StoreSet) GetStoreStatus() []StoreStatus {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	statuses := make([]StoreStatus, 0, len(s.storeStatuses))
+	for _, st := range s.storeStatuses {
+		statuses = append(statuses, *st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
 func (s *StoreSet) Close() {
 	for _, st := range s.stores {
 		st.close()
@@ -202,6 +202,19 @@ func TestStoreSet_StaticStores_OneAvailable(t *testing.T) {
 	testutil.Equals(t, 1, len(store.labels))
 	testutil.Equals(t, "addr", store.labels[0].Name)
 	testutil.Equals(t, addr, store.labels[0].Value)
+
+	// Both stores are reported, with the error of the unavailable one.
+	statuses := storeSet.GetStoreStatus()
+	testutil.Equals(t, 2, len(statuses))
+	for _, s := range statuses {
+		if s.Name == addr {
+			testutil.Ok(t, s.LastError)
+			testutil.Equals(t, store.labels, s.Labels)
+			continue
+		}
+		testutil.Equals(t, initialStoreAddr[0], s.Name)
+		testutil.NotOk(t, s.LastError)
+	}
 }
 
 func TestStoreSet_StaticStores_NoneAvailable(t *testing.T) {
@@ -0,0 +1,257 @@
+package receive
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/improbable-eng/thanos/pkg/runutil"
+	"github.com/improbable-eng/thanos/pkg/store/prompb"
+	"github.com/improbable-eng/thanos/pkg/tracing"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/route"
+	"github.com/prometheus/tsdb"
+)
+
+// replicaHeader carries the replica of series in requests forwarded between receivers.
+// Such requests are written locally by the receiving node, they are neither replicated nor forwarded again.
+const replicaHeader = "THANOS-REPLICA"
+
+// Handler serves the Prometheus remote write API. Without a hashring all samples are written locally with
+// a Writer. With a hashring, series are distributed to the endpoints of the hashring, each series to as many
+// endpoints as the replication factor.
+type Handler struct {
+	logger            log.Logger
+	writer            *Writer
+	client            *http.Client
+	endpoint          string
+	tenantHeader      string
+	replicationFactor uint64
+
+	mtx      sync.RWMutex
+	hashring Hashring
+
+	requests *prometheus.CounterVec
+	samples  prometheus.Counter
+	forwards *prometheus.CounterVec
+}
+
+// NewHandler returns a new Handler writing samples with the given writer. Endpoint is the address of this
+// receiver in the hashring, tenantHeader the HTTP header identifying tenants and replicationFactor
+// the number of receivers each series is written to.
+func NewHandler(
+	logger log.Logger,
+	reg prometheus.Registerer,
+	writer *Writer,
+	endpoint string,
+	tenantHeader string,
+	replicationFactor uint64,
+) *Handler {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	if replicationFactor == 0 {
+		replicationFactor = 1
+	}
+	h := &Handler{
+		logger:            logger,
+		writer:            writer,
+		client:            &http.Client{Transport: tracing.HTTPTripperware(logger, http.DefaultTransport)},
+		endpoint:          endpoint,
+		tenantHeader:      tenantHeader,
+		replicationFactor: replicationFactor,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "thanos_receive_write_requests_total",
+			Help: "Total number of received remote write requests by HTTP status code.",
+		}, []string{"code"}),
+		samples: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "thanos_receive_samples_total",
+			Help: "Total number of samples of successfully written remote write requests.",
+		}),
+		forwards: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "thanos_receive_forward_requests_total",
+			Help: "Total number of remote write requests forwarded to other receivers by result.",
+		}, []string{"result"}),
+	}
+	if reg != nil {
+		reg.MustRegister(h.requests, h.samples, h.forwards)
+	}
+	return h
+}
+
+// SetHashring replaces the hashring used to distribute series. A nil hashring makes the handler write all samples locally.
+func (h *Handler) SetHashring(hashring Hashring) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.hashring = hashring
+}
+
+// Register registers the remote write endpoint on the router.
+func (h *Handler) Register(r *route.Router, tracer opentracing.Tracer) {
+	r.Post("/api/v1/receive", tracing.HTTPMiddleware(tracer, "receive", h.logger, http.HandlerFunc(h.receive)))
+}
+
+func (h *Handler) receive(w http.ResponseWriter, r *http.Request) {
+	code, err := h.write(r)
+	h.requests.WithLabelValues(strconv.Itoa(code)).Inc()
+	if err != nil {
+		level.Warn(h.logger).Log("msg", "remote write request failed", "err", err)
+		http.Error(w, err.Error(), code)
+		return
+	}
+	w.WriteHeader(code)
+}
+
+// write decodes and writes the snappy compressed remote write request. It returns HTTP status code of the response.
+func (h *Handler) write(r *http.Request) (int, error) {
+	compressed, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	reqBuf, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	var wreq prompb.WriteRequest
+	if err := proto.Unmarshal(reqBuf, &wreq); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	tenant := r.Header.Get(h.tenantHeader)
+	if rh := r.Header.Get(replicaHeader); rh != "" {
+		if _, err := strconv.ParseUint(rh, 10, 64); err != nil {
+			return http.StatusBadRequest, errors.Wrapf(err, "parse %s header", replicaHeader)
+		}
+		// The request was already routed by the hashring of the sending receiver. It is written locally even
+		// if the hashring of this receiver differs, e.g. during a reload, so requests never bounce between receivers.
+		err = h.writer.Write(&wreq)
+	} else {
+		err = h.replicate(r.Context(), tenant, &wreq)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	for _, t := range wreq.Timeseries {
+		h.samples.Add(float64(len(t.Samples)))
+	}
+	return http.StatusNoContent, nil
+}
+
+// replicate writes all replicas of the series of the request. It succeeds if the majority of replicas was written.
+func (h *Handler) replicate(ctx context.Context, tenant string, wreq *prompb.WriteRequest) error {
+	if h.replicationFactor == 1 {
+		return h.forward(ctx, tenant, 0, wreq)
+	}
+
+	errs := make(chan error, h.replicationFactor)
+	for n := uint64(0); n < h.replicationFactor; n++ {
+		go func(n uint64) {
+			errs <- errors.Wrapf(h.forward(ctx, tenant, n, wreq), "replica %d", n)
+		}(n)
+	}
+
+	var (
+		merr   tsdb.MultiError
+		quorum = h.replicationFactor/2 + 1
+	)
+	for n := uint64(0); n < h.replicationFactor; n++ {
+		merr.Add(<-errs)
+	}
+	if uint64(len(merr)) > h.replicationFactor-quorum {
+		return errors.Wrapf(merr.Err(), "write quorum of %d out of %d replicas not reached", quorum, h.replicationFactor)
+	}
+	if len(merr) > 0 {
+		level.Warn(h.logger).Log("msg", "some replicas failed to be written", "err", merr.Err())
+	}
+	return nil
+}
+
+// forward writes the n-th replica of the series of the request. Series owned by this receiver are written
+// locally, the others are forwarded to their endpoints in the hashring.
+func (h *Handler) forward(ctx context.Context, tenant string, n uint64, wreq *prompb.WriteRequest) error {
+	h.mtx.RLock()
+	hashring := h.hashring
+	h.mtx.RUnlock()
+
+	if hashring == nil {
+		return h.writer.Write(wreq)
+	}
+
+	wreqs := map[string]*prompb.WriteRequest{}
+	for i := range wreq.Timeseries {
+		endpoint, err := hashring.GetN(tenant, &wreq.Timeseries[i], n)
+		if err != nil {
+			return err
+		}
+		if _, ok := wreqs[endpoint]; !ok {
+			wreqs[endpoint] = &prompb.WriteRequest{}
+		}
+		wreqs[endpoint].Timeseries = append(wreqs[endpoint].Timeseries, wreq.Timeseries[i])
+	}
+
+	errs := make(chan error, len(wreqs))
+	for endpoint := range wreqs {
+		go func(endpoint string) {
+			if endpoint == h.endpoint {
+				errs <- errors.Wrap(h.writer.Write(wreqs[endpoint]), "write locally")
+				return
+			}
+			err := h.send(ctx, endpoint, tenant, n, wreqs[endpoint])
+			if err != nil {
+				h.forwards.WithLabelValues("error").Inc()
+			} else {
+				h.forwards.WithLabelValues("success").Inc()
+			}
+			errs <- errors.Wrapf(err, "forward to %s", endpoint)
+		}(endpoint)
+	}
+
+	var merr tsdb.MultiError
+	for range wreqs {
+		merr.Add(<-errs)
+	}
+	return merr.Err()
+}
+
+// send sends the request with the n-th replica of its series to the receiver at the given endpoint.
+func (h *Handler) send(ctx context.Context, endpoint string, tenant string, n uint64, wreq *prompb.WriteRequest) error {
+	b, err := proto.Marshal(wreq)
+	if err != nil {
+		return errors.Wrap(err, "marshal request")
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(snappy.Encode(nil, b)))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set(replicaHeader, strconv.FormatUint(n, 10))
+	if tenant != "" {
+		req.Header.Set(h.tenantHeader, tenant)
+	}
+
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer runutil.CloseWithLogOnErr(h.logger, resp.Body, "forward response body")
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
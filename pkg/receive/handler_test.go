@@ -0,0 +1,208 @@
+package receive
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/improbable-eng/thanos/pkg/store/prompb"
+	"github.com/improbable-eng/thanos/pkg/tenancy"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/common/route"
+	"github.com/prometheus/tsdb"
+	"github.com/prometheus/tsdb/labels"
+)
+
+func TestHandler_Receive(t *testing.T) {
+	db, err := testutil.NewTSDB()
+	testutil.Ok(t, err)
+	defer func() {
+		testutil.Ok(t, db.Close())
+		testutil.Ok(t, os.RemoveAll(db.Dir()))
+	}()
+
+	router := route.New()
+	NewHandler(nil, nil, NewWriter(nil, db), "", tenancy.DefaultTenantHeader, 1).Register(router, opentracing.NoopTracer{})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	post := func(body []byte) int {
+		resp, err := http.Post(srv.URL+"/api/v1/receive", "application/x-protobuf", bytes.NewReader(body))
+		testutil.Ok(t, err)
+		testutil.Ok(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	wreq := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				// Labels are not sorted on purpose.
+				Labels:  []prompb.Label{{Name: "b", Value: "1"}, {Name: "a", Value: "1"}},
+				Samples: []prompb.Sample{{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 2}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "a", Value: "2"}},
+				Samples: []prompb.Sample{{Timestamp: 10, Value: 3}},
+			},
+		},
+	}
+	b, err := proto.Marshal(wreq)
+	testutil.Ok(t, err)
+
+	testutil.Equals(t, http.StatusNoContent, post(snappy.Encode(nil, b)))
+	// Repeated samples are skipped and do not fail the request.
+	testutil.Equals(t, http.StatusNoContent, post(snappy.Encode(nil, b)))
+
+	testutil.Equals(t, http.StatusBadRequest, post(b))
+	testutil.Equals(t, http.StatusBadRequest, post(snappy.Encode(nil, []byte("not a protobuf"))))
+
+	testutil.Equals(t, []series{
+		{lset: labels.FromStrings("a", "1", "b", "1"), samples: []sample{{t: 10, v: 1}, {t: 20, v: 2}}},
+		{lset: labels.FromStrings("a", "2"), samples: []sample{{t: 10, v: 3}}},
+	}, querySeries(t, db))
+}
+
+type sample struct {
+	t int64
+	v float64
+}
+
+type series struct {
+	lset    labels.Labels
+	samples []sample
+}
+
+// querySeries returns all series of the TSDB with label a.
+func querySeries(t *testing.T, db *tsdb.DB) []series {
+	q, err := db.Querier(0, math.MaxInt64)
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, q.Close()) }()
+
+	set, err := q.Select(labels.NewMustRegexpMatcher("a", ".+"))
+	testutil.Ok(t, err)
+
+	var res []series
+	for set.Next() {
+		s := series{lset: set.At().Labels()}
+		it := set.At().Iterator()
+		for it.Next() {
+			ts, v := it.At()
+			s.samples = append(s.samples, sample{t: ts, v: v})
+		}
+		testutil.Ok(t, it.Err())
+		res = append(res, s)
+	}
+	testutil.Ok(t, set.Err())
+	return res
+}
+
+func TestHandler_Replicate(t *testing.T) {
+	const numReceivers = 3
+
+	var (
+		endpoints []string
+		handlers  []*Handler
+		dbs       []*tsdb.DB
+	)
+	for i := 0; i < numReceivers; i++ {
+		db, err := testutil.NewTSDB()
+		testutil.Ok(t, err)
+		defer func() {
+			testutil.Ok(t, db.Close())
+			testutil.Ok(t, os.RemoveAll(db.Dir()))
+		}()
+
+		router := route.New()
+		srv := httptest.NewServer(router)
+		defer srv.Close()
+
+		endpoint := srv.URL + "/api/v1/receive"
+		h := NewHandler(nil, nil, NewWriter(nil, db), endpoint, tenancy.DefaultTenantHeader, 2)
+		h.Register(router, opentracing.NoopTracer{})
+
+		endpoints = append(endpoints, endpoint)
+		handlers = append(handlers, h)
+		dbs = append(dbs, db)
+	}
+	hashring, err := NewHashring([]HashringConfig{{Hashring: "default", Endpoints: endpoints}})
+	testutil.Ok(t, err)
+	for _, h := range handlers {
+		h.SetHashring(hashring)
+	}
+
+	wreq := &prompb.WriteRequest{}
+	for i := 0; i < 50; i++ {
+		wreq.Timeseries = append(wreq.Timeseries, prompb.TimeSeries{
+			Labels:  []prompb.Label{{Name: "a", Value: fmt.Sprintf("%d", i)}},
+			Samples: []prompb.Sample{{Timestamp: 10, Value: float64(i)}},
+		})
+	}
+	b, err := proto.Marshal(wreq)
+	testutil.Ok(t, err)
+
+	resp, err := http.Post(endpoints[0], "application/x-protobuf", bytes.NewReader(snappy.Encode(nil, b)))
+	testutil.Ok(t, err)
+	testutil.Ok(t, resp.Body.Close())
+	testutil.Equals(t, http.StatusNoContent, resp.StatusCode)
+
+	// Each series must be written to two different receivers, as selected by the hashring.
+	written := map[string][]string{}
+	for i, db := range dbs {
+		for _, s := range querySeries(t, db) {
+			testutil.Equals(t, 1, len(s.samples))
+			written[s.lset.Get("a")] = append(written[s.lset.Get("a")], endpoints[i])
+		}
+	}
+	testutil.Equals(t, len(wreq.Timeseries), len(written))
+	for _, ts := range wreq.Timeseries {
+		exp := make([]string, 0, 2)
+		for n := uint64(0); n < 2; n++ {
+			e, err := hashring.GetN("", &ts, n)
+			testutil.Ok(t, err)
+			exp = append(exp, e)
+		}
+		got := written[ts.Labels[0].Value]
+		sort.Strings(exp)
+		sort.Strings(got)
+		testutil.Equals(t, exp, got)
+	}
+
+	// Forwarded requests are written locally, regardless of the endpoints selected by the hashring.
+	wreq = &prompb.WriteRequest{}
+	for i := 0; i < 10; i++ {
+		wreq.Timeseries = append(wreq.Timeseries, prompb.TimeSeries{
+			Labels:  []prompb.Label{{Name: "a", Value: fmt.Sprintf("forwarded-%d", i)}},
+			Samples: []prompb.Sample{{Timestamp: 10, Value: float64(i)}},
+		})
+	}
+	b, err = proto.Marshal(wreq)
+	testutil.Ok(t, err)
+
+	req, err := http.NewRequest("POST", endpoints[0], bytes.NewReader(snappy.Encode(nil, b)))
+	testutil.Ok(t, err)
+	req.Header.Set(replicaHeader, "1")
+	resp, err = http.DefaultClient.Do(req)
+	testutil.Ok(t, err)
+	testutil.Ok(t, resp.Body.Close())
+	testutil.Equals(t, http.StatusNoContent, resp.StatusCode)
+
+	forwarded := 0
+	for i, db := range dbs {
+		for _, s := range querySeries(t, db) {
+			if strings.HasPrefix(s.lset.Get("a"), "forwarded-") {
+				testutil.Equals(t, 0, i)
+				forwarded++
+			}
+		}
+	}
+	testutil.Equals(t, len(wreq.Timeseries), forwarded)
+}
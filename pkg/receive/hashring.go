@@ -0,0 +1,171 @@
+package receive
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/cespare/xxhash"
+	"github.com/improbable-eng/thanos/pkg/store/prompb"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// sep is used to separate tenant and label names and values when hashing series.
+const sep = '\xff'
+
+// Hashring finds the endpoint responsible for a time series of a tenant.
+type Hashring interface {
+	// GetN returns the endpoint responsible for the n-th replica of the time series of the tenant.
+	GetN(tenant string, ts *prompb.TimeSeries, n uint64) (string, error)
+}
+
+// HashringConfig is the configuration of a single hashring. A hashring without tenants
+// receives series of all tenants not listed in any other hashring.
+type HashringConfig struct {
+	Hashring  string   `yaml:"hashring"`
+	Tenants   []string `yaml:"tenants"`
+	Endpoints []string `yaml:"endpoints"`
+}
+
+// ParseHashringConfig parses the YAML list of hashring configurations.
+func ParseHashringConfig(b []byte) ([]HashringConfig, error) {
+	var cfgs []HashringConfig
+	if err := yaml.UnmarshalStrict(b, &cfgs); err != nil {
+		return nil, errors.Wrap(err, "parsing YAML content of hashring config")
+	}
+	return cfgs, nil
+}
+
+// hash returns a hash of the tenant and labels of the series. The result does not depend on the order of labels.
+func hash(tenant string, ts *prompb.TimeSeries) uint64 {
+	lset := ts.Labels
+	if !sort.SliceIsSorted(lset, func(i, j int) bool { return lset[i].Name < lset[j].Name }) {
+		lset = append([]prompb.Label(nil), lset...)
+		sort.Slice(lset, func(i, j int) bool { return lset[i].Name < lset[j].Name })
+	}
+
+	b := make([]byte, 0, 1024)
+	b = append(b, tenant...)
+	b = append(b, sep)
+	for _, l := range lset {
+		b = append(b, l.Name...)
+		b = append(b, sep)
+		b = append(b, l.Value...)
+		b = append(b, sep)
+	}
+	return xxhash.Sum64(b)
+}
+
+// virtualNodesPerEndpoint is the number of points each endpoint has on the ring. More points spread
+// series more evenly across endpoints.
+const virtualNodesPerEndpoint = 128
+
+// ringHashring is a consistent hashring. Each endpoint owns several points on a ring of hashes and a series
+// is assigned to the endpoint of the first point following the hash of its tenant and labels. Replicas of
+// the series are assigned to the next distinct endpoints along the ring. Adding or removing an endpoint
+// only moves series from or to that endpoint.
+type ringHashring struct {
+	endpoints []string
+	tokens    []uint64
+	// owners holds the index of the endpoint owning the token with the same index.
+	owners []int
+}
+
+func newRingHashring(endpoints []string) *ringHashring {
+	type point struct {
+		token uint64
+		owner int
+	}
+	points := make([]point, 0, len(endpoints)*virtualNodesPerEndpoint)
+	for i, e := range endpoints {
+		b := make([]byte, len(e)+1+8)
+		copy(b, e)
+		b[len(e)] = sep
+		for v := uint64(0); v < virtualNodesPerEndpoint; v++ {
+			binary.BigEndian.PutUint64(b[len(e)+1:], v)
+			points = append(points, point{token: xxhash.Sum64(b), owner: i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].token < points[j].token })
+
+	r := &ringHashring{
+		endpoints: endpoints,
+		tokens:    make([]uint64, 0, len(points)),
+		owners:    make([]int, 0, len(points)),
+	}
+	for _, p := range points {
+		r.tokens = append(r.tokens, p.token)
+		r.owners = append(r.owners, p.owner)
+	}
+	return r
+}
+
+func (r *ringHashring) GetN(tenant string, ts *prompb.TimeSeries, n uint64) (string, error) {
+	if n >= uint64(len(r.endpoints)) {
+		return "", errors.Errorf("replica %d cannot be placed on hashring of %d endpoints", n, len(r.endpoints))
+	}
+	h := hash(tenant, ts)
+	i := sort.Search(len(r.tokens), func(i int) bool { return r.tokens[i] >= h })
+
+	seen := make(map[int]struct{}, n+1)
+	for ; ; i++ {
+		owner := r.owners[i%len(r.owners)]
+		if _, ok := seen[owner]; ok {
+			continue
+		}
+		if uint64(len(seen)) == n {
+			return r.endpoints[owner], nil
+		}
+		seen[owner] = struct{}{}
+	}
+}
+
+// multiHashring routes series to hashrings by tenant.
+type multiHashring struct {
+	tenants map[string]Hashring
+	dflt    Hashring
+}
+
+func (m *multiHashring) GetN(tenant string, ts *prompb.TimeSeries, n uint64) (string, error) {
+	h, ok := m.tenants[tenant]
+	if !ok {
+		if m.dflt == nil {
+			return "", errors.Errorf("no hashring configured for tenant %q", tenant)
+		}
+		h = m.dflt
+	}
+	return h.GetN(tenant, ts, n)
+}
+
+// NewHashring returns a hashring routing series of tenants to the configured hashrings.
+func NewHashring(cfgs []HashringConfig) (Hashring, error) {
+	m := &multiHashring{tenants: map[string]Hashring{}}
+	for _, cfg := range cfgs {
+		if len(cfg.Endpoints) == 0 {
+			return nil, errors.Errorf("hashring %q has no endpoints", cfg.Hashring)
+		}
+		uniq := map[string]struct{}{}
+		for _, e := range cfg.Endpoints {
+			if _, ok := uniq[e]; ok {
+				return nil, errors.Errorf("endpoint %q is listed more than once in hashring %q", e, cfg.Hashring)
+			}
+			uniq[e] = struct{}{}
+		}
+		h := newRingHashring(cfg.Endpoints)
+
+		if len(cfg.Tenants) == 0 {
+			if m.dflt != nil {
+				return nil, errors.Errorf("hashring %q is the second hashring without tenants", cfg.Hashring)
+			}
+			m.dflt = h
+			continue
+		}
+		for _, t := range cfg.Tenants {
+			if _, ok := m.tenants[t]; ok {
+				return nil, errors.Errorf("tenant %q is assigned to more than one hashring", t)
+			}
+			m.tenants[t] = h
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,125 @@
+package receive
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/improbable-eng/thanos/pkg/store/prompb"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+)
+
+func TestParseHashringConfig(t *testing.T) {
+	cfgs, err := ParseHashringConfig([]byte(`
+- hashring: tenants
+  tenants: ["a", "b"]
+  endpoints:
+  - http://receive-0:19291/api/v1/receive
+- hashring: default
+  endpoints:
+  - http://receive-1:19291/api/v1/receive
+  - http://receive-2:19291/api/v1/receive
+`))
+	testutil.Ok(t, err)
+	testutil.Equals(t, []HashringConfig{
+		{Hashring: "tenants", Tenants: []string{"a", "b"}, Endpoints: []string{"http://receive-0:19291/api/v1/receive"}},
+		{Hashring: "default", Endpoints: []string{"http://receive-1:19291/api/v1/receive", "http://receive-2:19291/api/v1/receive"}},
+	}, cfgs)
+
+	_, err = ParseHashringConfig([]byte(`- hashring: default
+  nodes: ["a"]`))
+	testutil.NotOk(t, err)
+}
+
+func TestNewHashring(t *testing.T) {
+	for _, tcase := range []struct {
+		cfgs []HashringConfig
+		ok   bool
+	}{
+		{cfgs: nil, ok: true},
+		{cfgs: []HashringConfig{{Endpoints: []string{"a"}}, {Tenants: []string{"t"}, Endpoints: []string{"b"}}}, ok: true},
+		{cfgs: []HashringConfig{{Endpoints: nil}}},
+		{cfgs: []HashringConfig{{Endpoints: []string{"a", "b", "a"}}}},
+		{cfgs: []HashringConfig{{Endpoints: []string{"a"}}, {Endpoints: []string{"b"}}}},
+		{cfgs: []HashringConfig{{Tenants: []string{"t"}, Endpoints: []string{"a"}}, {Tenants: []string{"t"}, Endpoints: []string{"b"}}}},
+	} {
+		_, err := NewHashring(tcase.cfgs)
+		testutil.Equals(t, tcase.ok, err == nil)
+	}
+}
+
+func TestHashring_GetN(t *testing.T) {
+	h, err := NewHashring([]HashringConfig{
+		{Hashring: "tenants", Tenants: []string{"t1"}, Endpoints: []string{"t1-0"}},
+		{Hashring: "default", Endpoints: []string{"d-0", "d-1", "d-2"}},
+	})
+	testutil.Ok(t, err)
+
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		ts := &prompb.TimeSeries{Labels: []prompb.Label{{Name: "a", Value: fmt.Sprintf("%d", i)}, {Name: "b", Value: "1"}}}
+
+		e, err := h.GetN("t1", ts, 0)
+		testutil.Ok(t, err)
+		testutil.Equals(t, "t1-0", e)
+		_, err = h.GetN("t1", ts, 1)
+		testutil.NotOk(t, err)
+
+		e0, err := h.GetN("", ts, 0)
+		testutil.Ok(t, err)
+		e1, err := h.GetN("", ts, 1)
+		testutil.Ok(t, err)
+		testutil.Assert(t, e0 != e1, "replicas of a series must be placed on different endpoints")
+		counts[e0]++
+
+		// Order of labels must not matter.
+		reordered := &prompb.TimeSeries{Labels: []prompb.Label{ts.Labels[1], ts.Labels[0]}}
+		e, err = h.GetN("", reordered, 0)
+		testutil.Ok(t, err)
+		testutil.Equals(t, e0, e)
+	}
+	testutil.Equals(t, 3, len(counts))
+
+	h, err = NewHashring([]HashringConfig{{Tenants: []string{"t1"}, Endpoints: []string{"t1-0"}}})
+	testutil.Ok(t, err)
+	_, err = h.GetN("t2", &prompb.TimeSeries{}, 0)
+	testutil.NotOk(t, err)
+}
+
+func TestHashring_Consistent(t *testing.T) {
+	endpoints := []string{"e-0", "e-1", "e-2", "e-3", "e-4"}
+	h, err := NewHashring([]HashringConfig{{Endpoints: endpoints}})
+	testutil.Ok(t, err)
+	// Remove an endpoint from the middle, so series are not shifted by index.
+	removed, err := NewHashring([]HashringConfig{{Endpoints: append([]string{"e-0", "e-1"}, endpoints[3:]...)}})
+	testutil.Ok(t, err)
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		ts := &prompb.TimeSeries{Labels: []prompb.Label{{Name: "a", Value: fmt.Sprintf("%d", i)}}}
+
+		e, err := h.GetN("", ts, 0)
+		testutil.Ok(t, err)
+		counts[e]++
+
+		// Only series of the removed endpoint are moved.
+		if e != "e-2" {
+			re, err := removed.GetN("", ts, 0)
+			testutil.Ok(t, err)
+			testutil.Equals(t, e, re)
+		}
+
+		// All replicas are placed on distinct endpoints.
+		seen := map[string]struct{}{}
+		for n := uint64(0); n < uint64(len(endpoints)); n++ {
+			e, err := h.GetN("", ts, n)
+			testutil.Ok(t, err)
+			_, ok := seen[e]
+			testutil.Assert(t, !ok, "replica %d of series %d placed on %s twice", n, i, e)
+			seen[e] = struct{}{}
+		}
+	}
+	// Series are spread across all endpoints.
+	for _, e := range endpoints {
+		testutil.Assert(t, counts[e] > 100, "endpoint %s got only %d series", e, counts[e])
+	}
+}
@@ -0,0 +1,88 @@
+package receive
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/improbable-eng/thanos/pkg/store/prompb"
+	"github.com/pkg/errors"
+	"github.com/prometheus/tsdb"
+	"github.com/prometheus/tsdb/labels"
+)
+
+// Appendable returns appenders of a storage, e.g. *tsdb.DB.
+type Appendable interface {
+	Appender() tsdb.Appender
+}
+
+// Writer appends samples of remote write requests to a storage.
+type Writer struct {
+	logger log.Logger
+	append Appendable
+}
+
+// NewWriter returns a new Writer appending to the given storage.
+func NewWriter(logger log.Logger, app Appendable) *Writer {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	return &Writer{
+		logger: logger,
+		append: app,
+	}
+}
+
+// Write appends all samples of the request in a single transaction. Samples rejected by the storage
+// because they are out of order, duplicated or too old are skipped with a warning, as retrying them
+// would never succeed.
+func (w *Writer) Write(wreq *prompb.WriteRequest) error {
+	var (
+		numOutOfOrder  = 0
+		numDuplicates  = 0
+		numOutOfBounds = 0
+	)
+
+	app := w.append.Appender()
+	for _, t := range wreq.Timeseries {
+		lset := make(labels.Labels, 0, len(t.Labels))
+		for _, l := range t.Labels {
+			lset = append(lset, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		lset = labels.New(lset...)
+
+		for _, s := range t.Samples {
+			_, err := app.Add(lset, s.Timestamp, s.Value)
+			switch err {
+			case nil:
+			case tsdb.ErrOutOfOrderSample:
+				numOutOfOrder++
+				level.Debug(w.logger).Log("msg", "out of order sample", "lset", lset.String(), "t", s.Timestamp)
+			case tsdb.ErrAmendSample:
+				numDuplicates++
+				level.Debug(w.logger).Log("msg", "duplicate sample for timestamp", "lset", lset.String(), "t", s.Timestamp)
+			case tsdb.ErrOutOfBounds:
+				numOutOfBounds++
+				level.Debug(w.logger).Log("msg", "out of bounds metric", "lset", lset.String(), "t", s.Timestamp)
+			default:
+				if rerr := app.Rollback(); rerr != nil {
+					err = errors.Wrapf(err, "rollback failed: %v", rerr)
+				}
+				return errors.Wrap(err, "append sample")
+			}
+		}
+	}
+
+	if numOutOfOrder > 0 {
+		level.Warn(w.logger).Log("msg", "error on ingesting out-of-order samples", "num_dropped", numOutOfOrder)
+	}
+	if numDuplicates > 0 {
+		level.Warn(w.logger).Log("msg", "error on ingesting samples with different value but same timestamp", "num_dropped", numDuplicates)
+	}
+	if numOutOfBounds > 0 {
+		level.Warn(w.logger).Log("msg", "error on ingesting samples that are too old or are too far into the future", "num_dropped", numOutOfBounds)
+	}
+
+	if err := app.Commit(); err != nil {
+		return errors.Wrap(err, "commit samples")
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/improbable-eng/thanos/pkg/block"
+	"github.com/improbable-eng/thanos/pkg/cache"
 	"github.com/improbable-eng/thanos/pkg/compact/downsample"
 	"github.com/improbable-eng/thanos/pkg/objstore"
 	"github.com/improbable-eng/thanos/pkg/pool"
@@ -56,6 +58,7 @@ type bucketStoreMetrics struct {
 	seriesMergeDuration   prometheus.Histogram
 	resultSeriesCount     prometheus.Summary
 	chunkSizeBytes        prometheus.Histogram
+	queriesLimited        *prometheus.CounterVec
 }
 
 func newBucketStoreMetrics(reg prometheus.Registerer) *bucketStoreMetrics {
@@ -131,6 +134,11 @@ func newBucketStoreMetrics(reg prometheus.Registerer) *bucketStoreMetrics {
 		},
 	})
 
+	m.queriesLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_bucket_store_queries_limited_total",
+		Help: "Total number of series requests that were rejected because of exceeding a limit.",
+	}, []string{"limit"})
+
 	if reg != nil {
 		reg.MustRegister(
 			m.blockLoads,
@@ -147,6 +155,7 @@ func newBucketStoreMetrics(reg prometheus.Registerer) *bucketStoreMetrics {
 			m.seriesMergeDuration,
 			m.resultSeriesCount,
 			m.chunkSizeBytes,
+			m.queriesLimited,
 		)
 	}
 	return &m
@@ -159,8 +168,9 @@ type BucketStore struct {
 	metrics    *bucketStoreMetrics
 	bucket     objstore.BucketReader
 	dir        string
-	indexCache *indexCache
+	indexCache indexCache
 	chunkPool  *pool.BytesPool
+	chunkCache cache.Cache
 
 	// Sets of blocks that have the same labels. They are indexed by a hash over their label set.
 	mtx       sync.RWMutex
@@ -169,6 +179,22 @@ type BucketStore struct {
 
 	// Verbose enabled additional logging.
 	debugLogging bool
+
+	// Limits of series touched and chunks fetched for a single series request. Zero means no limit.
+	maxSeries uint64
+	maxChunks uint64
+
+	// Restricts the blocks served by the store. Nil means all blocks in the bucket are served.
+	filterConfig *FilterConfig
+	// Metas of blocks rejected by the filter, so they are not downloaded again on each sync.
+	rejectedMtx   sync.Mutex
+	rejectedMetas map[ulid.ULID]*block.Meta
+
+	// Index headers of blocks not queried for longer than this are unloaded on sync. Zero means never.
+	indexHeaderIdleTimeout time.Duration
+
+	// External label identifying the tenant of blocks. If set, only blocks of the tenant of the request are served.
+	tenantLabel string
 }
 
 // NewBucketStore creates a new bucket backed store that implements the store API against
@@ -179,29 +205,56 @@ func NewBucketStore(
 	bucket objstore.BucketReader,
 	dir string,
 	indexCacheSizeBytes uint64,
+	indexCacheDiskSizeBytes uint64,
+	indexCacheMemcachedAddrs []string,
 	maxChunkPoolBytes uint64,
+	chunkCache cache.Cache,
+	maxSeries uint64,
+	maxChunks uint64,
+	filterConfig *FilterConfig,
+	indexHeaderIdleTimeout time.Duration,
+	tenantLabel string,
 	debugLogging bool,
 ) (*BucketStore, error) {
 	if logger == nil {
 		logger = log.NewNopLogger()
 	}
-	indexCache, err := newIndexCache(reg, indexCacheSizeBytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "create index cache")
+	var indexCache indexCache
+	if len(indexCacheMemcachedAddrs) > 0 {
+		c, err := cache.NewMemcachedCache(logger, reg, "index-cache", cache.MemcachedConfig{
+			Addresses: indexCacheMemcachedAddrs,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "create memcached client")
+		}
+		indexCache = newMemcachedIndexCache(logger, reg, c)
+	} else {
+		c, err := newInMemoryIndexCache(logger, reg, indexCacheSizeBytes, filepath.Join(dir, "index-cache"), indexCacheDiskSizeBytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "create index cache")
+		}
+		indexCache = c
 	}
 	chunkPool, err := pool.NewBytesPool(2e5, 50e6, 2, maxChunkPoolBytes)
 	if err != nil {
 		return nil, errors.Wrap(err, "create chunk pool")
 	}
 	s := &BucketStore{
-		logger:       logger,
-		bucket:       bucket,
-		dir:          dir,
-		indexCache:   indexCache,
-		chunkPool:    chunkPool,
-		blocks:       map[ulid.ULID]*bucketBlock{},
-		blockSets:    map[uint64]*bucketBlockSet{},
-		debugLogging: debugLogging,
+		logger:                 logger,
+		bucket:                 bucket,
+		dir:                    dir,
+		indexCache:             indexCache,
+		chunkPool:              chunkPool,
+		chunkCache:             chunkCache,
+		blocks:                 map[ulid.ULID]*bucketBlock{},
+		blockSets:              map[uint64]*bucketBlockSet{},
+		debugLogging:           debugLogging,
+		maxSeries:              maxSeries,
+		maxChunks:              maxChunks,
+		filterConfig:           filterConfig,
+		rejectedMetas:          map[ulid.ULID]*block.Meta{},
+		indexHeaderIdleTimeout: indexHeaderIdleTimeout,
+		tenantLabel:            tenantLabel,
 	}
 	s.metrics = newBucketStoreMetrics(reg)
 
@@ -223,6 +276,8 @@ func (s *BucketStore) Close() (err error) {
 			err = e
 		}
 	}
+	// Persist the index cache only after all pending readers are done with it.
+	s.indexCache.close()
 	return err
 }
 
@@ -236,6 +291,19 @@ func (s *BucketStore) SyncBlocks(ctx context.Context) error {
 		wg.Add(1)
 		go func() {
 			for id := range blockc {
+				if s.filterConfig != nil {
+					meta, err := block.DownloadMeta(ctx, s.logger, s.bucket, id)
+					if err != nil {
+						level.Warn(s.logger).Log("msg", "loading block meta failed", "id", id, "err", err)
+						continue
+					}
+					if !s.filterConfig.selects(&meta) {
+						s.rejectedMtx.Lock()
+						s.rejectedMetas[id] = &meta
+						s.rejectedMtx.Unlock()
+						continue
+					}
+				}
 				if err := s.addBlock(ctx, id); err != nil {
 					level.Warn(s.logger).Log("msg", "loading block failed", "id", id, "err", err)
 					continue
@@ -253,11 +321,28 @@ func (s *BucketStore) SyncBlocks(ctx context.Context) error {
 		if err != nil {
 			return nil
 		}
+		if b := s.getBlock(id); b != nil {
+			// Blocks may move out of the time range relative to now, so loaded blocks are dropped.
+			if s.filterConfig.selects(b.meta) {
+				allIDs[id] = struct{}{}
+			}
+			return nil
+		}
 		allIDs[id] = struct{}{}
 
-		if b := s.getBlock(id); b != nil {
+		// Blocks may move into the time range relative to now, so only the filter is evaluated again
+		// for rejected blocks.
+		s.rejectedMtx.Lock()
+		meta, ok := s.rejectedMetas[id]
+		if ok && s.filterConfig.selects(meta) {
+			delete(s.rejectedMetas, id)
+			ok = false
+		}
+		s.rejectedMtx.Unlock()
+		if ok {
 			return nil
 		}
+
 		select {
 		case <-ctx.Done():
 		case blockc <- id:
@@ -271,6 +356,14 @@ func (s *BucketStore) SyncBlocks(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "iter")
 	}
+	s.rejectedMtx.Lock()
+	for id := range s.rejectedMetas {
+		if _, ok := allIDs[id]; !ok {
+			delete(s.rejectedMetas, id)
+		}
+	}
+	s.rejectedMtx.Unlock()
+
 	// Drop all blocks that are no longer present in the bucket.
 	for id := range s.blocks {
 		if _, ok := allIDs[id]; ok {
@@ -282,10 +375,31 @@ func (s *BucketStore) SyncBlocks(ctx context.Context) error {
 		}
 		s.metrics.blockDrops.Inc()
 	}
+	s.unloadIdleIndexHeaders()
 
 	return nil
 }
 
+// unloadIdleIndexHeaders unloads index headers of blocks that were not queried for the idle timeout.
+func (s *BucketStore) unloadIdleIndexHeaders() {
+	if s.indexHeaderIdleTimeout <= 0 {
+		return
+	}
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	for id, b := range s.blocks {
+		unloaded, err := b.unloadIndexHeaderIfIdle(s.indexHeaderIdleTimeout)
+		if err != nil {
+			level.Warn(s.logger).Log("msg", "unloading idle index header failed", "block", id, "err", err)
+			continue
+		}
+		if unloaded {
+			level.Debug(s.logger).Log("msg", "unloaded idle index header", "block", id)
+		}
+	}
+}
+
 // InitialSync perform blocking sync with extra step at the end to delete locally saved blocks that are no longer
 // present in the bucket. The mismatch of these can only happen between restarts, so we can do that only once per startup.
 func (s *BucketStore) InitialSync(ctx context.Context) error {
@@ -348,6 +462,7 @@ func (s *BucketStore) addBlock(ctx context.Context, id ulid.ULID) (err error) {
 		dir,
 		s.indexCache,
 		s.chunkPool,
+		s.chunkCache,
 	)
 	if err != nil {
 		return errors.Wrap(err, "new bucket block")
@@ -411,17 +526,56 @@ func (s *BucketStore) TimeRange() (mint, maxt int64) {
 			maxt = b.meta.MaxTime
 		}
 	}
+	// Blocks overlapping the boundaries of the configured time range are served only within it.
+	fmint, fmaxt := s.filterConfig.timeRange()
+	if mint < fmint {
+		mint = fmint
+	}
+	if maxt > fmaxt {
+		maxt = fmaxt
+	}
 	return mint, maxt
 }
 
 // Info implements the storepb.StoreServer interface.
 func (s *BucketStore) Info(context.Context, *storepb.InfoRequest) (*storepb.InfoResponse, error) {
 	mint, maxt := s.TimeRange()
-	// Store nodes hold global data and thus have no labels.
-	return &storepb.InfoResponse{
+	res := &storepb.InfoResponse{
 		MinTime: mint,
 		MaxTime: maxt,
-	}, nil
+	}
+	// Store nodes hold global data and thus have no labels, unless they serve only blocks selected by
+	// their labels. Labels shared by all such blocks then allow the querier to skip the store.
+	if s.filterConfig == nil || len(s.filterConfig.RelabelConfigs) == 0 {
+		return res, nil
+	}
+	for _, l := range s.commonLabels() {
+		res.Labels = append(res.Labels, storepb.Label{Name: l.Name, Value: l.Value})
+	}
+	return res, nil
+}
+
+// commonLabels returns external labels with the same value in all loaded blocks.
+func (s *BucketStore) commonLabels() labels.Labels {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var common map[string]string
+	for _, b := range s.blocks {
+		if common == nil {
+			common = make(map[string]string, len(b.meta.Thanos.Labels))
+			for n, v := range b.meta.Thanos.Labels {
+				common[n] = v
+			}
+			continue
+		}
+		for n, v := range common {
+			if b.meta.Thanos.Labels[n] != v {
+				delete(common, n)
+			}
+		}
+	}
+	return labels.FromMap(common)
 }
 
 type seriesEntry struct {
@@ -467,6 +621,8 @@ func (s *BucketStore) blockSeries(
 	chunkr *bucketChunkReader,
 	matchers []labels.Matcher,
 	req *storepb.SeriesRequest,
+	seriesLimiter *limiter,
+	chunksLimiter *limiter,
 ) (storepb.SeriesSet, *queryStats, error) {
 	stats := &queryStats{}
 
@@ -490,12 +646,16 @@ func (s *BucketStore) blockSeries(
 	if err != nil {
 		return nil, stats, errors.Wrap(err, "expand postings")
 	}
+	// Check the limit before any series data is fetched.
+	if err := seriesLimiter.Reserve(uint64(len(ps))); err != nil {
+		return nil, stats, err
+	}
 
 	// As of version two all series entries are 16 byte padded. All references
 	// we get have to account for that to get the correct offset.
 	// We do it right at the beginning as it's easier than doing it more fine-grained
 	// at the loading level.
-	if indexr.block.indexVersion >= 2 {
+	if indexr.header.IndexVersion() >= 2 {
 		for i, id := range ps {
 			ps[i] = id * 16
 		}
@@ -543,6 +703,14 @@ func (s *BucketStore) blockSeries(
 			return s.lset[i].Name < s.lset[j].Name
 		})
 
+		if req.SkipChunks {
+			// Only return the label set if the series has data in the requested time range.
+			if overlapsChunks(chks, req.MinTime, req.MaxTime) {
+				res = append(res, s)
+			}
+			continue
+		}
+
 		for _, meta := range chks {
 			if meta.MaxTime < req.MinTime {
 				continue
@@ -565,6 +733,20 @@ func (s *BucketStore) blockSeries(
 		}
 	}
 
+	if req.SkipChunks {
+		stats = stats.merge(indexr.stats)
+		return newBucketSeriesSet(res), stats, nil
+	}
+
+	// Check the limit before any chunk is fetched.
+	var numChunks int
+	for _, s := range res {
+		numChunks += len(s.refs)
+	}
+	if err := chunksLimiter.Reserve(uint64(numChunks)); err != nil {
+		return nil, stats, err
+	}
+
 	// Preload all chunks that were marked in the previous stage.
 	if err := chunkr.preload(); err != nil {
 		return nil, stats, errors.Wrap(err, "preload chunks")
@@ -678,15 +860,34 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 	if err != nil {
 		return status.Error(codes.InvalidArgument, err.Error())
 	}
+	tenant, err := requestTenant(srv.Context(), s.tenantLabel)
+	if err != nil {
+		return err
+	}
+	// Blocks overlapping boundaries of the configured time range are served by multiple stores splitting
+	// the bucket by time. Serve only data within the range to not return it twice.
+	mint, maxt := s.filterConfig.timeRange()
+	if req.MinTime < mint {
+		req.MinTime = mint
+	}
+	if req.MaxTime > maxt {
+		req.MaxTime = maxt
+	}
 	var (
 		stats = &queryStats{}
 		g     run.Group
 		res   []storepb.SeriesSet
 		mtx   sync.Mutex
+
+		seriesLimiter = newLimiter("series", s.maxSeries, s.metrics.queriesLimited.WithLabelValues("series"))
+		chunksLimiter = newLimiter("chunks", s.maxChunks, s.metrics.queriesLimited.WithLabelValues("chunks"))
 	)
 	s.mtx.RLock()
 
 	for _, bs := range s.blockSets {
+		if !tenantMatches(s.tenantLabel, tenant, bs.labels) {
+			continue
+		}
 		blockMatchers, ok := bs.labelMatchers(matchers...)
 		if !ok {
 			continue
@@ -712,6 +913,9 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 			defer runutil.CloseWithLogOnErr(s.logger, chunkr, "series block")
 
 			g.Add(func() error {
+				if err := indexr.loadHeader(); err != nil {
+					return errors.Wrapf(err, "block %s", b.meta.ULID)
+				}
 				part, pstats, err := s.blockSeries(ctx,
 					b.meta.ULID,
 					b.meta.Thanos.Labels,
@@ -719,6 +923,8 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 					chunkr,
 					blockMatchers,
 					req,
+					seriesLimiter,
+					chunksLimiter,
 				)
 				if err != nil {
 					return errors.Wrapf(err, "fetch series for block %s", b.meta.ULID)
@@ -748,6 +954,9 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		span.Finish()
 
 		if err != nil {
+			if _, ok := errors.Cause(err).(limitExceededError); ok {
+				return status.Error(codes.ResourceExhausted, err.Error())
+			}
 			return status.Error(codes.Aborted, err.Error())
 		}
 		stats.getAllDuration = time.Since(begin)
@@ -813,12 +1022,71 @@ func chunksSize(chks []storepb.AggrChunk) (size int) {
 }
 
 // LabelNames implements the storepb.StoreServer interface.
-func (s *BucketStore) LabelNames(context.Context, *storepb.LabelNamesRequest) (*storepb.LabelNamesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "not implemented")
+func (s *BucketStore) LabelNames(ctx context.Context, req *storepb.LabelNamesRequest) (*storepb.LabelNamesResponse, error) {
+	matchers, err := translateMatchers(req.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	tenant, err := requestTenant(ctx, s.tenantLabel)
+	if err != nil {
+		return nil, err
+	}
+	var g errgroup.Group
+
+	s.mtx.RLock()
+
+	var mtx sync.Mutex
+	var sets [][]string
+
+	for _, b := range s.labelBlocks(req.TimeRange, matchers, tenant) {
+		indexr := b.indexReader(ctx)
+		extLset := b.meta.Thanos.Labels
+
+		g.Go(func() error {
+			defer runutil.CloseWithLogOnErr(s.logger, indexr, "label names")
+
+			if err := indexr.loadHeader(); err != nil {
+				return errors.Wrapf(err, "block %s", indexr.block.meta.ULID)
+			}
+			// Series returned for the block have its external labels attached, so report them as well.
+			names := indexr.LabelNames()
+			res := names
+			for ln := range extLset {
+				if i := sort.SearchStrings(names, ln); i < len(names) && names[i] == ln {
+					continue
+				}
+				res = append(res, ln)
+			}
+			sort.Strings(res)
+
+			mtx.Lock()
+			sets = append(sets, res)
+			mtx.Unlock()
+
+			return nil
+		})
+	}
+
+	s.mtx.RUnlock()
+
+	if err := g.Wait(); err != nil {
+		return nil, status.Error(codes.Aborted, err.Error())
+	}
+	return &storepb.LabelNamesResponse{
+		Names: strutil.MergeSlices(sets...),
+	}, nil
 }
 
 // LabelValues implements the storepb.StoreServer interface.
 func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesRequest) (*storepb.LabelValuesResponse, error) {
+	matchers, err := translateMatchers(req.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	tenant, err := requestTenant(ctx, s.tenantLabel)
+	if err != nil {
+		return nil, err
+	}
 	var g errgroup.Group
 
 	s.mtx.RLock()
@@ -826,13 +1094,16 @@ func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesR
 	var mtx sync.Mutex
 	var sets [][]string
 
-	for _, b := range s.blocks {
+	for _, b := range s.labelBlocks(req.TimeRange, matchers, tenant) {
 		indexr := b.indexReader(ctx)
 		// TODO(fabxc): only aggregate chunk metas first and add a subsequent fetch stage
 		// where we consolidate requests.
 		g.Go(func() error {
 			defer runutil.CloseWithLogOnErr(s.logger, indexr, "label values")
 
+			if err := indexr.loadHeader(); err != nil {
+				return errors.Wrapf(err, "block %s", indexr.block.meta.ULID)
+			}
 			tpls, err := indexr.LabelValues(req.Label)
 			if err != nil {
 				return errors.Wrap(err, "lookup label values")
@@ -865,6 +1136,24 @@ func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesR
 	}, nil
 }
 
+// labelBlocks returns blocks of the tenant holding data within the given time range of label names or values
+// request with external labels matching the given matchers. Blocks of the lowest available resolution are preferred
+// as all resolutions share the same series. It must be called with the store's read lock held.
+func (s *BucketStore) labelBlocks(r *storepb.TimeRange, matchers []labels.Matcher, tenant string) (blocks []*bucketBlock) {
+	mint, maxt := labelsRequestTimeRange(r)
+
+	for _, bs := range s.blockSets {
+		if !tenantMatches(s.tenantLabel, tenant, bs.labels) {
+			continue
+		}
+		if _, ok := bs.labelMatchers(matchers...); !ok {
+			continue
+		}
+		blocks = append(blocks, bs.getFor(mint, maxt, downsample.ResLevel2)...)
+	}
+	return blocks
+}
+
 // bucketBlockSet holds all blocks of an equal label set. It internally splits
 // them up by downsampling resolution and allows querying
 type bucketBlockSet struct {
@@ -997,13 +1286,20 @@ type bucketBlock struct {
 	bucket     objstore.BucketReader
 	meta       *block.Meta
 	dir        string
-	indexCache *indexCache
+	indexCache indexCache
 	chunkPool  *pool.BytesPool
+	chunkCache cache.Cache
+
+	// Index header is loaded on first use and can be unloaded when idle.
+	headerMtx      sync.Mutex
+	header         *block.IndexHeader
+	headerLoad     *indexHeaderLoad
+	headerRefs     int
+	headerLastUsed time.Time
 
-	indexVersion int
-	symbols      map[uint32]string
-	lvals        map[string][]string
-	postings     map[labels.Label]index.Range
+	// Context of background operations of the block, canceled on close.
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	indexObj  string
 	chunkObjs []string
@@ -1017,8 +1313,9 @@ func newBucketBlock(
 	bkt objstore.BucketReader,
 	id ulid.ULID,
 	dir string,
-	indexCache *indexCache,
+	indexCache indexCache,
 	chunkPool *pool.BytesPool,
+	chunkCache cache.Cache,
 ) (b *bucketBlock, err error) {
 	b = &bucketBlock{
 		logger:     logger,
@@ -1026,14 +1323,18 @@ func newBucketBlock(
 		indexObj:   path.Join(id.String(), block.IndexFilename),
 		indexCache: indexCache,
 		chunkPool:  chunkPool,
+		chunkCache: chunkCache,
 		dir:        dir,
 	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	defer func() {
+		if err != nil {
+			b.cancel()
+		}
+	}()
 	if err = b.loadMeta(ctx, id); err != nil {
 		return nil, errors.Wrap(err, "load meta")
 	}
-	if err = b.loadIndexCache(ctx); err != nil {
-		return nil, errors.Wrap(err, "load index cache")
-	}
 	// Get object handles for all chunk files.
 	err = bkt.Iter(ctx, path.Join(id.String(), block.ChunksDirname), func(n string) error {
 		b.chunkObjs = append(b.chunkObjs, n)
@@ -1067,41 +1368,173 @@ func (b *bucketBlock) loadMeta(ctx context.Context, id ulid.ULID) error {
 	return nil
 }
 
-func (b *bucketBlock) loadIndexCache(ctx context.Context) (err error) {
+// indexHeaderLoad is a pending load of the index header shared by all readers waiting for it.
+type indexHeaderLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// acquireIndexHeader returns the index header of the block, loading it if needed. The header is loaded
+// in the background, as it may have to be built from the whole index, so the load is neither held back
+// nor canceled by the context of a single query. Each successful call must be followed by releaseIndexHeader
+// once the header is no longer used.
+func (b *bucketBlock) acquireIndexHeader(ctx context.Context) (*block.IndexHeader, error) {
+	for {
+		b.headerMtx.Lock()
+		if b.header != nil {
+			b.headerRefs++
+			h := b.header
+			b.headerMtx.Unlock()
+			return h, nil
+		}
+		l := b.headerLoad
+		if l == nil {
+			l = &indexHeaderLoad{done: make(chan struct{})}
+			b.headerLoad = l
+			go b.loadIndexHeader(l)
+		}
+		b.headerMtx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-l.done:
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+		// The header may have been unloaded in the meantime, so it is loaded again.
+	}
+}
+
+func (b *bucketBlock) releaseIndexHeader() {
+	b.headerMtx.Lock()
+	defer b.headerMtx.Unlock()
+
+	b.headerRefs--
+	b.headerLastUsed = time.Now()
+}
+
+// unloadIndexHeaderIfIdle unloads the index header if it is not used and was last used longer than
+// the given duration ago. It returns true if the header was unloaded.
+func (b *bucketBlock) unloadIndexHeaderIfIdle(idle time.Duration) (bool, error) {
+	b.headerMtx.Lock()
+	defer b.headerMtx.Unlock()
+
+	if b.header == nil || b.headerRefs > 0 || time.Since(b.headerLastUsed) < idle {
+		return false, nil
+	}
+	return true, b.unloadIndexHeader()
+}
+
+func (b *bucketBlock) unloadIndexHeader() error {
+	err := b.header.Close()
+	b.header = nil
+	return err
+}
+
+// loadIndexHeader loads the index header and notifies all readers waiting for the given load.
+func (b *bucketBlock) loadIndexHeader(l *indexHeaderLoad) {
+	h, err := b.openIndexHeader(b.ctx)
+
+	b.headerMtx.Lock()
+	b.header, b.headerLoad, l.err = h, nil, err
+	b.headerLastUsed = time.Now()
+	b.headerMtx.Unlock()
+
+	close(l.done)
+}
+
+// openIndexHeader memory-maps the index header from the block directory, fetching it first if needed.
+func (b *bucketBlock) openIndexHeader(ctx context.Context) (*block.IndexHeader, error) {
+	fn := filepath.Join(b.dir, block.IndexHeaderFilename)
+
+	if _, err := os.Stat(fn); os.IsNotExist(err) {
+		if err := b.fetchIndexHeader(ctx, fn); err != nil {
+			return nil, errors.Wrap(err, "fetch index header")
+		}
+	} else if err != nil {
+		return nil, errors.Wrap(err, "stat index header")
+	}
+	h, err := block.OpenIndexHeader(fn)
+	if err != nil {
+		// Remove the header, so it is rebuilt next time.
+		if rerr := os.Remove(fn); rerr != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove broken index header", "path", fn, "err", rerr)
+		}
+		return nil, errors.Wrap(err, "open index header")
+	}
+	return h, nil
+}
+
+// fetchIndexHeader writes the index header to the given file. It prefers converting the JSON index cache
+// left by previous versions, then the header uploaded along with the block. The header is built from
+// the downloaded index only if neither is available.
+func (b *bucketBlock) fetchIndexHeader(ctx context.Context, fn string) error {
 	cachefn := filepath.Join(b.dir, block.IndexCacheFilename)
+	if _, err := os.Stat(cachefn); err == nil {
+		err := block.WriteIndexHeaderFromCache(b.logger, cachefn, fn)
+		if rerr := os.Remove(cachefn); rerr != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove index cache", "path", cachefn, "err", rerr)
+		}
+		if err == nil {
+			return nil
+		}
+		level.Warn(b.logger).Log("msg", "converting index cache to index header failed", "err", err)
+	}
 
-	b.indexVersion, b.symbols, b.lvals, b.postings, err = block.ReadIndexCache(b.logger, cachefn)
-	if err == nil {
-		return nil
+	src := path.Join(b.meta.ULID.String(), block.IndexHeaderFilename)
+	ok, err := b.bucket.Exists(ctx, src)
+	if err != nil {
+		return errors.Wrap(err, "check index header existence")
 	}
-	if !os.IsNotExist(errors.Cause(err)) {
-		return errors.Wrap(err, "read index cache")
+	if ok {
+		if err = b.downloadIndexHeader(ctx, src, fn); err == nil {
+			return nil
+		}
+		level.Warn(b.logger).Log("msg", "downloading index header failed, building it from index", "err", err)
 	}
-	// No cache exists is on disk yet, build it from the downloaded index and retry.
-	fn := filepath.Join(b.dir, block.IndexFilename)
+	if err := b.buildIndexHeader(ctx, fn); err != nil {
+		return errors.Wrap(err, "build index header")
+	}
+	return nil
+}
+
+// downloadIndexHeader downloads and verifies the index header uploaded along with the block.
+func (b *bucketBlock) downloadIndexHeader(ctx context.Context, src, fn string) error {
+	if err := objstore.DownloadFile(ctx, b.logger, b.bucket, src, fn); err != nil {
+		return errors.Wrap(err, "download index header")
+	}
+	h, err := block.OpenIndexHeader(fn)
+	if err != nil {
+		if rerr := os.Remove(fn); rerr != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove broken index header", "path", fn, "err", rerr)
+		}
+		return errors.Wrap(err, "open index header")
+	}
+	return h.Close()
+}
 
-	if err := objstore.DownloadFile(ctx, b.logger, b.bucket, b.indexObj, fn); err != nil {
+func (b *bucketBlock) buildIndexHeader(ctx context.Context, fn string) error {
+	indexfn := filepath.Join(b.dir, block.IndexFilename)
+
+	if err := objstore.DownloadFile(ctx, b.logger, b.bucket, b.indexObj, indexfn); err != nil {
 		return errors.Wrap(err, "download index file")
 	}
 	defer func() {
-		if rerr := os.Remove(fn); rerr != nil {
-			level.Error(b.logger).Log("msg", "failed to remove temp index file", "path", fn, "err", rerr)
+		if rerr := os.Remove(indexfn); rerr != nil {
+			level.Error(b.logger).Log("msg", "failed to remove temp index file", "path", indexfn, "err", rerr)
 		}
 	}()
 
-	indexr, err := index.NewFileReader(fn)
+	indexr, err := index.NewFileReader(indexfn)
 	if err != nil {
 		return errors.Wrap(err, "open index reader")
 	}
-	defer runutil.CloseWithLogOnErr(b.logger, indexr, "load index cache reader")
-
-	if err := block.WriteIndexCache(b.logger, cachefn, indexr); err != nil {
-		return errors.Wrap(err, "write index cache")
-	}
+	defer runutil.CloseWithLogOnErr(b.logger, indexr, "build index header reader")
 
-	b.indexVersion, b.symbols, b.lvals, b.postings, err = block.ReadIndexCache(b.logger, cachefn)
-	if err != nil {
-		return errors.Wrap(err, "read index cache")
+	if err := block.WriteIndexHeader(b.logger, fn, indexr); err != nil {
+		return errors.Wrap(err, "write index header")
 	}
 	return nil
 }
@@ -1152,23 +1585,40 @@ func (b *bucketBlock) chunkReader(ctx context.Context) *bucketChunkReader {
 // Close waits for all pending readers to finish and then closes all underlying resources.
 func (b *bucketBlock) Close() error {
 	b.pendingReaders.Wait()
-	return nil
+
+	// Stop loading the index header, nobody is waiting for it anymore.
+	b.cancel()
+	b.headerMtx.Lock()
+	l := b.headerLoad
+	b.headerMtx.Unlock()
+	if l != nil {
+		<-l.done
+	}
+
+	b.headerMtx.Lock()
+	defer b.headerMtx.Unlock()
+
+	if b.header == nil {
+		return nil
+	}
+	return b.unloadIndexHeader()
 }
 
 type bucketIndexReader struct {
 	logger log.Logger
 	ctx    context.Context
 	block  *bucketBlock
+	header *block.IndexHeader
 	dec    *index.Decoder
 	stats  *queryStats
-	cache  *indexCache
+	cache  indexCache
 
 	mtx            sync.Mutex
 	loadedPostings []*lazyPostings
 	loadedSeries   map[uint64][]byte
 }
 
-func newBucketIndexReader(ctx context.Context, logger log.Logger, block *bucketBlock, cache *indexCache) *bucketIndexReader {
+func newBucketIndexReader(ctx context.Context, logger log.Logger, block *bucketBlock, cache indexCache) *bucketIndexReader {
 	r := &bucketIndexReader{
 		logger:       logger,
 		ctx:          ctx,
@@ -1178,10 +1628,19 @@ func newBucketIndexReader(ctx context.Context, logger log.Logger, block *bucketB
 		cache:        cache,
 		loadedSeries: map[uint64][]byte{},
 	}
-	r.dec.SetSymbolTable(r.block.symbols)
 	return r
 }
 
+// loadHeader loads the index header of the block. It must be called before any lookups.
+func (r *bucketIndexReader) loadHeader() error {
+	h, err := r.block.acquireIndexHeader(r.ctx)
+	if err != nil {
+		return errors.Wrap(err, "load index header")
+	}
+	r.header = h
+	return nil
+}
+
 func (r *bucketIndexReader) preloadPostings() error {
 	const maxGapSize = 512 * 1024
 
@@ -1247,16 +1706,10 @@ func (r *bucketIndexReader) preloadSeries(ids []uint64) error {
 	const maxSeriesSize = 64 * 1024
 	const maxGapSize = 512 * 1024
 
-	var newIDs []uint64
-
-	for _, id := range ids {
-		if b, ok := r.cache.series(r.block.meta.ULID, id); ok {
-			r.loadedSeries[id] = b
-			continue
-		}
-		newIDs = append(newIDs, id)
+	hits, ids := r.cache.fetchMultiSeries(r.block.meta.ULID, ids)
+	for id, b := range hits {
+		r.loadedSeries[id] = b
 	}
-	ids = newIDs
 
 	parts := partitionRanges(len(ids), func(i int) (start, end uint64) {
 		return ids[i], ids[i] + maxSeriesSize
@@ -1346,7 +1799,12 @@ func (r *bucketIndexReader) LabelValues(names ...string) (index.StringTuples, er
 	if len(names) != 1 {
 		return nil, errors.New("label value lookups only supported for single name")
 	}
-	return index.NewStringTuples(r.block.lvals[names[0]], 1)
+	return index.NewStringTuples(r.header.LabelValues(names[0]), 1)
+}
+
+// LabelNames returns all label names present in the block's index in sorted order.
+func (r *bucketIndexReader) LabelNames() []string {
+	return r.header.LabelNames()
 }
 
 type lazyPostings struct {
@@ -1365,7 +1823,7 @@ func (p *lazyPostings) set(v index.Postings) {
 // background garbage collections.
 func (r *bucketIndexReader) Postings(name, value string) (index.Postings, error) {
 	l := labels.Label{Name: name, Value: value}
-	ptr, ok := r.block.postings[l]
+	ptr, ok := r.header.PostingsRange(name, value)
 	if !ok {
 		return index.EmptyPostings(), nil
 	}
@@ -1403,7 +1861,89 @@ func (r *bucketIndexReader) Series(ref uint64, lset *labels.Labels, chks *[]chun
 	r.stats.seriesTouched++
 	r.stats.seriesTouchedSizeSum += len(b)
 
-	return r.dec.Series(b, lset, chks)
+	return decodeSeries(b, r.header.LookupSymbol, lset, chks)
+}
+
+// decodeSeries decodes the series entry the same way as index.Decoder, but symbols are looked up with
+// the given function instead of a symbol table held on the heap.
+func decodeSeries(b []byte, lookupSymbol func(uint32) (string, error), lset *labels.Labels, chks *[]chunks.Meta) error {
+	*lset = (*lset)[:0]
+	*chks = (*chks)[:0]
+
+	d := seriesDecbuf{b: b}
+
+	k := int(d.uvarint64())
+	for i := 0; i < k; i++ {
+		lno := uint32(d.uvarint64())
+		lvo := uint32(d.uvarint64())
+		if d.err != nil {
+			return errors.Wrap(d.err, "read series label offsets")
+		}
+		ln, err := lookupSymbol(lno)
+		if err != nil {
+			return errors.Wrap(err, "lookup label name")
+		}
+		lv, err := lookupSymbol(lvo)
+		if err != nil {
+			return errors.Wrap(err, "lookup label value")
+		}
+		*lset = append(*lset, labels.Label{Name: ln, Value: lv})
+	}
+
+	k = int(d.uvarint64())
+	if k == 0 {
+		return d.err
+	}
+	t0 := d.varint64()
+	maxt := int64(d.uvarint64()) + t0
+	ref0 := int64(d.uvarint64())
+
+	*chks = append(*chks, chunks.Meta{Ref: uint64(ref0), MinTime: t0, MaxTime: maxt})
+	t0 = maxt
+
+	for i := 1; i < k; i++ {
+		mint := int64(d.uvarint64()) + t0
+		maxt := int64(d.uvarint64()) + mint
+		ref0 += d.varint64()
+		t0 = maxt
+
+		if d.err != nil {
+			return errors.Wrapf(d.err, "read meta for chunk %d", i)
+		}
+		*chks = append(*chks, chunks.Meta{Ref: uint64(ref0), MinTime: mint, MaxTime: maxt})
+	}
+	return d.err
+}
+
+type seriesDecbuf struct {
+	b   []byte
+	err error
+}
+
+func (d *seriesDecbuf) uvarint64() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.b)
+	if n < 1 {
+		d.err = errors.New("invalid uvarint")
+		return 0
+	}
+	d.b = d.b[n:]
+	return x
+}
+
+func (d *seriesDecbuf) varint64() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.b)
+	if n < 1 {
+		d.err = errors.New("invalid varint")
+		return 0
+	}
+	d.b = d.b[n:]
+	return x
 }
 
 // LabelIndices returns the label pairs for which indices exist.
@@ -1413,6 +1953,9 @@ func (r *bucketIndexReader) LabelIndices() ([][]string, error) {
 
 // Close released the underlying resources of the reader.
 func (r *bucketIndexReader) Close() error {
+	if r.header != nil {
+		r.block.releaseIndexHeader()
+	}
 	r.block.pendingReaders.Done()
 	return nil
 }
@@ -1458,9 +2001,16 @@ func (r *bucketChunkReader) preload() error {
 	const maxChunkSize = 16000
 	const maxGapSize = 512 * 1024
 
+	if r.block.chunkCache != nil {
+		r.preloadCachedChunks()
+	}
+
 	var g run.Group
 
 	for seq, offsets := range r.preloads {
+		if len(offsets) == 0 {
+			continue
+		}
 		sort.Slice(offsets, func(i, j int) bool {
 			return offsets[i] < offsets[j]
 		})
@@ -1487,6 +2037,45 @@ func (r *bucketChunkReader) preload() error {
 	return g.Run()
 }
 
+// preloadCachedChunks takes all chunks marked for preloading that are found in the chunk cache and
+// removes them from preloads, so they are not fetched from the bucket.
+func (r *bucketChunkReader) preloadCachedChunks() {
+	var keys []string
+	for seq, offsets := range r.preloads {
+		for _, o := range offsets {
+			keys = append(keys, chunkCacheKey(r.block.meta.ULID, seq, o))
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	found := r.block.chunkCache.Fetch(r.ctx, keys)
+	if len(found) == 0 {
+		return
+	}
+
+	i := 0
+	for seq, offsets := range r.preloads {
+		var misses []uint32
+		for _, o := range offsets {
+			c, ok := found[keys[i]]
+			i++
+			// Skip malformed entries, they will be fetched and overwritten.
+			if !ok || len(c) == 0 {
+				misses = append(misses, o)
+				continue
+			}
+			r.chunks[uint64(seq<<32)|uint64(o)] = rawChunk(c)
+		}
+		r.preloads[seq] = misses
+	}
+}
+
+// chunkCacheKey returns the key of the chunk with the given offset in the given segment file of the block.
+func chunkCacheKey(block ulid.ULID, seq int, off uint32) string {
+	return "C:" + block.String() + ":" + strconv.Itoa(seq) + ":" + strconv.FormatUint(uint64(off), 10)
+}
+
 func (r *bucketChunkReader) loadChunks(ctx context.Context, offs []uint32, seq int, start, end uint32) error {
 	begin := time.Now()
 
@@ -1494,11 +2083,22 @@ func (r *bucketChunkReader) loadChunks(ctx context.Context, offs []uint32, seq i
 	if err != nil {
 		return errors.Wrapf(err, "read range for %d", seq)
 	}
-	r.chunkBytes = append(r.chunkBytes, b)
+
+	var toCache map[string][]byte
+	if r.block.chunkCache != nil {
+		toCache = make(map[string][]byte, len(offs))
+		// Store chunks after all are parsed and the lock is released, as the cache might be remote.
+		defer func() {
+			if len(toCache) > 0 {
+				r.block.chunkCache.Store(ctx, toCache)
+			}
+		}()
+	}
 
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	r.chunkBytes = append(r.chunkBytes, b)
 	r.stats.chunksFetchCount++
 	r.stats.chunksFetched += len(offs)
 	r.stats.chunksFetchDurationSum += time.Since(begin)
@@ -1516,6 +2116,10 @@ func (r *bucketChunkReader) loadChunks(ctx context.Context, offs []uint32, seq i
 		}
 		cid := uint64(seq<<32) | uint64(o)
 		r.chunks[cid] = rawChunk(cb[n : n+int(l)+1])
+
+		if toCache != nil {
+			toCache[chunkCacheKey(r.block.meta.ULID, seq, o)] = cb[n : n+int(l)+1]
+		}
 	}
 	return nil
 }
@@ -10,14 +10,19 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/improbable-eng/thanos/pkg/block"
+	"github.com/improbable-eng/thanos/pkg/cache"
+	"github.com/improbable-eng/thanos/pkg/model"
 	"github.com/improbable-eng/thanos/pkg/objstore"
 	"github.com/improbable-eng/thanos/pkg/objstore/objtesting"
 	"github.com/improbable-eng/thanos/pkg/runutil"
 	"github.com/improbable-eng/thanos/pkg/store/storepb"
+	"github.com/improbable-eng/thanos/pkg/tenancy"
 	"github.com/improbable-eng/thanos/pkg/testutil"
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/pkg/timestamp"
 	"github.com/prometheus/tsdb/labels"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestBucketStore_e2e(t *testing.T) {
@@ -71,6 +76,9 @@ func TestBucketStore_e2e(t *testing.T) {
 			meta.Thanos.Labels = map[string]string{"ext2": "value2"}
 			testutil.Ok(t, block.WriteMetaFile(log.NewNopLogger(), dir2, meta))
 
+			// Upload index header only with the first block, the store builds it for the second one.
+			testutil.Ok(t, block.CreateIndexHeader(log.NewNopLogger(), dir1))
+
 			testutil.Ok(t, block.Upload(ctx, log.NewNopLogger(), bkt, dir1))
 			testutil.Ok(t, block.Upload(ctx, log.NewNopLogger(), bkt, dir2))
 
@@ -78,7 +86,10 @@ func TestBucketStore_e2e(t *testing.T) {
 			testutil.Ok(t, os.RemoveAll(dir2))
 		}
 
-		store, err := NewBucketStore(nil, nil, bkt, dir, 100, 0, false)
+		chunkCache, err := cache.NewInMemoryCache(nil, "chunks", 1e6)
+		testutil.Ok(t, err)
+
+		store, err := NewBucketStore(nil, nil, bkt, dir, 100, 0, nil, 0, chunkCache, 0, 0, nil, 0, "", false)
 		testutil.Ok(t, err)
 
 		go func() {
@@ -108,6 +119,46 @@ func TestBucketStore_e2e(t *testing.T) {
 		testutil.Ok(t, err)
 		testutil.Equals(t, []string{"1", "2"}, vals.Values)
 
+		names, err := store.LabelNames(ctx, &storepb.LabelNamesRequest{})
+		testutil.Ok(t, err)
+		testutil.Equals(t, []string{"a", "b", "c", "ext1", "ext2"}, names.Names)
+
+		// Label lookups should be scoped to blocks matching requested external labels.
+		names, err = store.LabelNames(ctx, &storepb.LabelNamesRequest{
+			Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "ext2", Value: "value2"}},
+		})
+		testutil.Ok(t, err)
+		testutil.Equals(t, []string{"a", "c", "ext2"}, names.Names)
+
+		vals, err = store.LabelValues(ctx, &storepb.LabelValuesRequest{
+			Label:    "b",
+			Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "ext1", Value: "value1"}},
+		})
+		testutil.Ok(t, err)
+		testutil.Equals(t, []string{"1", "2"}, vals.Values)
+
+		vals, err = store.LabelValues(ctx, &storepb.LabelValuesRequest{
+			Label:    "b",
+			Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "ext1", Value: "wrong-value"}},
+		})
+		testutil.Ok(t, err)
+		testutil.Equals(t, 0, len(vals.Values))
+
+		// As well as to blocks within the requested time range.
+		vals, err = store.LabelValues(ctx, &storepb.LabelValuesRequest{
+			Label:     "a",
+			TimeRange: &storepb.TimeRange{MinTime: minTime, MaxTime: minTime + 1},
+		})
+		testutil.Ok(t, err)
+		testutil.Equals(t, []string{"1", "2"}, vals.Values)
+
+		vals, err = store.LabelValues(ctx, &storepb.LabelValuesRequest{
+			Label:     "a",
+			TimeRange: &storepb.TimeRange{MinTime: maxTime + 1, MaxTime: maxTime + 2},
+		})
+		testutil.Ok(t, err)
+		testutil.Equals(t, 0, len(vals.Values))
+
 		pbseries := [][]storepb.Label{
 			{{Name: "a", Value: "1"}, {Name: "b", Value: "1"}, {Name: "ext1", Value: "value1"}},
 			{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "ext1", Value: "value1"}},
@@ -118,11 +169,40 @@ func TestBucketStore_e2e(t *testing.T) {
 			{{Name: "a", Value: "2"}, {Name: "c", Value: "1"}, {Name: "ext2", Value: "value2"}},
 			{{Name: "a", Value: "2"}, {Name: "c", Value: "2"}, {Name: "ext2", Value: "value2"}},
 		}
+		// The second run is served from the chunk cache and must return the same data.
+		var firstRun []storepb.Series
+		for run := 0; run < 2; run++ {
+			srv := newStoreSeriesServer(ctx)
+
+			err = store.Series(&storepb.SeriesRequest{
+				Matchers: []storepb.LabelMatcher{
+					{Type: storepb.LabelMatcher_RE, Name: "a", Value: "1|2"},
+				},
+				MinTime: timestamp.FromTime(start),
+				MaxTime: timestamp.FromTime(now),
+			}, srv)
+			testutil.Ok(t, err)
+			testutil.Equals(t, len(pbseries), len(srv.SeriesSet))
+
+			for i, s := range srv.SeriesSet {
+				testutil.Equals(t, pbseries[i], s.Labels)
+				testutil.Equals(t, 3, len(s.Chunks))
+			}
+			if run > 0 {
+				testutil.Equals(t, firstRun, srv.SeriesSet)
+			}
+			firstRun = srv.SeriesSet
+		}
+
+		pbseries = [][]storepb.Label{
+			{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "ext1", Value: "value1"}},
+			{{Name: "a", Value: "2"}, {Name: "b", Value: "2"}, {Name: "ext1", Value: "value1"}},
+		}
 		srv := newStoreSeriesServer(ctx)
 
 		err = store.Series(&storepb.SeriesRequest{
 			Matchers: []storepb.LabelMatcher{
-				{Type: storepb.LabelMatcher_RE, Name: "a", Value: "1|2"},
+				{Type: storepb.LabelMatcher_EQ, Name: "b", Value: "2"},
 			},
 			MinTime: timestamp.FromTime(start),
 			MaxTime: timestamp.FromTime(now),
@@ -135,25 +215,23 @@ func TestBucketStore_e2e(t *testing.T) {
 			testutil.Equals(t, 3, len(s.Chunks))
 		}
 
-		pbseries = [][]storepb.Label{
-			{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "ext1", Value: "value1"}},
-			{{Name: "a", Value: "2"}, {Name: "b", Value: "2"}, {Name: "ext1", Value: "value1"}},
-		}
+		// Skipping chunks returns the same series without chunks.
 		srv = newStoreSeriesServer(ctx)
 
 		err = store.Series(&storepb.SeriesRequest{
 			Matchers: []storepb.LabelMatcher{
 				{Type: storepb.LabelMatcher_EQ, Name: "b", Value: "2"},
 			},
-			MinTime: timestamp.FromTime(start),
-			MaxTime: timestamp.FromTime(now),
+			MinTime:    timestamp.FromTime(start),
+			MaxTime:    timestamp.FromTime(now),
+			SkipChunks: true,
 		}, srv)
 		testutil.Ok(t, err)
 		testutil.Equals(t, len(pbseries), len(srv.SeriesSet))
 
 		for i, s := range srv.SeriesSet {
 			testutil.Equals(t, pbseries[i], s.Labels)
-			testutil.Equals(t, 3, len(s.Chunks))
+			testutil.Equals(t, 0, len(s.Chunks))
 		}
 
 		// Matching by external label should work as well.
@@ -190,6 +268,118 @@ func TestBucketStore_e2e(t *testing.T) {
 		}, srv)
 		testutil.Ok(t, err)
 		testutil.Equals(t, 0, len(srv.SeriesSet))
+
+		// Store enforcing tenancy serves only blocks with the tenant of the request in the tenant label.
+		store.tenantLabel = "ext2"
+		tenantReq := &storepb.SeriesRequest{
+			Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_RE, Name: "a", Value: "1|2"}},
+			MinTime:  timestamp.FromTime(start),
+			MaxTime:  timestamp.FromTime(now),
+		}
+		srv = newStoreSeriesServer(tenancy.ContextWithTenant(ctx, "value2"))
+		testutil.Ok(t, store.Series(tenantReq, srv))
+		testutil.Equals(t, 4, len(srv.SeriesSet))
+		for _, s := range srv.SeriesSet {
+			testutil.Equals(t, storepb.Label{Name: "ext2", Value: "value2"}, s.Labels[len(s.Labels)-1])
+		}
+
+		names, err = store.LabelNames(tenancy.ContextWithTenant(ctx, "value2"), &storepb.LabelNamesRequest{})
+		testutil.Ok(t, err)
+		testutil.Equals(t, []string{"a", "c", "ext2"}, names.Names)
+
+		srv = newStoreSeriesServer(tenancy.ContextWithTenant(ctx, "other"))
+		testutil.Ok(t, store.Series(tenantReq, srv))
+		testutil.Equals(t, 0, len(srv.SeriesSet))
+
+		_, err = store.LabelValues(ctx, &storepb.LabelValuesRequest{Label: "a"})
+		testutil.NotOk(t, err)
+		testutil.Equals(t, codes.PermissionDenied, status.Code(err))
+		store.tenantLabel = ""
+
+		// Requests exceeding limits should fail. All 6 blocks have 4 series with a single chunk each.
+		allReq := &storepb.SeriesRequest{
+			Matchers: []storepb.LabelMatcher{
+				{Type: storepb.LabelMatcher_RE, Name: "a", Value: "1|2"},
+			},
+			MinTime: timestamp.FromTime(start),
+			MaxTime: timestamp.FromTime(now),
+		}
+		for _, tcase := range []struct {
+			maxSeries, maxChunks uint64
+			ok                   bool
+		}{
+			{maxSeries: 24, maxChunks: 24, ok: true},
+			{maxSeries: 23},
+			{maxChunks: 23},
+		} {
+			store.maxSeries, store.maxChunks = tcase.maxSeries, tcase.maxChunks
+
+			srv = newStoreSeriesServer(ctx)
+			err = store.Series(allReq, srv)
+			if tcase.ok {
+				testutil.Ok(t, err)
+				testutil.Equals(t, len(series), len(srv.SeriesSet))
+				continue
+			}
+			testutil.NotOk(t, err)
+			testutil.Equals(t, codes.ResourceExhausted, status.Code(err))
+		}
+
+		// Store restricted by time and external labels should serve only the selected blocks.
+		filterDir, err := ioutil.TempDir("", "test_bucketstore_e2e_filter")
+		testutil.Ok(t, err)
+		defer func() { testutil.Ok(t, os.RemoveAll(filterDir)) }()
+
+		relabelConfigs, err := ParseRelabelConfigs([]byte(`[{action: keep, source_labels: [ext2], regex: value2}]`))
+		testutil.Ok(t, err)
+		filterConf := &FilterConfig{RelabelConfigs: relabelConfigs}
+		// Select the last two time slots only, the first is dropped as block ranges are half-open.
+		testutil.Ok(t, filterConf.MinTime.Set(timestamp.Time(minTime+int64(2*time.Hour/time.Millisecond)).Format(time.RFC3339Nano)))
+
+		filterStore, err := NewBucketStore(nil, nil, bkt, filterDir, 100, 0, nil, 0, nil, 0, 0, filterConf, 0, "", false)
+		testutil.Ok(t, err)
+		testutil.Ok(t, filterStore.SyncBlocks(ctx))
+		testutil.Equals(t, 2, filterStore.numBlocks())
+		// Metas of rejected blocks are kept, so they are not downloaded on every sync.
+		testutil.Equals(t, store.numBlocks()-2, len(filterStore.rejectedMetas))
+
+		info, err := filterStore.Info(ctx, &storepb.InfoRequest{})
+		testutil.Ok(t, err)
+		testutil.Equals(t, []storepb.Label{{Name: "ext2", Value: "value2"}}, info.Labels)
+		testutil.Equals(t, filterConf.MinTime.PrometheusTimestamp(), info.MinTime)
+		testutil.Equals(t, maxTime, info.MaxTime)
+
+		srv = newStoreSeriesServer(ctx)
+		testutil.Ok(t, filterStore.Series(&storepb.SeriesRequest{
+			Matchers: []storepb.LabelMatcher{
+				{Type: storepb.LabelMatcher_RE, Name: "a", Value: "1|2"},
+			},
+			MinTime: timestamp.FromTime(start),
+			MaxTime: timestamp.FromTime(now),
+		}, srv))
+		testutil.Equals(t, 4, len(srv.SeriesSet))
+
+		for _, s := range srv.SeriesSet {
+			testutil.Equals(t, 2, len(s.Chunks))
+		}
+
+		// Index headers are loaded lazily and unloaded on sync once idle.
+		filterStore.indexHeaderIdleTimeout = time.Nanosecond
+		testutil.Ok(t, filterStore.SyncBlocks(ctx))
+		for _, b := range filterStore.blocks {
+			testutil.Assert(t, b.header == nil, "index header of block %s not unloaded", b.meta.ULID)
+		}
+		names, err = filterStore.LabelNames(ctx, &storepb.LabelNamesRequest{})
+		testutil.Ok(t, err)
+		testutil.Equals(t, []string{"a", "c", "ext2"}, names.Names)
+
+		// Rejected blocks are loaded once they move into the time range.
+		filterConf.MinTime = model.TimeOrDurationValue{}
+		testutil.Ok(t, filterStore.SyncBlocks(ctx))
+		testutil.Equals(t, 3, filterStore.numBlocks())
+		testutil.Equals(t, store.numBlocks()-3, len(filterStore.rejectedMetas))
+
+		testutil.Ok(t, filterStore.Close())
 	})
 
 }
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"encoding/binary"
 	"testing"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/fortytw2/leaktest"
 	"github.com/improbable-eng/thanos/pkg/block"
 	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/tsdb/chunks"
 	"github.com/prometheus/tsdb/labels"
 )
 
@@ -235,3 +238,55 @@ func TestPartitionRanges(t *testing.T) {
 		testutil.Equals(t, c.expected, res)
 	}
 }
+
+func TestDecodeSeries(t *testing.T) {
+	symbols := map[uint32]string{1: "a", 2: "1", 3: "b"}
+	lookup := func(ref uint32) (string, error) {
+		s, ok := symbols[ref]
+		if !ok {
+			return "", errors.Errorf("unknown symbol %d", ref)
+		}
+		return s, nil
+	}
+
+	var (
+		b   []byte
+		buf [binary.MaxVarintLen64]byte
+	)
+	putUvarint := func(vs ...uint64) {
+		for _, v := range vs {
+			b = append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+		}
+	}
+	putVarint := func(v int64) {
+		b = append(b, buf[:binary.PutVarint(buf[:], v)]...)
+	}
+	// Labels a=1, b=1.
+	putUvarint(2, 1, 2, 3, 2)
+	// Chunks [-10, 20] at 100, [30, 50] at 90 and [51, 60] at 200. Times are deltas to the previous
+	// maximum time and references are deltas to the previous reference after the first chunk.
+	putUvarint(3)
+	putVarint(-10)
+	putUvarint(30, 100)
+	putUvarint(10, 20)
+	putVarint(-10)
+	putUvarint(1, 9)
+	putVarint(110)
+
+	var (
+		lset labels.Labels
+		chks []chunks.Meta
+	)
+	testutil.Ok(t, decodeSeries(b, lookup, &lset, &chks))
+	testutil.Equals(t, labels.FromStrings("a", "1", "b", "1"), lset)
+	testutil.Equals(t, []chunks.Meta{
+		{Ref: 100, MinTime: -10, MaxTime: 20},
+		{Ref: 90, MinTime: 30, MaxTime: 50},
+		{Ref: 200, MinTime: 51, MaxTime: 60},
+	}, chks)
+
+	// Truncated entries and unknown symbols are reported.
+	testutil.NotOk(t, decodeSeries(b[:len(b)-1], lookup, &lset, &chks))
+	delete(symbols, 3)
+	testutil.NotOk(t, decodeSeries(b, lookup, &lset, &chks))
+}
@@ -1,17 +1,40 @@
 package store
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	lru "github.com/hashicorp/golang-lru/simplelru"
+	"github.com/improbable-eng/thanos/pkg/runutil"
 	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/tsdb/fileutil"
 	"github.com/prometheus/tsdb/labels"
 )
 
 const (
 	cacheTypePostings = "postings"
 	cacheTypeSeries   = "series"
+
+	cacheTierMemory = "memory"
+	cacheTierDisk   = "disk"
+
+	// Maximum number of items evicted from memory waiting to be written to the disk tier. Further items are dropped.
+	diskIndexCacheQueueSize = 1000
 )
 
 type cacheItem struct {
@@ -29,73 +52,189 @@ func (c cacheItem) keyType() string {
 	return "<unknown>"
 }
 
+// fileName returns the name of the file holding the item in the disk tier. Label of postings is hashed,
+// as file names are limited in length. The full label is stored in the file header instead.
+func (c cacheItem) fileName() string {
+	switch k := c.key.(type) {
+	case cacheKeyPostings:
+		h := sha256.Sum256([]byte(k.Name + "\xff" + k.Value))
+		return cacheTypePostings + "-" + hex.EncodeToString(h[:])
+	case cacheKeySeries:
+		return cacheTypeSeries + "-" + strconv.FormatUint(uint64(k), 10)
+	}
+	return ""
+}
+
+// fileHeader returns the header written before the item value in its file in the disk tier.
+func (c cacheItem) fileHeader() []byte {
+	k, ok := c.key.(cacheKeyPostings)
+	if !ok {
+		return nil
+	}
+	var buf [binary.MaxVarintLen64]byte
+	b := make([]byte, 0, 2*binary.MaxVarintLen64+len(k.Name)+len(k.Value))
+	for _, s := range []string{k.Name, k.Value} {
+		n := binary.PutUvarint(buf[:], uint64(len(s)))
+		b = append(b, buf[:n]...)
+		b = append(b, s...)
+	}
+	return b
+}
+
+// readCacheItem reads the cache item from its file in the disk tier. The reader is positioned
+// at the item value afterwards.
+func readCacheItem(block ulid.ULID, name string, r *bufio.Reader) (cacheItem, error) {
+	parts := strings.Split(name, "-")
+	switch {
+	case len(parts) == 2 && parts[0] == cacheTypePostings:
+		var l [2]string
+		for i := range l {
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return cacheItem{}, errors.Wrap(err, "read label length")
+			}
+			b := make([]byte, n)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return cacheItem{}, errors.Wrap(err, "read label")
+			}
+			l[i] = string(b)
+		}
+		item := cacheItem{block, cacheKeyPostings(labels.Label{Name: l[0], Value: l[1]})}
+		if item.fileName() != name {
+			return cacheItem{}, errors.Errorf("label %s=%q does not match file name", l[0], l[1])
+		}
+		return item, nil
+	case len(parts) == 2 && parts[0] == cacheTypeSeries:
+		id, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return cacheItem{}, errors.Wrap(err, "parse series id")
+		}
+		return cacheItem{block, cacheKeySeries(id)}, nil
+	}
+	return cacheItem{}, errors.Errorf("unknown cache file %s", name)
+}
+
 type cacheKeyPostings labels.Label
 type cacheKeySeries uint64
 
-type indexCache struct {
-	mtx     sync.Mutex
-	lru     *lru.LRU
-	maxSize uint64
-	curSize uint64
+// indexCache caches postings and series entries of block indexes. All operations are best effort.
+// NOTE: It is required to be thread-safe.
+type indexCache interface {
+	setPostings(b ulid.ULID, l labels.Label, v []byte)
+	postings(b ulid.ULID, l labels.Label) ([]byte, bool)
 
+	setSeries(b ulid.ULID, id uint64, v []byte)
+	// fetchMultiSeries returns entries of the given series found in the cache and ids of series that were not found.
+	fetchMultiSeries(b ulid.ULID, ids []uint64) (hits map[uint64][]byte, misses []uint64)
+
+	// close releases all resources held by the cache. It is called once no other method is in use.
+	close()
+}
+
+type indexCacheMetrics struct {
 	requests    *prometheus.CounterVec
 	hits        *prometheus.CounterVec
 	added       *prometheus.CounterVec
+	evicted     *prometheus.CounterVec
 	current     *prometheus.GaugeVec
 	currentSize *prometheus.GaugeVec
 }
 
-// newIndexCache creates a new LRU cache for index entries and ensures the total cache
-// size approximately does not exceed maxBytes.
-func newIndexCache(reg prometheus.Registerer, maxBytes uint64) (*indexCache, error) {
-	c := &indexCache{
-		maxSize: maxBytes,
-	}
-	evicted := prometheus.NewCounterVec(prometheus.CounterOpts{
+func newIndexCacheMetrics(reg prometheus.Registerer) *indexCacheMetrics {
+	var m indexCacheMetrics
+
+	m.evicted = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_items_evicted_total",
 		Help: "Total number of items that were evicted from the index cache.",
-	}, []string{"item_type"})
+	}, []string{"item_type", "tier"})
 
-	c.added = prometheus.NewCounterVec(prometheus.CounterOpts{
+	m.added = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_items_added_total",
 		Help: "Total number of items that were added to the index cache.",
-	}, []string{"item_type"})
+	}, []string{"item_type", "tier"})
 
-	c.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	m.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_requests_total",
 		Help: "Total number of requests to the cache.",
-	}, []string{"item_type"})
+	}, []string{"item_type", "tier"})
 
-	c.hits = prometheus.NewCounterVec(prometheus.CounterOpts{
+	m.hits = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "thanos_store_index_cache_hits_total",
 		Help: "Total number of requests to the cache that were a hit.",
-	}, []string{"item_type"})
+	}, []string{"item_type", "tier"})
 
-	c.current = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	m.current = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "thanos_store_index_cache_items",
 		Help: "Current number of items in the index cache.",
-	}, []string{"item_type"})
+	}, []string{"item_type", "tier"})
 
-	c.currentSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	m.currentSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "thanos_store_index_cache_items_size_bytes",
 		Help: "Current byte size of items in the index cache.",
-	}, []string{"item_type"})
+	}, []string{"item_type", "tier"})
+
+	if reg != nil {
+		reg.MustRegister(m.requests, m.hits, m.added, m.evicted, m.current, m.currentSize)
+	}
+	return &m
+}
+
+type spilledItem struct {
+	item cacheItem
+	val  []byte
+}
 
+// inMemoryIndexCache is an indexCache holding items in memory with an optional on-disk tier.
+type inMemoryIndexCache struct {
+	mtx     sync.Mutex
+	lru     *lru.LRU
+	maxSize uint64
+	curSize uint64
+
+	// Optional disk tier, nil if disabled.
+	disk *diskIndexCache
+
+	metrics *indexCacheMetrics
+}
+
+// newInMemoryIndexCache creates a new LRU cache for index entries and ensures the total cache
+// size approximately does not exceed maxBytes. If maxDiskBytes is greater than zero, items evicted
+// from memory are spilled to dir, which is reloaded on startup and bounded by maxDiskBytes.
+func newInMemoryIndexCache(logger log.Logger, reg prometheus.Registerer, maxBytes uint64, dir string, maxDiskBytes uint64) (*inMemoryIndexCache, error) {
+	c := &inMemoryIndexCache{
+		maxSize: maxBytes,
+		metrics: newIndexCacheMetrics(reg),
+	}
 	// Initialize eviction metric with 0.
-	evicted.WithLabelValues(cacheTypePostings)
-	evicted.WithLabelValues(cacheTypeSeries)
+	for _, tier := range []string{cacheTierMemory, cacheTierDisk} {
+		c.metrics.evicted.WithLabelValues(cacheTypePostings, tier)
+		c.metrics.evicted.WithLabelValues(cacheTypeSeries, tier)
+	}
+	if reg != nil {
+		maxSize := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "thanos_store_index_cache_max_size_bytes",
+			Help: "Maximum number of bytes to be held in the index cache.",
+		}, []string{"tier"})
+		maxSize.WithLabelValues(cacheTierMemory).Set(float64(maxBytes))
+		maxSize.WithLabelValues(cacheTierDisk).Set(float64(maxDiskBytes))
+		reg.MustRegister(maxSize)
+	}
 
 	// Initialize LRU cache with a high size limit since we will manage evictions ourselves
 	// based on stored size.
 	onEvict := func(key, val interface{}) {
-		k := key.(cacheItem).keyType()
+		k := key.(cacheItem)
 		v := val.([]byte)
 
-		evicted.WithLabelValues(k).Inc()
-		c.current.WithLabelValues(k).Dec()
-		c.currentSize.WithLabelValues(k).Sub(float64(len(v)))
+		c.metrics.evicted.WithLabelValues(k.keyType(), cacheTierMemory).Inc()
+		c.metrics.current.WithLabelValues(k.keyType(), cacheTierMemory).Dec()
+		c.metrics.currentSize.WithLabelValues(k.keyType(), cacheTierMemory).Sub(float64(len(v)))
 
 		c.curSize -= uint64(len(v))
+
+		if c.disk != nil {
+			c.disk.enqueue(k, v)
+		}
 	}
 	l, err := lru.NewLRU(1e12, onEvict)
 	if err != nil {
@@ -103,82 +242,380 @@ func newIndexCache(reg prometheus.Registerer, maxBytes uint64) (*indexCache, err
 	}
 	c.lru = l
 
-	if reg != nil {
-		reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
-			Name: "thanos_store_index_cache_max_size_bytes",
-			Help: "Maximum number of bytes to be held in the index cache.",
-		}, func() float64 {
-			return float64(maxBytes)
-		}))
-		reg.MustRegister(c.requests, c.hits, c.added, evicted, c.current, c.currentSize)
+	if maxDiskBytes > 0 {
+		c.disk, err = newDiskIndexCache(logger, reg, c.metrics, dir, maxDiskBytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "create disk index cache")
+		}
 	}
 	return c, nil
 }
 
-func (c *indexCache) ensureFits(b []byte) {
+func (c *inMemoryIndexCache) ensureFits(b []byte) {
 	for c.curSize+uint64(len(b)) > c.maxSize {
 		c.lru.RemoveOldest()
 	}
 }
 
-func (c *indexCache) setPostings(b ulid.ULID, l labels.Label, v []byte) {
-	c.added.WithLabelValues(cacheTypePostings).Inc()
-
+func (c *inMemoryIndexCache) set(item cacheItem, v []byte) {
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
 
+	if uint64(len(v)) > c.maxSize || c.lru.Contains(item) {
+		c.mtx.Unlock()
+		return
+	}
 	c.ensureFits(v)
 
 	// The caller may be passing in a sub-slice of a huge array. Copy the data
 	// to ensure we don't waste huge amounts of space for something small.
 	cv := make([]byte, len(v))
 	copy(cv, v)
-	c.lru.Add(cacheItem{b, cacheKeyPostings(l)}, cv)
+	c.lru.Add(item, cv)
+
+	c.curSize += uint64(len(v))
+	c.metrics.added.WithLabelValues(item.keyType(), cacheTierMemory).Inc()
+	c.metrics.current.WithLabelValues(item.keyType(), cacheTierMemory).Inc()
+	c.metrics.currentSize.WithLabelValues(item.keyType(), cacheTierMemory).Add(float64(len(v)))
 
-	c.currentSize.WithLabelValues(cacheTypePostings).Add(float64(len(v)))
+	c.mtx.Unlock()
 }
 
-func (c *indexCache) postings(b ulid.ULID, l labels.Label) ([]byte, bool) {
-	c.requests.WithLabelValues(cacheTypePostings).Inc()
+func (c *inMemoryIndexCache) get(item cacheItem) ([]byte, bool) {
+	c.metrics.requests.WithLabelValues(item.keyType(), cacheTierMemory).Inc()
 
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	v, ok := c.lru.Get(item)
+	c.mtx.Unlock()
+
+	if ok {
+		c.metrics.hits.WithLabelValues(item.keyType(), cacheTierMemory).Inc()
+		return v.([]byte), true
+	}
+	if c.disk == nil {
+		return nil, false
+	}
 
-	v, ok := c.lru.Get(cacheItem{b, cacheKeyPostings(l)})
+	b, ok := c.disk.get(item)
 	if !ok {
 		return nil, false
 	}
-	c.hits.WithLabelValues(cacheTypePostings).Inc()
-	return v.([]byte), true
+	// Promote the item back to memory. It stays on disk as well, so it is not written again once evicted.
+	c.set(item, b)
+	return b, true
+}
+
+func (c *inMemoryIndexCache) setPostings(b ulid.ULID, l labels.Label, v []byte) {
+	c.set(cacheItem{b, cacheKeyPostings(l)}, v)
+}
+
+func (c *inMemoryIndexCache) postings(b ulid.ULID, l labels.Label) ([]byte, bool) {
+	return c.get(cacheItem{b, cacheKeyPostings(l)})
+}
+
+func (c *inMemoryIndexCache) setSeries(b ulid.ULID, id uint64, v []byte) {
+	c.set(cacheItem{b, cacheKeySeries(id)}, v)
+}
+
+func (c *inMemoryIndexCache) fetchMultiSeries(b ulid.ULID, ids []uint64) (hits map[uint64][]byte, misses []uint64) {
+	hits = map[uint64][]byte{}
+	for _, id := range ids {
+		if v, ok := c.get(cacheItem{b, cacheKeySeries(id)}); ok {
+			hits[id] = v
+			continue
+		}
+		misses = append(misses, id)
+	}
+	return hits, misses
 }
 
-func (c *indexCache) setSeries(b ulid.ULID, id uint64, v []byte) {
-	c.added.WithLabelValues(cacheTypeSeries).Inc()
+// close spills all items held in memory to the disk tier, so they are available after restart.
+func (c *inMemoryIndexCache) close() {
+	if c.disk == nil {
+		return
+	}
+	// Write items that are already evicted first, as they are older.
+	c.disk.close()
 
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	c.ensureFits(v)
+	// Keys are ordered from the oldest, so the most recently used items are the most recent on disk as well.
+	for _, k := range c.lru.Keys() {
+		v, ok := c.lru.Peek(k)
+		if !ok {
+			continue
+		}
+		c.disk.set(k.(cacheItem), v.([]byte))
+	}
+}
 
-	// The caller may be passing in a sub-slice of a huge array. Copy the data
-	// to ensure we don't waste huge amounts of space for something small.
-	cv := make([]byte, len(v))
-	copy(cv, v)
-	c.lru.Add(cacheItem{b, cacheKeySeries(id)}, cv)
+// diskIndexCache is a size-bounded LRU cache of index entries stored as files in a directory.
+// Each entry is stored in a separate file under the directory of its block. Items are written
+// asynchronously, so memory lookups are never blocked by disk writes.
+type diskIndexCache struct {
+	logger log.Logger
+	dir    string
+
+	mtx     sync.Mutex
+	lru     *lru.LRU
+	maxSize uint64
+	curSize uint64
 
-	c.currentSize.WithLabelValues(cacheTypeSeries).Add(float64(len(v)))
+	queue chan spilledItem
+	quit  chan struct{}
+	wg    sync.WaitGroup
+
+	metrics *indexCacheMetrics
+	dropped prometheus.Counter
+}
+
+func newDiskIndexCache(logger log.Logger, reg prometheus.Registerer, metrics *indexCacheMetrics, dir string, maxBytes uint64) (*diskIndexCache, error) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	c := &diskIndexCache{
+		logger:  logger,
+		dir:     dir,
+		maxSize: maxBytes,
+		queue:   make(chan spilledItem, diskIndexCacheQueueSize),
+		quit:    make(chan struct{}),
+		metrics: metrics,
+	}
+	c.dropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_disk_items_dropped_total",
+		Help: "Total number of items that were not written to the disk tier because too many items were waiting.",
+	})
+	if reg != nil {
+		reg.MustRegister(c.dropped)
+	}
+	onEvict := func(key, val interface{}) {
+		k := key.(cacheItem)
+		size := val.(uint64)
+
+		c.metrics.evicted.WithLabelValues(k.keyType(), cacheTierDisk).Inc()
+		c.metrics.current.WithLabelValues(k.keyType(), cacheTierDisk).Dec()
+		c.metrics.currentSize.WithLabelValues(k.keyType(), cacheTierDisk).Sub(float64(size))
+
+		c.curSize -= size
+
+		if err := os.Remove(c.path(k)); err != nil && !os.IsNotExist(err) {
+			level.Warn(c.logger).Log("msg", "failed to remove evicted index cache item", "err", err)
+		}
+	}
+	l, err := lru.NewLRU(1e12, onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = l
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, errors.Wrap(err, "create dir")
+	}
+	if err := c.load(); err != nil {
+		return nil, errors.Wrap(err, "load items")
+	}
+
+	c.wg.Add(1)
+	go c.writeLoop()
+	return c, nil
+}
+
+func (c *diskIndexCache) writeLoop() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.quit:
+			// Write what is left in the queue before exiting.
+			for len(c.queue) > 0 {
+				it := <-c.queue
+				c.set(it.item, it.val)
+			}
+			return
+		case it := <-c.queue:
+			c.set(it.item, it.val)
+		}
+	}
+}
+
+// enqueue schedules the item to be written. The item is dropped if too many items are waiting.
+func (c *diskIndexCache) enqueue(item cacheItem, v []byte) {
+	select {
+	case c.queue <- spilledItem{item: item, val: v}:
+	default:
+		c.dropped.Inc()
+	}
+}
+
+// close waits for all enqueued items to be written. Items can still be written synchronously afterwards.
+func (c *diskIndexCache) close() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+func (c *diskIndexCache) path(item cacheItem) string {
+	return filepath.Join(c.dir, item.block.String(), item.fileName())
+}
+
+type diskCacheFile struct {
+	item  cacheItem
+	size  uint64
+	mtime time.Time
+}
+
+// load adds all items found in the directory to the cache, the least recently written first.
+// Unknown and temporary files are removed.
+func (c *diskIndexCache) load() error {
+	blockDirs, err := fileutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var files []diskCacheFile
+
+	for _, bd := range blockDirs {
+		id, err := ulid.Parse(bd)
+		if err != nil {
+			continue
+		}
+		fis, err := ioutil.ReadDir(filepath.Join(c.dir, bd))
+		if err != nil {
+			return err
+		}
+		for _, fi := range fis {
+			p := filepath.Join(c.dir, bd, fi.Name())
+
+			var item cacheItem
+			if fi.Mode().IsRegular() {
+				item, err = c.loadItem(id, p)
+			} else {
+				err = errors.New("not a regular file")
+			}
+			if err != nil {
+				level.Debug(c.logger).Log("msg", "removing invalid index cache file", "file", p, "err", err)
+				if err := os.RemoveAll(p); err != nil {
+					return err
+				}
+				continue
+			}
+			files = append(files, diskCacheFile{item: item, size: uint64(fi.Size()), mtime: fi.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mtime.Before(files[j].mtime)
+	})
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, f := range files {
+		c.add(f.item, f.size)
+	}
+	level.Info(c.logger).Log("msg", "loaded index cache from disk", "dir", c.dir, "items", c.lru.Len(), "size", c.curSize)
+	return nil
+}
+
+// loadItem reads the cache item from the header of the given file.
+func (c *diskIndexCache) loadItem(block ulid.ULID, p string) (cacheItem, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return cacheItem{}, err
+	}
+	defer runutil.CloseWithLogOnErr(c.logger, f, "close index cache file")
+
+	return readCacheItem(block, filepath.Base(p), bufio.NewReader(f))
 }
 
-func (c *indexCache) series(b ulid.ULID, id uint64) ([]byte, bool) {
-	c.requests.WithLabelValues(cacheTypeSeries).Inc()
+// add accounts the item in the LRU, evicting older items if needed. It must be called with the lock held.
+func (c *diskIndexCache) add(item cacheItem, size uint64) {
+	for c.curSize+size > c.maxSize && c.lru.Len() > 0 {
+		c.lru.RemoveOldest()
+	}
+	c.lru.Add(item, size)
+
+	c.curSize += size
+	c.metrics.added.WithLabelValues(item.keyType(), cacheTierDisk).Inc()
+	c.metrics.current.WithLabelValues(item.keyType(), cacheTierDisk).Inc()
+	c.metrics.currentSize.WithLabelValues(item.keyType(), cacheTierDisk).Add(float64(size))
+}
+
+// set writes the item to disk and adds it to the cache. The file is written without holding the lock,
+// so lookups are not blocked by disk I/O.
+func (c *diskIndexCache) set(item cacheItem, v []byte) {
+	hdr := item.fileHeader()
+	size := uint64(len(hdr) + len(v))
+	if size > c.maxSize {
+		return
+	}
+	c.mtx.Lock()
+	ok := c.lru.Contains(item)
+	c.mtx.Unlock()
+	if ok {
+		return
+	}
+
+	p := c.path(item)
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to create index cache dir", "err", err)
+		return
+	}
+	// Write to a temporary file first, so partially written items are never loaded or read.
+	tmp := p + ".tmp"
+	if err := c.writeFile(tmp, hdr, v); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to write index cache item", "err", err)
+		_ = os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to rename index cache item", "err", err)
+		_ = os.Remove(tmp)
+		return
+	}
 
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	v, ok := c.lru.Get(cacheItem{b, cacheKeySeries(id)})
+	if !c.lru.Contains(item) {
+		c.add(item, size)
+	}
+}
+
+func (c *diskIndexCache) writeFile(p string, hdr, v []byte) (err error) {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer runutil.CloseWithErrCapture(c.logger, &err, f, "close index cache file")
+
+	if _, err := f.Write(hdr); err != nil {
+		return err
+	}
+	_, err = f.Write(v)
+	return err
+}
+
+func (c *diskIndexCache) get(item cacheItem) ([]byte, bool) {
+	c.metrics.requests.WithLabelValues(item.keyType(), cacheTierDisk).Inc()
+
+	c.mtx.Lock()
+	_, ok := c.lru.Get(item)
+	c.mtx.Unlock()
+
 	if !ok {
 		return nil, false
 	}
-	c.hits.WithLabelValues(cacheTypeSeries).Inc()
-	return v.([]byte), true
+	b, err := ioutil.ReadFile(c.path(item))
+	if err != nil {
+		// The item might have been evicted concurrently.
+		level.Debug(c.logger).Log("msg", "failed to read index cache item", "err", err)
+		return nil, false
+	}
+	hdr := item.fileHeader()
+	if len(b) < len(hdr) || string(b[:len(hdr)]) != string(hdr) {
+		level.Warn(c.logger).Log("msg", "index cache item does not match its file", "file", c.path(item))
+		return nil, false
+	}
+	b = b[len(hdr):]
+
+	c.metrics.hits.WithLabelValues(item.keyType(), cacheTierDisk).Inc()
+	return b, true
 }
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/improbable-eng/thanos/pkg/cache"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/tsdb/labels"
+)
+
+const (
+	cacheTierMemcached = "memcached"
+
+	// Maximum number of items waiting to be stored in memcached. Further items are dropped.
+	memcachedIndexCacheQueueSize = 10000
+)
+
+// memcachedIndexCache is an indexCache backed by servers speaking memcached protocol, so it can be shared
+// by multiple store gateways. Items are stored asynchronously, so lookups are never blocked by them.
+type memcachedIndexCache struct {
+	logger log.Logger
+	cache  *cache.AsyncCache
+
+	metrics *indexCacheMetrics
+}
+
+// newMemcachedIndexCache returns an indexCache storing items in the given memcached-backed cache.
+// The cache is closed along with the index cache if it implements io.Closer.
+func newMemcachedIndexCache(logger log.Logger, reg prometheus.Registerer, c cache.Cache) *memcachedIndexCache {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	return &memcachedIndexCache{
+		logger:  logger,
+		cache:   cache.NewAsyncCache(reg, "index", c, memcachedIndexCacheQueueSize),
+		metrics: newIndexCacheMetrics(reg),
+	}
+}
+
+func (c *memcachedIndexCache) set(item cacheItem, v []byte) {
+	c.metrics.added.WithLabelValues(item.keyType(), cacheTierMemcached).Inc()
+	c.cache.Store(context.Background(), map[string][]byte{memcachedKey(item): v})
+}
+
+func (c *memcachedIndexCache) setPostings(b ulid.ULID, l labels.Label, v []byte) {
+	c.set(cacheItem{b, cacheKeyPostings(l)}, v)
+}
+
+func (c *memcachedIndexCache) postings(b ulid.ULID, l labels.Label) ([]byte, bool) {
+	c.metrics.requests.WithLabelValues(cacheTypePostings, cacheTierMemcached).Inc()
+
+	key := memcachedKey(cacheItem{b, cacheKeyPostings(l)})
+	v, ok := c.cache.Fetch(context.Background(), []string{key})[key]
+	if !ok {
+		return nil, false
+	}
+	c.metrics.hits.WithLabelValues(cacheTypePostings, cacheTierMemcached).Inc()
+	return v, true
+}
+
+func (c *memcachedIndexCache) setSeries(b ulid.ULID, id uint64, v []byte) {
+	c.set(cacheItem{b, cacheKeySeries(id)}, v)
+}
+
+func (c *memcachedIndexCache) fetchMultiSeries(b ulid.ULID, ids []uint64) (hits map[uint64][]byte, misses []uint64) {
+	c.metrics.requests.WithLabelValues(cacheTypeSeries, cacheTierMemcached).Add(float64(len(ids)))
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, memcachedKey(cacheItem{b, cacheKeySeries(id)}))
+	}
+	found := c.cache.Fetch(context.Background(), keys)
+
+	hits = make(map[uint64][]byte, len(found))
+	for i, id := range ids {
+		v, ok := found[keys[i]]
+		if !ok {
+			misses = append(misses, id)
+			continue
+		}
+		hits[id] = v
+	}
+	c.metrics.hits.WithLabelValues(cacheTypeSeries, cacheTierMemcached).Add(float64(len(hits)))
+	return hits, misses
+}
+
+func (c *memcachedIndexCache) close() {
+	if err := c.cache.Close(); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to close memcached client", "err", err)
+	}
+}
+
+// memcachedKey returns the memcached key of the item. Label of postings is hashed, as memcached keys
+// are limited in length and must not contain whitespace.
+func memcachedKey(item cacheItem) string {
+	switch k := item.key.(type) {
+	case cacheKeyPostings:
+		h := sha256.Sum256([]byte(k.Name + "\xff" + k.Value))
+		return "P:" + item.block.String() + ":" + hex.EncodeToString(h[:])
+	case cacheKeySeries:
+		return "S:" + item.block.String() + ":" + strconv.FormatUint(uint64(k), 10)
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/improbable-eng/thanos/pkg/cache"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/tsdb/labels"
+)
+
+func TestMemcachedIndexCache(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	srv, err := cache.NewFakeMemcachedServer()
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, srv.Close()) }()
+
+	mc, err := cache.NewMemcachedCache(nil, nil, "test", cache.MemcachedConfig{
+		Addresses: []string{srv.Addr()},
+		Timeout:   5 * time.Second,
+	})
+	testutil.Ok(t, err)
+
+	id := ulid.MustNew(0, nil)
+	l := labels.Label{Name: "a", Value: "value with spaces"}
+
+	c := newMemcachedIndexCache(nil, nil, mc)
+	c.setPostings(id, l, []byte("postings"))
+	c.setSeries(id, 1, []byte("series 1"))
+	c.setSeries(id, 2, []byte("series 2"))
+	// Close stores all pending items.
+	c.close()
+	testutil.Equals(t, 3, srv.Items())
+
+	// A new cache, e.g. of another store replica, shares the items.
+	c = newMemcachedIndexCache(nil, nil, mc)
+	defer c.close()
+
+	v, ok := c.postings(id, l)
+	testutil.Assert(t, ok, "expected postings to be cached")
+	testutil.Equals(t, []byte("postings"), v)
+
+	_, ok = c.postings(id, labels.Label{Name: "a", Value: "other"})
+	testutil.Assert(t, !ok, "expected postings of another label to be missing")
+
+	hits, misses := c.fetchMultiSeries(id, []uint64{1, 2, 3})
+	testutil.Equals(t, map[uint64][]byte{1: []byte("series 1"), 2: []byte("series 2")}, hits)
+	testutil.Equals(t, []uint64{3}, misses)
+}
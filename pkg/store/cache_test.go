@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/improbable-eng/thanos/pkg/runutil"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/tsdb/labels"
+)
+
+func TestIndexCache_Memory(t *testing.T) {
+	c, err := newInMemoryIndexCache(nil, nil, 10, "", 0)
+	testutil.Ok(t, err)
+
+	id := ulid.MustNew(0, nil)
+	l := labels.Label{Name: "a", Value: "1"}
+
+	c.setPostings(id, l, []byte("1234"))
+	c.setSeries(id, 1, []byte("1234"))
+	testutil.Equals(t, uint64(8), c.curSize)
+
+	// Setting the same item again must not be accounted twice.
+	c.setSeries(id, 1, []byte("1234"))
+	testutil.Equals(t, uint64(8), c.curSize)
+
+	// Items larger than the cache are skipped.
+	c.setSeries(id, 2, []byte("12345678901"))
+	hits, misses := c.fetchMultiSeries(id, []uint64{1, 2})
+	testutil.Equals(t, map[uint64][]byte{1: []byte("1234")}, hits)
+	testutil.Equals(t, []uint64{2}, misses)
+
+	// Postings are the least recently used so they should be evicted.
+	c.setSeries(id, 3, []byte("123"))
+	_, ok := c.postings(id, l)
+	testutil.Assert(t, !ok, "expected postings to be evicted")
+	testutil.Equals(t, uint64(7), c.curSize)
+}
+
+func TestIndexCache_Disk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_index_cache_disk")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	c, err := newInMemoryIndexCache(nil, nil, 5, dir, 13)
+	testutil.Ok(t, err)
+
+	id := ulid.MustNew(0, nil)
+	l := labels.Label{Name: "a", Value: ""}
+
+	c.setPostings(id, l, []byte("1234"))
+	c.setSeries(id, 1, []byte("1234"))
+
+	// Postings were evicted from memory, but are still available from disk once written.
+	testutil.Equals(t, 1, c.lru.Len())
+	waitDiskItems(t, c.disk, 1)
+	v, ok := c.postings(id, l)
+	testutil.Assert(t, ok, "expected postings to be cached on disk")
+	testutil.Equals(t, []byte("1234"), v)
+
+	// Series are spilled now. Postings are stored along with their label.
+	waitDiskItems(t, c.disk, 2)
+	testutil.Equals(t, uint64(4+7), c.disk.curSize)
+
+	// Items held in memory are spilled on close, postings are not written twice. The disk tier is bounded,
+	// so the least recently used postings are evicted.
+	c.setSeries(id, 2, []byte("123"))
+	c.close()
+	testutil.Equals(t, 2, c.disk.lru.Len())
+
+	c, err = newInMemoryIndexCache(nil, nil, 5, dir, 13)
+	testutil.Ok(t, err)
+
+	testutil.Equals(t, 2, c.disk.lru.Len())
+	testutil.Equals(t, uint64(7), c.disk.curSize)
+	_, ok = c.postings(id, l)
+	testutil.Assert(t, !ok, "expected postings to be evicted from disk")
+	hits, misses := c.fetchMultiSeries(id, []uint64{1, 2})
+	testutil.Equals(t, map[uint64][]byte{1: []byte("1234"), 2: []byte("123")}, hits)
+	testutil.Equals(t, 0, len(misses))
+}
+
+func TestIndexCache_DiskLongLabel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_index_cache_disk_long_label")
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, os.RemoveAll(dir)) }()
+
+	c, err := newInMemoryIndexCache(nil, nil, 5, dir, 1e6)
+	testutil.Ok(t, err)
+
+	id := ulid.MustNew(0, nil)
+	l := labels.Label{Name: strings.Repeat("a", 200), Value: strings.Repeat("b", 300)}
+
+	c.setPostings(id, l, []byte("1234"))
+	c.close()
+
+	c, err = newInMemoryIndexCache(nil, nil, 5, dir, 1e6)
+	testutil.Ok(t, err)
+	defer c.close()
+
+	testutil.Equals(t, 1, c.disk.lru.Len())
+	v, ok := c.postings(id, l)
+	testutil.Assert(t, ok, "expected postings to be cached on disk")
+	testutil.Equals(t, []byte("1234"), v)
+
+	_, ok = c.postings(id, labels.Label{Name: l.Name, Value: l.Value + "c"})
+	testutil.Assert(t, !ok, "expected postings of another label not to be found")
+}
+
+// waitDiskItems waits until the given number of items is written to the disk tier.
+func waitDiskItems(t *testing.T, c *diskIndexCache, n int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	testutil.Ok(t, runutil.Retry(10*time.Millisecond, ctx.Done(), func() error {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+
+		if c.lru.Len() != n {
+			return errors.Errorf("expected %d items, got %d", n, c.lru.Len())
+		}
+		return nil
+	}))
+}
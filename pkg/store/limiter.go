@@ -0,0 +1,50 @@
+package store
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// limitExceededError is returned when a request exceeds a configured limit.
+type limitExceededError struct {
+	what  string
+	limit uint64
+	got   uint64
+}
+
+func (e limitExceededError) Error() string {
+	return fmt.Sprintf("%s limit %d exceeded (got at least %d), narrow down the time range or matchers of the query", e.what, e.limit, e.got)
+}
+
+// limiter checks if the amount of data reserved by a single request has passed a threshold.
+// It is safe to use concurrently.
+type limiter struct {
+	what     string
+	limit    uint64
+	reserved uint64
+	dropped  prometheus.Counter
+}
+
+// newLimiter returns a new limiter of the given amount of data. Zero limit means no limit. The dropped counter
+// is incremented once if the limit is exceeded.
+func newLimiter(what string, limit uint64, dropped prometheus.Counter) *limiter {
+	return &limiter{what: what, limit: limit, dropped: dropped}
+}
+
+// Reserve reserves the given amount and returns limitExceededError if the limit is exceeded.
+func (l *limiter) Reserve(num uint64) error {
+	if l.limit == 0 {
+		return nil
+	}
+	reserved := atomic.AddUint64(&l.reserved, num)
+	if reserved <= l.limit {
+		return nil
+	}
+	// Count only the first reservation over the limit.
+	if reserved-num <= l.limit {
+		l.dropped.Inc()
+	}
+	return limitExceededError{what: l.what, limit: l.limit, got: reserved}
+}
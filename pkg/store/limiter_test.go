@@ -0,0 +1,31 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestLimiter(t *testing.T) {
+	c := prometheus.NewCounter(prometheus.CounterOpts{})
+	l := newLimiter("series", 10, c)
+
+	testutil.Ok(t, l.Reserve(5))
+	testutil.Ok(t, l.Reserve(5))
+
+	err := l.Reserve(1)
+	testutil.NotOk(t, err)
+	testutil.Equals(t, limitExceededError{what: "series", limit: 10, got: 11}, err)
+
+	testutil.NotOk(t, l.Reserve(2))
+
+	var m dto.Metric
+	testutil.Ok(t, c.Write(&m))
+	testutil.Equals(t, float64(1), m.GetCounter().GetValue())
+
+	// Zero limit means no limit.
+	l = newLimiter("series", 0, c)
+	testutil.Ok(t, l.Reserve(1e9))
+}
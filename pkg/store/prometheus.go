@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"path"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/go-kit/kit/log"
@@ -27,6 +30,10 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// remoteReadFrameLimit is the maximum size of a single frame of the streamed remote read response.
+// Prometheus splits series into frames of 1MB by default.
+const remoteReadFrameLimit = 50 * 1024 * 1024
+
 // PrometheusStore implements the store node API on top of the Prometheus remote read API.
 type PrometheusStore struct {
 	logger         log.Logger
@@ -35,17 +42,20 @@ type PrometheusStore struct {
 	buffers        sync.Pool
 	externalLabels func() labels.Labels
 	timestamps     func() (mint int64, maxt int64)
+	tenantLabel    string
 }
 
 // NewPrometheusStore returns a new PrometheusStore that uses the given HTTP client
 // to talk to Prometheus.
 // It attaches the provided external labels to all results.
+// If tenantLabel is not empty, only requests of the tenant given by the external label of that name are served.
 func NewPrometheusStore(
 	logger log.Logger,
 	client *http.Client,
 	baseURL *url.URL,
 	externalLabels func() labels.Labels,
 	timestamps func() (mint int64, maxt int64),
+	tenantLabel string,
 ) (*PrometheusStore, error) {
 	if logger == nil {
 		logger = log.NewNopLogger()
@@ -61,6 +71,7 @@ func NewPrometheusStore(
 		client:         client,
 		externalLabels: externalLabels,
 		timestamps:     timestamps,
+		tenantLabel:    tenantLabel,
 	}
 	return p, nil
 }
@@ -102,6 +113,14 @@ func (p *PrometheusStore) putBuffer(b []byte) {
 func (p *PrometheusStore) Series(r *storepb.SeriesRequest, s storepb.Store_SeriesServer) error {
 	ext := p.externalLabels()
 
+	tenant, err := requestTenant(s.Context(), p.tenantLabel)
+	if err != nil {
+		return err
+	}
+	if !tenantMatches(p.tenantLabel, tenant, ext) {
+		return nil
+	}
+
 	match, newMatchers, err := labelsMatches(ext, r.Matchers)
 	if err != nil {
 		return status.Error(codes.InvalidArgument, err.Error())
@@ -109,6 +128,9 @@ func (p *PrometheusStore) Series(r *storepb.SeriesRequest, s storepb.Store_Serie
 	if !match {
 		return nil
 	}
+	if r.SkipChunks {
+		return p.seriesLabels(s, r.MinTime, r.MaxTime, newMatchers, ext)
+	}
 	q := prompb.Query{StartTimestampMs: r.MinTime, EndTimestampMs: r.MaxTime}
 
 	// TODO(fabxc): import common definitions from prompb once we have a stable gRPC
@@ -131,7 +153,25 @@ func (p *PrometheusStore) Series(r *storepb.SeriesRequest, s storepb.Store_Serie
 		q.Matchers = append(q.Matchers, pm)
 	}
 
-	resp, err := p.promSeries(s.Context(), q)
+	httpResp, err := p.startPromSeries(s.Context(), q)
+	if err != nil {
+		return errors.Wrap(err, "query Prometheus")
+	}
+	defer runutil.CloseWithLogOnErr(p.logger, httpResp.Body, "prom series request body")
+
+	// Prometheus older than v2.13.0 does not support streamed remote read and ignores accepted response types.
+	contentType := httpResp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/x-protobuf") {
+		return p.handleSampledPrometheusResponse(s, httpResp, ext)
+	}
+	if !strings.HasPrefix(contentType, prompb.StreamedXORChunksContentType) {
+		return errors.Errorf("unsupported remote read content type %q", contentType)
+	}
+	return p.handleStreamedPrometheusResponse(s, httpResp, ext)
+}
+
+func (p *PrometheusStore) handleSampledPrometheusResponse(s storepb.Store_SeriesServer, httpResp *http.Response, ext labels.Labels) error {
+	resp, err := p.fetchSampledResponse(s.Context(), httpResp)
 	if err != nil {
 		return errors.Wrap(err, "query Prometheus")
 	}
@@ -176,11 +216,83 @@ func (p *PrometheusStore) Series(r *storepb.SeriesRequest, s storepb.Store_Serie
 	return nil
 }
 
-func (p *PrometheusStore) promSeries(ctx context.Context, q prompb.Query) (*prompb.ReadResponse, error) {
+// handleStreamedPrometheusResponse forwards chunks of the streamed remote read response frame by frame,
+// so at most a single frame and a single series are held in memory.
+func (p *PrometheusStore) handleStreamedPrometheusResponse(s storepb.Store_SeriesServer, httpResp *http.Response, ext labels.Labels) error {
+	span, _ := tracing.StartSpan(s.Context(), "transform_and_respond")
+	defer span.Finish()
+
+	buf := p.getBuffer()
+	defer func() {
+		p.putBuffer(buf)
+	}()
+
+	var (
+		r = prompb.NewChunkedReader(httpResp.Body, remoteReadFrameLimit, buf)
+		// Chunks of a single series can be split across consecutive frames. They are sent as a single series.
+		cur *storepb.Series
+	)
+	flush := func() error {
+		if cur == nil || len(cur.Chunks) == 0 {
+			return nil
+		}
+		return s.Send(storepb.NewSeriesResponse(cur))
+	}
+	for {
+		var res prompb.ChunkedReadResponse
+		if err := r.NextProto(&res); err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "read chunked response frame")
+		}
+		if res.QueryIndex != 0 {
+			return errors.Errorf("unexpected query index %d in chunked response", res.QueryIndex)
+		}
+
+		for _, series := range res.ChunkedSeries {
+			chks, err := translateChunks(series.Chunks)
+			if err != nil {
+				return status.Error(codes.Unknown, err.Error())
+			}
+			lset := p.translateAndExtendLabels(series.Labels, ext)
+
+			if cur != nil && storepb.CompareLabels(cur.Labels, lset) == 0 {
+				cur.Chunks = append(cur.Chunks, chks...)
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			cur = &storepb.Series{Labels: lset, Chunks: chks}
+		}
+	}
+	return flush()
+}
+
+// translateChunks converts Prometheus chunks into StoreAPI raw chunks without re-encoding them.
+func translateChunks(cs []prompb.Chunk) ([]storepb.AggrChunk, error) {
+	res := make([]storepb.AggrChunk, 0, len(cs))
+	for _, c := range cs {
+		if c.Type != prompb.Chunk_XOR {
+			return nil, errors.Errorf("unsupported chunk encoding %s", c.Type)
+		}
+		res = append(res, storepb.AggrChunk{
+			MinTime: c.MinTimeMs,
+			MaxTime: c.MaxTimeMs,
+			Raw:     &storepb.Chunk{Type: storepb.Chunk_XOR, Data: c.Data},
+		})
+	}
+	return res, nil
+}
+
+func (p *PrometheusStore) startPromSeries(ctx context.Context, q prompb.Query) (*http.Response, error) {
 	span, ctx := tracing.StartSpan(ctx, "query_prometheus")
 	defer span.Finish()
 
-	reqb, err := proto.Marshal(&prompb.ReadRequest{Queries: []prompb.Query{q}})
+	reqb, err := proto.Marshal(&prompb.ReadRequest{
+		Queries:               []prompb.Query{q},
+		AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS},
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "marshal read request")
 	}
@@ -202,17 +314,22 @@ func (p *PrometheusStore) promSeries(ctx context.Context, q prompb.Query) (*prom
 	if err != nil {
 		return nil, errors.Wrap(err, "send request")
 	}
-	defer runutil.CloseWithLogOnErr(p.logger, presp.Body, "prom series request body")
-
 	if presp.StatusCode/100 != 2 {
+		runutil.CloseWithLogOnErr(p.logger, presp.Body, "prom series request body")
 		return nil, errors.Errorf("request failed with code %s", presp.Status)
 	}
+	return presp, nil
+}
+
+func (p *PrometheusStore) fetchSampledResponse(ctx context.Context, resp *http.Response) (*prompb.ReadResponse, error) {
+	span, _ := tracing.StartSpan(ctx, "fetch_sampled_response")
+	defer span.Finish()
 
 	buf := bytes.NewBuffer(p.getBuffer())
 	defer func() {
 		p.putBuffer(buf.Bytes())
 	}()
-	if _, err := io.Copy(buf, presp.Body); err != nil {
+	if _, err := io.Copy(buf, resp.Body); err != nil {
 		return nil, errors.Wrap(err, "copy response")
 	}
 	decomp, err := snappy.Decode(p.getBuffer(), buf.Bytes())
@@ -305,19 +422,194 @@ func extendLset(lset []storepb.Label, extend labels.Labels) []storepb.Label {
 	return lset
 }
 
-// LabelNames returns all known label names.
+// seriesLabels sends the label sets of series matching the matchers without chunks. They are fetched
+// from the series API of Prometheus, so no samples have to be read.
+func (p *PrometheusStore) seriesLabels(s storepb.Store_SeriesServer, mint, maxt int64, ms []storepb.LabelMatcher, ext labels.Labels) error {
+	sel, err := seriesSelector(ms)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	q := url.Values{}
+	q.Add("match[]", sel)
+	q.Set("start", formatTime(mint))
+	q.Set("end", formatTime(maxt))
+
+	u := *p.base
+	u.Path = path.Join(u.Path, "/api/v1/series")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+
+	span, ctx := tracing.StartSpan(s.Context(), "/prom_series HTTP[client]")
+	defer span.Finish()
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "query Prometheus")
+	}
+	defer runutil.CloseWithLogOnErr(p.logger, resp.Body, "series request body")
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("request Prometheus server failed, code %s", resp.Status)
+	}
+
+	var m struct {
+		Data []map[string]string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return errors.Wrap(err, "decode series response")
+	}
+
+	for _, series := range m.Data {
+		lset := make([]storepb.Label, 0, len(series)+len(ext))
+		for n, v := range series {
+			if ext.Get(n) != "" {
+				continue
+			}
+			lset = append(lset, storepb.Label{Name: n, Value: v})
+		}
+		if err := s.Send(storepb.NewSeriesResponse(&storepb.Series{Labels: extendLset(lset, ext)})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seriesSelector returns a PromQL series selector for the matchers. Prometheus rejects selectors
+// matching the empty string only, so a metric name matcher is added for them.
+func seriesSelector(ms []storepb.LabelMatcher) (string, error) {
+	var (
+		parts    []string
+		nonEmpty bool
+	)
+	for _, m := range ms {
+		tm, err := translateMatcher(m)
+		if err != nil {
+			return "", err
+		}
+		if !tm.Matches("") {
+			nonEmpty = true
+		}
+
+		var op string
+		switch m.Type {
+		case storepb.LabelMatcher_EQ:
+			op = "="
+		case storepb.LabelMatcher_NEQ:
+			op = "!="
+		case storepb.LabelMatcher_RE:
+			op = "=~"
+		case storepb.LabelMatcher_NRE:
+			op = "!~"
+		}
+		parts = append(parts, fmt.Sprintf("%s%s%q", m.Name, op, m.Value))
+	}
+	if !nonEmpty {
+		parts = append(parts, `__name__=~".+"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}", nil
+}
+
+// LabelNames returns all known label names including the external ones.
 func (p *PrometheusStore) LabelNames(ctx context.Context, r *storepb.LabelNamesRequest) (
 	*storepb.LabelNamesResponse, error,
 ) {
-	return nil, status.Error(codes.Unimplemented, "not implemented")
+	tenant, err := requestTenant(ctx, p.tenantLabel)
+	if err != nil {
+		return nil, err
+	}
+	ext := p.externalLabels()
+	if !tenantMatches(p.tenantLabel, tenant, ext) {
+		return &storepb.LabelNamesResponse{}, nil
+	}
+
+	match, _, err := labelsMatches(ext, r.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !match {
+		return &storepb.LabelNamesResponse{}, nil
+	}
+
+	u := *p.base
+	u.Path = path.Join(u.Path, "/api/v1/labels")
+	u.RawQuery = labelsRequestQuery(r.TimeRange).Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+
+	span, ctx := tracing.StartSpan(ctx, "/prom_label_names HTTP[client]")
+	defer span.Finish()
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+	defer runutil.CloseWithLogOnErr(p.logger, resp.Body, "label names request body")
+
+	// Label names API is available since Prometheus v2.6.0. Report older versions as a warning, so the
+	// lack of it does not fail the whole request.
+	if resp.StatusCode == http.StatusNotFound {
+		return &storepb.LabelNamesResponse{
+			Warnings: []string{fmt.Sprintf("Prometheus %s does not support label names API, required Prometheus v2.6.0+", p.base)},
+		}, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("request Prometheus server failed, code %s", resp.Status))
+	}
+
+	var m struct {
+		Data []string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+
+	uniq := make(map[string]struct{}, len(m.Data))
+	for _, n := range m.Data {
+		uniq[n] = struct{}{}
+	}
+	for _, l := range ext {
+		uniq[l.Name] = struct{}{}
+	}
+	names := make([]string, 0, len(uniq))
+	for n := range uniq {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return &storepb.LabelNamesResponse{Names: names}, nil
 }
 
 // LabelValues returns all known label values for a given label name.
 func (p *PrometheusStore) LabelValues(ctx context.Context, r *storepb.LabelValuesRequest) (
 	*storepb.LabelValuesResponse, error,
 ) {
+	tenant, err := requestTenant(ctx, p.tenantLabel)
+	if err != nil {
+		return nil, err
+	}
+	ext := p.externalLabels()
+	if !tenantMatches(p.tenantLabel, tenant, ext) {
+		return &storepb.LabelValuesResponse{}, nil
+	}
+
+	match, _, err := labelsMatches(ext, r.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !match {
+		return &storepb.LabelValuesResponse{}, nil
+	}
+
 	u := *p.base
 	u.Path = path.Join(u.Path, "/api/v1/label/", r.Label, "/values")
+	u.RawQuery = labelsRequestQuery(r.TimeRange).Encode()
 
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
@@ -343,3 +635,24 @@ func (p *PrometheusStore) LabelValues(ctx context.Context, r *storepb.LabelValue
 
 	return &storepb.LabelValuesResponse{Values: m.Data}, nil
 }
+
+// labelsRequestQuery returns Prometheus HTTP API query parameters for the optional time range of label names
+// or values request. Prometheus versions not supporting time range for label APIs ignore them.
+func labelsRequestQuery(r *storepb.TimeRange) url.Values {
+	q := url.Values{}
+	if r == nil {
+		return q
+	}
+	if r.MinTime != math.MinInt64 {
+		q.Set("start", formatTime(r.MinTime))
+	}
+	if r.MaxTime != math.MaxInt64 {
+		q.Set("end", formatTime(r.MaxTime))
+	}
+	return q
+}
+
+// formatTime formats milliseconds timestamp as Unix time in seconds accepted by Prometheus HTTP API.
+func formatTime(t int64) string {
+	return strconv.FormatFloat(float64(t)/1000, 'f', -1, 64)
+}
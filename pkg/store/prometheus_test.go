@@ -2,12 +2,21 @@ package store
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
 
 	"github.com/fortytw2/leaktest"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/improbable-eng/thanos/pkg/store/prompb"
 	"github.com/improbable-eng/thanos/pkg/store/storepb"
 	"github.com/improbable-eng/thanos/pkg/testutil"
 	"github.com/prometheus/prometheus/pkg/timestamp"
@@ -55,7 +64,7 @@ func testPrometheusStoreSeriesE2e(t *testing.T, prefix string) {
 	proxy, err := NewPrometheusStore(nil, nil, u,
 		func() labels.Labels {
 			return labels.FromStrings("region", "eu-west")
-		}, nil)
+		}, nil, "")
 	testutil.Ok(t, err)
 
 	// Query all three samples except for the first one. Since we round up queried data
@@ -126,7 +135,9 @@ func TestPrometheusStore_LabelValues_e2e(t *testing.T) {
 	u, err := url.Parse(fmt.Sprintf("http://%s", p.Addr()))
 	testutil.Ok(t, err)
 
-	proxy, err := NewPrometheusStore(nil, nil, u, nil, nil)
+	proxy, err := NewPrometheusStore(nil, nil, u, func() labels.Labels {
+		return labels.FromStrings("region", "eu-west")
+	}, nil, "")
 	testutil.Ok(t, err)
 
 	resp, err := proxy.LabelValues(ctx, &storepb.LabelValuesRequest{
@@ -135,6 +146,214 @@ func TestPrometheusStore_LabelValues_e2e(t *testing.T) {
 	testutil.Ok(t, err)
 
 	testutil.Equals(t, []string{"a", "b", "c"}, resp.Values)
+
+	// Not matching external labels should result in no values.
+	resp, err = proxy.LabelValues(ctx, &storepb.LabelValuesRequest{
+		Label:    "a",
+		Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "region", Value: "us-east"}},
+	})
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(resp.Values))
+}
+
+func TestPrometheusStore_LabelNames(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/labels" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`{"status":"success","data":["__name__","a","region"]}`))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	extLabels := func() labels.Labels {
+		return labels.FromStrings("region", "eu-west", "replica", "1")
+	}
+	proxy, err := NewPrometheusStore(nil, nil, u, extLabels, nil, "")
+	testutil.Ok(t, err)
+
+	resp, err := proxy.LabelNames(context.Background(), &storepb.LabelNamesRequest{})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"__name__", "a", "region", "replica"}, resp.Names)
+	testutil.Equals(t, 0, len(resp.Warnings))
+	testutil.Equals(t, 0, len(query))
+
+	// Requested time range should be passed to Prometheus.
+	resp, err = proxy.LabelNames(context.Background(), &storepb.LabelNamesRequest{TimeRange: &storepb.TimeRange{MinTime: 1500, MaxTime: 3000}})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"__name__", "a", "region", "replica"}, resp.Names)
+	testutil.Equals(t, "1.5", query.Get("start"))
+	testutil.Equals(t, "3", query.Get("end"))
+
+	// Not matching external labels should result in no names without asking Prometheus.
+	query = nil
+	resp, err = proxy.LabelNames(context.Background(), &storepb.LabelNamesRequest{
+		Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "region", Value: "us-east"}},
+	})
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(resp.Names))
+	testutil.Assert(t, query == nil, "expected no request to Prometheus")
+
+	// Prometheus versions older than v2.6.0 do not have label names API.
+	u.Path = "/old"
+	proxy, err = NewPrometheusStore(nil, nil, u, extLabels, nil, "")
+	testutil.Ok(t, err)
+
+	resp, err = proxy.LabelNames(context.Background(), &storepb.LabelNamesRequest{})
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(resp.Names))
+	testutil.Equals(t, 1, len(resp.Warnings))
+}
+
+func TestPrometheusStore_Series_SkipChunks(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/series" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"up","a":"1","region":"local"},{"__name__":"up","a":"2"}]}`))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	proxy, err := NewPrometheusStore(nil, nil, u, func() labels.Labels { return labels.FromStrings("region", "eu-west") }, nil, "")
+	testutil.Ok(t, err)
+
+	seriesSrv := newStoreSeriesServer(context.Background())
+	testutil.Ok(t, proxy.Series(&storepb.SeriesRequest{
+		MinTime: 1500,
+		MaxTime: 3000,
+		Matchers: []storepb.LabelMatcher{
+			{Type: storepb.LabelMatcher_EQ, Name: "__name__", Value: "up"},
+			{Type: storepb.LabelMatcher_RE, Name: "region", Value: "eu-.*"},
+		},
+		SkipChunks: true,
+	}, seriesSrv))
+
+	// Matchers of external labels are not passed to Prometheus.
+	testutil.Equals(t, []string{`{__name__="up"}`}, query["match[]"])
+	testutil.Equals(t, "1.5", query.Get("start"))
+	testutil.Equals(t, "3", query.Get("end"))
+	testutil.Equals(t, []storepb.Series{
+		{Labels: []storepb.Label{{Name: "__name__", Value: "up"}, {Name: "a", Value: "1"}, {Name: "region", Value: "eu-west"}}},
+		{Labels: []storepb.Label{{Name: "__name__", Value: "up"}, {Name: "a", Value: "2"}, {Name: "region", Value: "eu-west"}}},
+	}, seriesSrv.SeriesSet)
+
+	// Selectors must contain a matcher not matching the empty string.
+	testutil.Ok(t, proxy.Series(&storepb.SeriesRequest{
+		MinTime:    1500,
+		MaxTime:    3000,
+		Matchers:   []storepb.LabelMatcher{{Type: storepb.LabelMatcher_NEQ, Name: "a", Value: "3"}},
+		SkipChunks: true,
+	}, newStoreSeriesServer(context.Background())))
+	testutil.Equals(t, []string{`{a!="3",__name__=~".+"}`}, query["match[]"])
+}
+
+func TestPrometheusStore_Series_StreamedChunks(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	newChunk := func(samples ...sample) prompb.Chunk {
+		c := chunkenc.NewXORChunk()
+		a, err := c.Appender()
+		testutil.Ok(t, err)
+		for _, s := range samples {
+			a.Append(s.t, s.v)
+		}
+		return prompb.Chunk{
+			MinTimeMs: samples[0].t,
+			MaxTimeMs: samples[len(samples)-1].t,
+			Type:      prompb.Chunk_XOR,
+			Data:      c.Bytes(),
+		}
+	}
+	ab := []prompb.Label{{Name: "a", Value: "b"}}
+	ac := []prompb.Label{{Name: "a", Value: "c"}}
+
+	// Series a="b" is split across two frames.
+	frames := []prompb.ChunkedReadResponse{
+		{ChunkedSeries: []prompb.ChunkedSeries{{Labels: ab, Chunks: []prompb.Chunk{newChunk(sample{100, 1}, sample{200, 2})}}}},
+		{ChunkedSeries: []prompb.ChunkedSeries{
+			{Labels: ab, Chunks: []prompb.Chunk{newChunk(sample{300, 3})}},
+			{Labels: ac, Chunks: []prompb.Chunk{newChunk(sample{100, 4})}},
+		}},
+	}
+
+	var req prompb.ReadRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		testutil.Ok(t, err)
+		b, err = snappy.Decode(nil, b)
+		testutil.Ok(t, err)
+		testutil.Ok(t, proto.Unmarshal(b, &req))
+
+		w.Header().Set("Content-Type", prompb.StreamedXORChunksContentType)
+		for _, f := range frames {
+			testutil.Ok(t, writeChunkedFrame(w, &f))
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	testutil.Ok(t, err)
+
+	proxy, err := NewPrometheusStore(nil, nil, u,
+		func() labels.Labels {
+			return labels.FromStrings("region", "eu-west")
+		}, nil, "")
+	testutil.Ok(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ssrv := newStoreSeriesServer(ctx)
+	testutil.Ok(t, proxy.Series(&storepb.SeriesRequest{
+		MinTime:  0,
+		MaxTime:  300,
+		Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_RE, Name: "a", Value: ".+"}},
+	}, ssrv))
+
+	testutil.Equals(t, []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS}, req.AcceptedResponseTypes)
+	testutil.Equals(t, 2, len(ssrv.SeriesSet))
+
+	testutil.Equals(t, []storepb.Label{{Name: "a", Value: "b"}, {Name: "region", Value: "eu-west"}}, ssrv.SeriesSet[0].Labels)
+	testutil.Equals(t, 2, len(ssrv.SeriesSet[0].Chunks))
+	var samples []sample
+	for _, c := range ssrv.SeriesSet[0].Chunks {
+		testutil.Equals(t, storepb.Chunk_XOR, c.Raw.Type)
+		chk, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+		testutil.Ok(t, err)
+		samples = append(samples, expandChunk(chk.Iterator())...)
+	}
+	testutil.Equals(t, []sample{{100, 1}, {200, 2}, {300, 3}}, samples)
+	testutil.Equals(t, int64(300), ssrv.SeriesSet[0].Chunks[1].MinTime)
+
+	testutil.Equals(t, []storepb.Label{{Name: "a", Value: "c"}, {Name: "region", Value: "eu-west"}}, ssrv.SeriesSet[1].Labels)
+	testutil.Equals(t, 1, len(ssrv.SeriesSet[1].Chunks))
+}
+
+// writeChunkedFrame writes a single frame of the streamed remote read response the way Prometheus does.
+func writeChunkedFrame(w io.Writer, res *prompb.ChunkedReadResponse) error {
+	b, err := proto.Marshal(res)
+	if err != nil {
+		return err
+	}
+	var hdr [binary.MaxVarintLen64 + 4]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(b)))
+	binary.BigEndian.PutUint32(hdr[n:], crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)))
+	if _, err := w.Write(hdr[:n+4]); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
 }
 
 func TestPrometheusStore_Series_MatchExternalLabel_e2e(t *testing.T) {
@@ -166,7 +385,7 @@ func TestPrometheusStore_Series_MatchExternalLabel_e2e(t *testing.T) {
 	proxy, err := NewPrometheusStore(nil, nil, u,
 		func() labels.Labels {
 			return labels.FromStrings("region", "eu-west")
-		}, nil)
+		}, nil, "")
 	testutil.Ok(t, err)
 	srv := newStoreSeriesServer(ctx)
 
@@ -215,7 +434,7 @@ func TestPrometheusStore_Info(t *testing.T) {
 		},
 		func() (int64, int64) {
 			return 123, 456
-		})
+		}, "")
 	testutil.Ok(t, err)
 
 	resp, err := proxy.Info(ctx, &storepb.InfoRequest{})
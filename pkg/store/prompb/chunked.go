@@ -0,0 +1,87 @@
+package prompb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// StreamedXORChunksContentType is the content type of the remote read response with response type STREAMED_XOR_CHUNKS.
+const StreamedXORChunksContentType = "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse"
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkedReader reads frames of the streamed remote read response. Each frame holds a single
+// ChunkedReadResponse message and is encoded as:
+//
+//   size of data(uvarint) | CRC32 Castagnoli of data(big-endian uint32) | data
+type ChunkedReader struct {
+	b         *bufio.Reader
+	data      []byte
+	sizeLimit uint64
+	crc32     hash.Hash32
+}
+
+// NewChunkedReader returns a new ChunkedReader reading from r. Frames larger than sizeLimit bytes are rejected.
+// The data buffer is reused for consecutive frames.
+func NewChunkedReader(r io.Reader, sizeLimit uint64, data []byte) *ChunkedReader {
+	return &ChunkedReader{
+		b:         bufio.NewReader(r),
+		data:      data,
+		sizeLimit: sizeLimit,
+		crc32:     crc32.New(castagnoliTable),
+	}
+}
+
+// Next returns data of the next frame or io.EOF if there are no more frames.
+// The returned slice is valid only until the next call.
+func (r *ChunkedReader) Next() ([]byte, error) {
+	size, err := binary.ReadUvarint(r.b)
+	if err != nil {
+		return nil, err
+	}
+	if size > r.sizeLimit {
+		return nil, errors.Errorf("frame size %d exceeds the limit of %d bytes", size, r.sizeLimit)
+	}
+	if uint64(cap(r.data)) < size {
+		r.data = make([]byte, size)
+	} else {
+		r.data = r.data[:size]
+	}
+
+	var crc [4]byte
+	if _, err := io.ReadFull(r.b, crc[:]); err != nil {
+		return nil, errors.Wrap(unexpectedEOF(err), "read checksum")
+	}
+	r.crc32.Reset()
+	if _, err := io.ReadFull(io.TeeReader(r.b, r.crc32), r.data); err != nil {
+		return nil, errors.Wrap(unexpectedEOF(err), "read frame")
+	}
+	if exp := binary.BigEndian.Uint32(crc[:]); exp != r.crc32.Sum32() {
+		return nil, errors.Errorf("frame checksum mismatch: expected %x, got %x", exp, r.crc32.Sum32())
+	}
+	return r.data, nil
+}
+
+// NextProto unmarshals the next frame into pb. It returns io.EOF if there are no more frames.
+func (r *ChunkedReader) NextProto(pb proto.Message) error {
+	data, err := r.Next()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, pb)
+}
+
+// unexpectedEOF converts io.EOF in the middle of a frame into io.ErrUnexpectedEOF, so a truncated
+// response is not mistaken for its end.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package prompb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/improbable-eng/thanos/pkg/testutil"
+)
+
+func appendFrame(b []byte, data []byte) []byte {
+	var hdr [binary.MaxVarintLen64 + 4]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(data)))
+	binary.BigEndian.PutUint32(hdr[n:], crc32.Checksum(data, castagnoliTable))
+	return append(append(b, hdr[:n+4]...), data...)
+}
+
+func TestChunkedReader(t *testing.T) {
+	var b []byte
+	b = appendFrame(b, []byte("test1"))
+	b = appendFrame(b, []byte("test12345678"))
+	b = appendFrame(b, []byte{})
+
+	r := NewChunkedReader(bytes.NewReader(b), 20, nil)
+	for _, exp := range []string{"test1", "test12345678", ""} {
+		data, err := r.Next()
+		testutil.Ok(t, err)
+		testutil.Equals(t, exp, string(data))
+	}
+	_, err := r.Next()
+	testutil.Equals(t, io.EOF, err)
+
+	// Frames over the size limit are rejected.
+	r = NewChunkedReader(bytes.NewReader(b), 10, nil)
+	_, err = r.Next()
+	testutil.Ok(t, err)
+	_, err = r.Next()
+	testutil.NotOk(t, err)
+
+	// Truncated frame must not be mistaken for the end of the response.
+	r = NewChunkedReader(bytes.NewReader(b[:len(b)-10]), 20, nil)
+	_, err = r.Next()
+	testutil.Ok(t, err)
+	_, err = r.Next()
+	testutil.NotOk(t, err)
+	testutil.Assert(t, err != io.EOF, "expected unexpected EOF error, got EOF")
+
+	// Corrupted frame is detected by checksum.
+	b[len(b)-6]++
+	r = NewChunkedReader(bytes.NewReader(b), 20, nil)
+	_, err = r.Next()
+	testutil.Ok(t, err)
+	_, err = r.Next()
+	testutil.NotOk(t, err)
+}
+
+func TestChunkedReader_NextProto(t *testing.T) {
+	exp := ChunkedReadResponse{
+		ChunkedSeries: []ChunkedSeries{{
+			Labels: []Label{{Name: "a", Value: "b"}},
+			Chunks: []Chunk{{MinTimeMs: 1, MaxTimeMs: 100, Type: Chunk_XOR, Data: []byte{1, 2, 3}}},
+		}},
+	}
+	data, err := exp.Marshal()
+	testutil.Ok(t, err)
+
+	r := NewChunkedReader(bytes.NewReader(appendFrame(nil, data)), 1024, nil)
+	var res ChunkedReadResponse
+	testutil.Ok(t, r.NextProto(&res))
+	testutil.Equals(t, exp, res)
+	testutil.Equals(t, io.EOF, r.NextProto(&res))
+}
@@ -6,6 +6,7 @@ import (
 	"math"
 	"strings"
 	"sync"
+	"time"
 
 	"fmt"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/improbable-eng/thanos/pkg/store/storepb"
 	"github.com/improbable-eng/thanos/pkg/strutil"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/tsdb/labels"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc/codes"
@@ -31,30 +33,69 @@ type Client interface {
 	// Minimum and maximum time range of data in the store.
 	TimeRange() (mint int64, maxt int64)
 
+	// Addr returns the address of the store. It is used to identify the store in metrics.
+	Addr() string
+
 	String() string
 }
 
+const (
+	timeoutFirstByte = "first_byte"
+	timeoutResponse  = "response"
+)
+
+type proxyStoreMetrics struct {
+	storeTimeouts *prometheus.CounterVec
+}
+
+func newProxyStoreMetrics(reg prometheus.Registerer) *proxyStoreMetrics {
+	var m proxyStoreMetrics
+
+	m.storeTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_proxy_store_timeouts_total",
+		Help: "Total number of series requests dropped because the store did not respond within the configured timeout.",
+	}, []string{"store", "timeout"})
+
+	if reg != nil {
+		reg.MustRegister(m.storeTimeouts)
+	}
+	return &m
+}
+
 // ProxyStore implements the store API that proxies request to all given underlying stores.
 type ProxyStore struct {
 	logger         log.Logger
+	metrics        *proxyStoreMetrics
 	stores         func(context.Context) ([]Client, error)
 	selectorLabels labels.Labels
+
+	firstByteTimeout time.Duration
+	responseTimeout  time.Duration
 }
 
 // NewProxyStore returns a new ProxyStore that uses the given clients that implements storeAPI to fan-in all series to the client.
 // Note that there is no deduplication support. Deduplication should be done on the highest level (just before PromQL)
+// A store that does not send its first series response within firstByteTimeout or does not finish its whole
+// response within responseTimeout is dropped from the series request with a warning. Time the store is held back
+// by slower stores does not count towards responseTimeout. Zero disables the given timeout.
 func NewProxyStore(
 	logger log.Logger,
+	reg prometheus.Registerer,
 	stores func(context.Context) ([]Client, error),
 	selectorLabels labels.Labels,
+	firstByteTimeout time.Duration,
+	responseTimeout time.Duration,
 ) *ProxyStore {
 	if logger == nil {
 		logger = log.NewNopLogger()
 	}
 	s := &ProxyStore{
-		logger:         logger,
-		stores:         stores,
-		selectorLabels: selectorLabels,
+		logger:           logger,
+		metrics:          newProxyStoreMetrics(reg),
+		stores:           stores,
+		selectorLabels:   selectorLabels,
+		firstByteTimeout: firstByteTimeout,
+		responseTimeout:  responseTimeout,
 	}
 	return s
 }
@@ -77,6 +118,8 @@ func (s *ProxyStore) Info(ctx context.Context, r *storepb.InfoRequest) (*storepb
 
 // Series returns all series for a requested time range and label matcher. Requested series are taken from other
 // stores and proxied to RPC client. NOTE: Resulted data are not trimmed exactly to min and max time range.
+// Failures of individual stores are returned as warnings unless partial response is disabled in the request,
+// in which case the first failure aborts the whole request.
 func (s *ProxyStore) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesServer) error {
 	match, newMatchers, err := labelsMatches(s.selectorLabels, r.Matchers)
 	if err != nil {
@@ -93,6 +136,11 @@ func (s *ProxyStore) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesSe
 		return status.Errorf(codes.Unknown, err.Error())
 	}
 
+	// Make sure all underlying streams are closed once we are done, especially
+	// when aborting early because of a failed store. The tenant of the request is passed to all stores.
+	ctx, cancel := context.WithCancel(withTenant(srv.Context()))
+	defer cancel()
+
 	var (
 		seriesSet []storepb.SeriesSet
 		respCh    = make(chan *storepb.SeriesResponse, len(stores)+1)
@@ -111,25 +159,35 @@ func (s *ProxyStore) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesSe
 		}
 		storeDebugMsgs = append(storeDebugMsgs, fmt.Sprintf("store %s queried", st))
 
-		sc, err := st.Series(srv.Context(), &storepb.SeriesRequest{
-			MinTime:             r.MinTime,
-			MaxTime:             r.MaxTime,
-			Matchers:            newMatchers,
-			Aggregates:          r.Aggregates,
-			MaxResolutionWindow: r.MaxResolutionWindow,
+		// Each store gets its own context, so a store that exceeded its timeout can be cancelled separately.
+		storeCtx, storeCancel := context.WithCancel(ctx)
+		sc, err := st.Series(storeCtx, &storepb.SeriesRequest{
+			MinTime:                 r.MinTime,
+			MaxTime:                 r.MaxTime,
+			Matchers:                newMatchers,
+			Aggregates:              r.Aggregates,
+			MaxResolutionWindow:     r.MaxResolutionWindow,
+			PartialResponseDisabled: r.PartialResponseDisabled,
+			SkipChunks:              r.SkipChunks,
 		})
 		if err != nil {
+			storeCancel()
 			storeID := fmt.Sprintf("%v", st.Labels())
 			if storeID == "" {
 				storeID = "Store Gateway"
 			}
 			err = errors.Wrapf(err, "fetch series for %s", storeID)
+			if r.PartialResponseDisabled {
+				level.Error(s.logger).Log("err", err, "msg", "partial response disabled; aborting request")
+				return status.Error(codes.Aborted, err.Error())
+			}
 			level.Error(s.logger).Log("err", err)
 			respCh <- storepb.NewWarnSeriesResponse(err)
 			continue
 		}
 
-		seriesSet = append(seriesSet, startStreamSeriesSet(sc, respCh, 10))
+		seriesSet = append(seriesSet, startStreamSeriesSet(ctx, storeCancel, st.Addr(), sc, respCh, 10, !r.PartialResponseDisabled,
+			s.firstByteTimeout, s.responseTimeout, s.metrics.storeTimeouts))
 	}
 	if len(seriesSet) == 0 {
 		err := errors.New("No store matched for this query")
@@ -160,53 +218,178 @@ func (s *ProxyStore) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesSe
 
 	if err := g.Wait(); err != nil {
 		level.Error(s.logger).Log("err", err)
-		return err
+		return status.Error(codes.Aborted, err.Error())
 	}
 	return nil
 
 }
 
 // streamSeriesSet iterates over incoming stream of series.
-// All errors are sent out of band via warning channel.
+// All errors are sent out of band via warning channel, unless partial response
+// is disabled. In that case the first error stops the set and is returned by Err.
+// The stream is abandoned if the store does not respond within the configured timeouts.
 type streamSeriesSet struct {
-	stream storepb.Store_SeriesClient
-	warnCh chan<- *storepb.SeriesResponse
+	// ctx is the context of the whole request, cancel aborts just the stream of this store.
+	ctx             context.Context
+	cancel          context.CancelFunc
+	addr            string
+	stream          storepb.Store_SeriesClient
+	warnCh          chan<- *storepb.SeriesResponse
+	partialResponse bool
+
+	firstByteTimeout time.Duration
+	responseTimeout  time.Duration
+	timeouts         *prometheus.CounterVec
 
 	currSeries *storepb.Series
 	recvCh     chan *storepb.Series
+
+	errMtx sync.Mutex
+	err    error
 }
 
 func startStreamSeriesSet(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	addr string,
 	stream storepb.Store_SeriesClient,
 	warnCh chan<- *storepb.SeriesResponse,
 	bufferSize int,
+	partialResponse bool,
+	firstByteTimeout time.Duration,
+	responseTimeout time.Duration,
+	timeouts *prometheus.CounterVec,
 ) *streamSeriesSet {
 	s := &streamSeriesSet{
-		stream: stream,
-		warnCh: warnCh,
-		recvCh: make(chan *storepb.Series, bufferSize),
+		ctx:              ctx,
+		cancel:           cancel,
+		addr:             addr,
+		stream:           stream,
+		warnCh:           warnCh,
+		partialResponse:  partialResponse,
+		firstByteTimeout: firstByteTimeout,
+		responseTimeout:  responseTimeout,
+		timeouts:         timeouts,
+		recvCh:           make(chan *storepb.Series, bufferSize),
 	}
 	go s.fetchLoop()
 	return s
 }
 
 func (s *streamSeriesSet) fetchLoop() {
+	done := make(chan struct{})
 	defer close(s.recvCh)
+	defer s.cancel()
+	defer close(done)
+
+	// A single goroutine receives from the stream, so that waiting for responses can be bounded by timeouts.
+	resCh := make(chan recvResult)
+	go func() {
+		for {
+			r, err := s.stream.Recv()
+			select {
+			case resCh <- recvResult{r: r, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var (
+		firstByte = true
+		remaining = s.responseTimeout
+	)
 	for {
-		r, err := s.stream.Recv()
+		r, err := s.recv(resCh, firstByte, &remaining)
 		if err == io.EOF {
 			return
 		}
 		if err != nil {
-			s.warnCh <- storepb.NewWarnSeriesResponse(errors.Wrap(err, "receive series"))
+			s.handleErr(err)
 			return
 		}
+		firstByte = false
 
 		if w := r.GetWarning(); w != "" {
-			s.warnCh <- storepb.NewWarnSeriesResponse(errors.New(w))
+			if !s.handleErr(errors.New(w)) {
+				return
+			}
 			continue
 		}
-		s.recvCh <- r.GetSeries()
+
+		select {
+		case s.recvCh <- r.GetSeries():
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+type recvResult struct {
+	r   *storepb.SeriesResponse
+	err error
+}
+
+// recv waits for the next response from the stream. Only the time spent waiting here counts towards the response
+// timeout, which is tracked in remaining. Time spent blocked on the merge of responses of other stores does not,
+// so that a store is not cut off because of backpressure. If a timeout fires before the response arrives,
+// a timeout error is returned.
+func (s *streamSeriesSet) recv(resCh <-chan recvResult, firstByte bool, remaining *time.Duration) (*storepb.SeriesResponse, error) {
+	var (
+		firstByteTimeout <-chan time.Time
+		responseTimeout  <-chan time.Time
+	)
+	if firstByte && s.firstByteTimeout > 0 {
+		t := time.NewTimer(s.firstByteTimeout)
+		defer t.Stop()
+		firstByteTimeout = t.C
+	}
+	if s.responseTimeout > 0 {
+		t := time.NewTimer(*remaining)
+		defer t.Stop()
+		responseTimeout = t.C
+
+		start := time.Now()
+		defer func() { *remaining -= time.Since(start) }()
+	}
+
+	var (
+		timeout string
+		d       time.Duration
+	)
+	select {
+	case res := <-resCh:
+		if res.err != nil && res.err != io.EOF {
+			return nil, errors.Wrap(res.err, "receive series")
+		}
+		return res.r, res.err
+	case <-firstByteTimeout:
+		timeout, d = timeoutFirstByte, s.firstByteTimeout
+	case <-responseTimeout:
+		timeout, d = timeoutResponse, s.responseTimeout
+	}
+	s.timeouts.WithLabelValues(s.addr, timeout).Inc()
+	return nil, errors.Errorf("receive series from %s: %s timeout of %s exceeded", s.addr, strings.Replace(timeout, "_", " ", -1), d)
+}
+
+// handleErr sends the error as a warning if partial response is enabled. Otherwise it records the error
+// to be returned by Err. It returns false if the set should stop fetching.
+func (s *streamSeriesSet) handleErr(err error) bool {
+	if !s.partialResponse {
+		s.errMtx.Lock()
+		s.err = err
+		s.errMtx.Unlock()
+		return false
+	}
+
+	select {
+	case s.warnCh <- storepb.NewWarnSeriesResponse(err):
+		return true
+	case <-s.ctx.Done():
+		return false
 	}
 }
 
@@ -222,8 +405,11 @@ func (s *streamSeriesSet) At() ([]storepb.Label, []storepb.AggrChunk) {
 	}
 	return s.currSeries.Labels, s.currSeries.Chunks
 }
+
 func (s *streamSeriesSet) Err() error {
-	return nil
+	s.errMtx.Lock()
+	defer s.errMtx.Unlock()
+	return s.err
 }
 
 // matchStore returns true if the given store may hold data for the given label matchers.
@@ -251,17 +437,86 @@ func storeMatches(s Client, mint, maxt int64, matchers ...storepb.LabelMatcher)
 	return true, nil
 }
 
+// labelsRequestTimeRange returns the time range requested by label names or values request.
+// Not set time range means the range is not restricted.
+func labelsRequestTimeRange(r *storepb.TimeRange) (mint int64, maxt int64) {
+	if r == nil {
+		return math.MinInt64, math.MaxInt64
+	}
+	return r.MinTime, r.MaxTime
+}
+
 // LabelNames returns all known label names.
 func (s *ProxyStore) LabelNames(ctx context.Context, r *storepb.LabelNamesRequest) (
 	*storepb.LabelNamesResponse, error,
 ) {
-	return nil, status.Error(codes.Unimplemented, "not implemented")
+	match, newMatchers, err := labelsMatches(s.selectorLabels, r.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !match {
+		return &storepb.LabelNamesResponse{}, nil
+	}
+
+	var (
+		warnings []string
+		all      [][]string
+		mtx      sync.Mutex
+		wg       sync.WaitGroup
+	)
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, err.Error())
+	}
+	ctx = withTenant(ctx)
+	mint, maxt := labelsRequestTimeRange(r.TimeRange)
+	for _, st := range stores {
+		// NOTE: all matchers are validated in labelsMatches method so we explicitly ignore error.
+		if ok, _ := storeMatches(st, mint, maxt, newMatchers...); !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(s Client) {
+			defer wg.Done()
+			resp, err := s.LabelNames(ctx, &storepb.LabelNamesRequest{
+				Matchers:  newMatchers,
+				TimeRange: r.TimeRange,
+			})
+			if err != nil {
+				mtx.Lock()
+				warnings = append(warnings, errors.Wrap(err, "fetch label names").Error())
+				mtx.Unlock()
+				return
+			}
+
+			mtx.Lock()
+			warnings = append(warnings, resp.Warnings...)
+			all = append(all, resp.Names)
+			mtx.Unlock()
+
+			return
+		}(st)
+	}
+
+	wg.Wait()
+	return &storepb.LabelNamesResponse{
+		Names:    strutil.MergeUnsortedSlices(all...),
+		Warnings: warnings,
+	}, nil
 }
 
 // LabelValues returns all known label values for a given label name.
 func (s *ProxyStore) LabelValues(ctx context.Context, r *storepb.LabelValuesRequest) (
 	*storepb.LabelValuesResponse, error,
 ) {
+	match, newMatchers, err := labelsMatches(s.selectorLabels, r.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !match {
+		return &storepb.LabelValuesResponse{}, nil
+	}
+
 	var (
 		warnings []string
 		all      [][]string
@@ -272,12 +527,20 @@ func (s *ProxyStore) LabelValues(ctx context.Context, r *storepb.LabelValuesRequ
 	if err != nil {
 		return nil, status.Errorf(codes.Unknown, err.Error())
 	}
+	ctx = withTenant(ctx)
+	mint, maxt := labelsRequestTimeRange(r.TimeRange)
 	for _, st := range stores {
+		// NOTE: all matchers are validated in labelsMatches method so we explicitly ignore error.
+		if ok, _ := storeMatches(st, mint, maxt, newMatchers...); !ok {
+			continue
+		}
 		wg.Add(1)
 		go func(s Client) {
 			defer wg.Done()
 			resp, err := s.LabelValues(ctx, &storepb.LabelValuesRequest{
-				Label: r.Label,
+				Label:     r.Label,
+				Matchers:  newMatchers,
+				TimeRange: r.TimeRange,
 			})
 			if err != nil {
 				mtx.Lock()
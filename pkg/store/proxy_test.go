@@ -2,7 +2,10 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"math"
+	"sync"
 	"testing"
 
 	"time"
@@ -36,6 +39,10 @@ func (c *testClient) TimeRange() (int64, int64) {
 	return c.minTime, c.maxTime
 }
 
+func (c *testClient) Addr() string {
+	return "testaddr"
+}
+
 func (c *testClient) String() string {
 	return "test"
 }
@@ -93,9 +100,10 @@ func TestQueryStore_Series(t *testing.T) {
 			maxTime: 302,
 		},
 	}
-	q := NewProxyStore(nil,
+	q := NewProxyStore(nil, nil,
 		func(context.Context) ([]Client, error) { return cls, nil },
 		tlabels.FromStrings("fed", "a"),
+		0, 0,
 	)
 
 	ctx := context.Background()
@@ -170,9 +178,10 @@ func TestQueryStore_Series_SameExtSet(t *testing.T) {
 			maxTime: 300,
 		},
 	}
-	q := NewProxyStore(nil,
+	q := NewProxyStore(nil, nil,
 		func(context.Context) ([]Client, error) { return cls, nil },
 		nil,
+		0, 0,
 	)
 
 	ctx := context.Background()
@@ -200,6 +209,183 @@ func TestQueryStore_Series_SameExtSet(t *testing.T) {
 	testutil.Equals(t, 0, len(s1.Warnings))
 }
 
+func TestQueryStore_Series_PartialResponseDisabled(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	okClient := &testClient{
+		StoreClient: &storeClient{
+			RespSet: []*storepb.SeriesResponse{
+				storeSeriesResponse(t, labels.FromStrings("a", "b"), []sample{{1, 1}, {2, 2}, {3, 3}}),
+			},
+		},
+		minTime: 1,
+		maxTime: 300,
+	}
+	for _, tcase := range []struct {
+		name   string
+		failed *testClient
+	}{
+		{
+			name: "store fails on request",
+			failed: &testClient{
+				StoreClient: &storeClient{RespError: errors.New("test error")},
+				minTime:     1,
+				maxTime:     300,
+			},
+		},
+		{
+			name: "store returns warning",
+			failed: &testClient{
+				StoreClient: &storeClient{
+					RespSet: []*storepb.SeriesResponse{
+						storeSeriesResponse(t, labels.FromStrings("a", "c"), []sample{{1, 1}}),
+						storepb.NewWarnSeriesResponse(errors.New("partial error")),
+					},
+				},
+				minTime: 1,
+				maxTime: 300,
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			q := NewProxyStore(nil, nil,
+				func(context.Context) ([]Client, error) { return []Client{okClient, tcase.failed}, nil },
+				nil,
+				0, 0,
+			)
+
+			s1 := newStoreSeriesServer(context.Background())
+			err := q.Series(&storepb.SeriesRequest{MinTime: 1, MaxTime: 300}, s1)
+			testutil.Ok(t, err)
+			testutil.Equals(t, 1, len(s1.Warnings))
+
+			// With partial response disabled the single failing store should fail the whole request.
+			s2 := newStoreSeriesServer(context.Background())
+			err = q.Series(&storepb.SeriesRequest{MinTime: 1, MaxTime: 300, PartialResponseDisabled: true}, s2)
+			testutil.NotOk(t, err)
+			testutil.Equals(t, codes.Aborted, status.Code(err))
+			testutil.Equals(t, 0, len(s2.Warnings))
+		})
+	}
+}
+
+func TestQueryStore_Series_StoreTimeout(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	fastClient := &testClient{
+		StoreClient: &storeClient{
+			RespSet: []*storepb.SeriesResponse{
+				storeSeriesResponse(t, labels.FromStrings("a", "a"), []sample{{1, 1}, {2, 2}}),
+				storeSeriesResponse(t, labels.FromStrings("a", "b"), []sample{{1, 1}, {2, 2}}),
+			},
+		},
+		minTime: 1,
+		maxTime: 300,
+	}
+	for _, tcase := range []struct {
+		name             string
+		slow             *testClient
+		firstByteTimeout time.Duration
+		responseTimeout  time.Duration
+		expected         [][]storepb.Label
+	}{
+		{
+			name: "first byte timeout",
+			slow: &testClient{
+				StoreClient: &storeClient{RespBlock: true},
+				minTime:     1,
+				maxTime:     300,
+			},
+			firstByteTimeout: 100 * time.Millisecond,
+			expected: [][]storepb.Label{
+				{{Name: "a", Value: "a"}},
+				{{Name: "a", Value: "b"}},
+			},
+		},
+		{
+			name: "response timeout",
+			slow: &testClient{
+				StoreClient: &storeClient{
+					RespSet: []*storepb.SeriesResponse{
+						storeSeriesResponse(t, labels.FromStrings("a", "c"), []sample{{1, 1}}),
+						storeSeriesResponse(t, labels.FromStrings("a", "d"), []sample{{1, 1}}),
+					},
+					RespBlock: true,
+				},
+				minTime: 1,
+				maxTime: 300,
+			},
+			firstByteTimeout: 100 * time.Millisecond,
+			responseTimeout:  100 * time.Millisecond,
+			expected: [][]storepb.Label{
+				{{Name: "a", Value: "a"}},
+				{{Name: "a", Value: "b"}},
+				{{Name: "a", Value: "c"}},
+				{{Name: "a", Value: "d"}},
+			},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			q := NewProxyStore(nil, nil,
+				func(context.Context) ([]Client, error) { return []Client{fastClient, tcase.slow}, nil },
+				nil,
+				tcase.firstByteTimeout, tcase.responseTimeout,
+			)
+
+			s1 := newStoreSeriesServer(context.Background())
+			err := q.Series(&storepb.SeriesRequest{MinTime: 1, MaxTime: 300}, s1)
+			testutil.Ok(t, err)
+
+			// Series sent before the slow store stopped responding should be returned, followed by a warning.
+			var lsets [][]storepb.Label
+			for _, s := range s1.SeriesSet {
+				lsets = append(lsets, s.Labels)
+			}
+			testutil.Equals(t, tcase.expected, lsets)
+			testutil.Equals(t, 1, len(s1.Warnings))
+
+			// The slow store should fail the whole request with partial response disabled.
+			s2 := newStoreSeriesServer(context.Background())
+			err = q.Series(&storepb.SeriesRequest{MinTime: 1, MaxTime: 300, PartialResponseDisabled: true}, s2)
+			testutil.NotOk(t, err)
+		})
+	}
+}
+
+// slowSeriesServer holds back the first series response, so that stores wait for the merge of responses.
+type slowSeriesServer struct {
+	*storeSeriesServer
+	delay time.Duration
+	once  sync.Once
+}
+
+func (s *slowSeriesServer) Send(r *storepb.SeriesResponse) error {
+	s.once.Do(func() { time.Sleep(s.delay) })
+	return s.storeSeriesServer.Send(r)
+}
+
+func TestQueryStore_Series_ResponseTimeoutBackpressure(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	// More series than fit into buffers, so the store is held back while the first response is sent.
+	var resps []*storepb.SeriesResponse
+	for i := 0; i < 100; i++ {
+		resps = append(resps, storeSeriesResponse(t, labels.FromStrings("a", fmt.Sprintf("%03d", i)), []sample{{1, 1}}))
+	}
+	q := NewProxyStore(nil, nil,
+		func(context.Context) ([]Client, error) {
+			return []Client{&testClient{StoreClient: &storeClient{RespSet: resps}, minTime: 1, maxTime: 300}}, nil
+		},
+		nil,
+		0, 50*time.Millisecond,
+	)
+
+	srv := &slowSeriesServer{storeSeriesServer: newStoreSeriesServer(context.Background()), delay: 200 * time.Millisecond}
+	testutil.Ok(t, q.Series(&storepb.SeriesRequest{MinTime: 1, MaxTime: 300}, srv))
+	testutil.Equals(t, 100, len(srv.SeriesSet))
+	testutil.Equals(t, 0, len(srv.Warnings))
+}
+
 func TestQueryStore_Series_FillResponseChannel(t *testing.T) {
 	defer leaktest.CheckTimeout(t, 10*time.Second)()
 
@@ -217,9 +403,10 @@ func TestQueryStore_Series_FillResponseChannel(t *testing.T) {
 		})
 	}
 
-	q := NewProxyStore(nil,
+	q := NewProxyStore(nil, nil,
 		func(context.Context) ([]Client, error) { return cls, nil },
 		tlabels.FromStrings("fed", "a"),
+		0, 0,
 	)
 
 	ctx := context.Background()
@@ -268,6 +455,90 @@ func seriesEqual(t *testing.T, expected []rawSeries, got []storepb.Series) {
 	}
 }
 
+func TestQueryStore_LabelNames(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	cls := []Client{
+		&testClient{StoreClient: &storeClient{Names: []string{"a", "c", "ext"}}},
+		&testClient{StoreClient: &storeClient{Names: []string{"b", "a"}}},
+		&testClient{StoreClient: &storeClient{RespError: errors.New("error!")}},
+	}
+	q := NewProxyStore(nil, nil,
+		func(_ context.Context) ([]Client, error) { return cls, nil },
+		nil,
+		0, 0,
+	)
+
+	resp, err := q.LabelNames(context.Background(), &storepb.LabelNamesRequest{})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"a", "b", "c", "ext"}, resp.Names)
+	testutil.Equals(t, 1, len(resp.Warnings))
+}
+
+func TestQueryStore_LabelValues_Scoped(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+
+	cls := []Client{
+		&testClient{
+			StoreClient: &storeClient{Values: map[string][]string{"a": {"1", "2"}}},
+			labels:      []storepb.Label{{Name: "region", Value: "eu-west"}},
+			minTime:     1,
+			maxTime:     100,
+		},
+		&testClient{
+			StoreClient: &storeClient{Values: map[string][]string{"a": {"3"}}},
+			labels:      []storepb.Label{{Name: "region", Value: "us-east"}},
+			minTime:     1,
+			maxTime:     100,
+		},
+		&testClient{
+			StoreClient: &storeClient{Values: map[string][]string{"a": {"4"}}},
+			labels:      []storepb.Label{{Name: "region", Value: "eu-west"}},
+			minTime:     200,
+			maxTime:     300,
+		},
+	}
+	q := NewProxyStore(nil, nil,
+		func(_ context.Context) ([]Client, error) { return cls, nil },
+		nil,
+		0, 0,
+	)
+
+	resp, err := q.LabelValues(context.Background(), &storepb.LabelValuesRequest{Label: "a"})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"1", "2", "3", "4"}, resp.Values)
+
+	resp, err = q.LabelValues(context.Background(), &storepb.LabelValuesRequest{
+		Label:    "a",
+		Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "region", Value: "eu-west"}},
+	})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"1", "2", "4"}, resp.Values)
+
+	resp, err = q.LabelValues(context.Background(), &storepb.LabelValuesRequest{
+		Label:     "a",
+		Matchers:  []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "region", Value: "eu-west"}},
+		TimeRange: &storepb.TimeRange{MinTime: 150, MaxTime: 250},
+	})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"4"}, resp.Values)
+
+	resp, err = q.LabelValues(context.Background(), &storepb.LabelValuesRequest{
+		Label:     "a",
+		TimeRange: &storepb.TimeRange{MinTime: math.MinInt64, MaxTime: 50},
+	})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"1", "2", "3"}, resp.Values)
+
+	// Range ending at epoch is not unrestricted.
+	resp, err = q.LabelValues(context.Background(), &storepb.LabelValuesRequest{
+		Label:     "a",
+		TimeRange: &storepb.TimeRange{MinTime: -10, MaxTime: 0},
+	})
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(resp.Values))
+}
+
 func TestStoreMatches(t *testing.T) {
 	defer leaktest.CheckTimeout(t, 10*time.Second)()
 
@@ -379,9 +650,12 @@ func (s *storeSeriesServer) Context() context.Context {
 // storeClient is test gRPC store API client.
 type storeClient struct {
 	Values map[string][]string
+	Names  []string
 
 	RespSet   []*storepb.SeriesResponse
 	RespError error
+	// RespBlock makes the series stream block after RespSet is sent until the request is cancelled.
+	RespBlock bool
 }
 
 func (s *storeClient) Info(ctx context.Context, req *storepb.InfoRequest, _ ...grpc.CallOption) (*storepb.InfoResponse, error) {
@@ -389,11 +663,14 @@ func (s *storeClient) Info(ctx context.Context, req *storepb.InfoRequest, _ ...g
 }
 
 func (s *storeClient) Series(ctx context.Context, req *storepb.SeriesRequest, _ ...grpc.CallOption) (storepb.Store_SeriesClient, error) {
-	return &StoreSeriesClient{ctx: ctx, respSet: s.RespSet}, s.RespError
+	return &StoreSeriesClient{ctx: ctx, respSet: s.RespSet, block: s.RespBlock}, s.RespError
 }
 
 func (s *storeClient) LabelNames(ctx context.Context, req *storepb.LabelNamesRequest, _ ...grpc.CallOption) (*storepb.LabelNamesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "not implemented")
+	if s.RespError != nil {
+		return nil, s.RespError
+	}
+	return &storepb.LabelNamesResponse{Names: s.Names}, nil
 }
 
 func (s *storeClient) LabelValues(ctx context.Context, req *storepb.LabelValuesRequest, _ ...grpc.CallOption) (*storepb.LabelValuesResponse, error) {
@@ -407,11 +684,19 @@ type StoreSeriesClient struct {
 	ctx     context.Context
 	i       int
 	respSet []*storepb.SeriesResponse
+	block   bool
 }
 
 func (c *StoreSeriesClient) Recv() (*storepb.SeriesResponse, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
 	if c.i >= len(c.respSet) {
-		return nil, io.EOF
+		if !c.block {
+			return nil, io.EOF
+		}
+		<-c.ctx.Done()
+		return nil, c.ctx.Err()
 	}
 	s := c.respSet[c.i]
 	c.i++
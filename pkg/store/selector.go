@@ -0,0 +1,64 @@
+package store
+
+import (
+	"math"
+
+	"github.com/improbable-eng/thanos/pkg/block"
+	"github.com/improbable-eng/thanos/pkg/model"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/relabel"
+	"gopkg.in/yaml.v2"
+)
+
+// ParseRelabelConfigs parses a YAML list of Prometheus relabel configs.
+func ParseRelabelConfigs(content []byte) ([]*config.RelabelConfig, error) {
+	var cfgs []*config.RelabelConfig
+	if err := yaml.UnmarshalStrict(content, &cfgs); err != nil {
+		return nil, errors.Wrap(err, "parse relabel configs")
+	}
+	return cfgs, nil
+}
+
+// FilterConfig restricts the blocks served by a BucketStore, so a single bucket can be split across multiple
+// store gateways. Blocks are selected by overlap with the time range and by their external labels, which are
+// relabelled with the relabel configs the same way Prometheus relabels targets. Blocks whose labels are dropped
+// by the relabelling are not served.
+type FilterConfig struct {
+	MinTime model.TimeOrDurationValue
+	MaxTime model.TimeOrDurationValue
+
+	RelabelConfigs []*config.RelabelConfig
+}
+
+// timeRange returns the time range of the filter in milliseconds. Times relative to now are evaluated on each call.
+func (f *FilterConfig) timeRange() (mint, maxt int64) {
+	mint, maxt = math.MinInt64, math.MaxInt64
+	if f == nil {
+		return mint, maxt
+	}
+	if f.MinTime.Time != nil || f.MinTime.Dur != nil {
+		mint = f.MinTime.PrometheusTimestamp()
+	}
+	if f.MaxTime.Time != nil || f.MaxTime.Dur != nil {
+		maxt = f.MaxTime.PrometheusTimestamp()
+	}
+	return mint, maxt
+}
+
+// selects returns true if the block with the given meta should be served.
+func (f *FilterConfig) selects(meta *block.Meta) bool {
+	if f == nil {
+		return true
+	}
+	mint, maxt := f.timeRange()
+	// Block time range is half-open.
+	if meta.MaxTime <= mint || meta.MinTime > maxt {
+		return false
+	}
+	if len(f.RelabelConfigs) == 0 {
+		return true
+	}
+	return relabel.Process(labels.FromMap(meta.Thanos.Labels), f.RelabelConfigs...) != nil
+}
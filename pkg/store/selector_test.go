@@ -0,0 +1,94 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/improbable-eng/thanos/pkg/block"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+)
+
+func TestFilterConfig_Selects(t *testing.T) {
+	relabelConfigs, err := ParseRelabelConfigs([]byte(`
+- action: keep
+  source_labels: [cluster]
+  regex: eu.*
+- action: drop
+  source_labels: [cluster, replica]
+  separator: /
+  regex: eu2/b
+`))
+	testutil.Ok(t, err)
+
+	f := &FilterConfig{RelabelConfigs: relabelConfigs}
+	testutil.Ok(t, f.MinTime.Set("1970-01-01T00:00:10Z"))
+	testutil.Ok(t, f.MaxTime.Set("1970-01-01T00:00:20Z"))
+
+	newMeta := func(mint, maxt int64, lset map[string]string) *block.Meta {
+		m := &block.Meta{}
+		m.MinTime, m.MaxTime = mint, maxt
+		m.Thanos.Labels = lset
+		return m
+	}
+	eu1 := map[string]string{"cluster": "eu1", "replica": "a"}
+
+	for _, tcase := range []struct {
+		meta     *block.Meta
+		selected bool
+	}{
+		{meta: newMeta(0, 10000, eu1)},
+		{meta: newMeta(0, 10001, eu1), selected: true},
+		{meta: newMeta(15000, 16000, eu1), selected: true},
+		{meta: newMeta(20000, 30000, eu1), selected: true},
+		{meta: newMeta(20001, 30000, eu1)},
+		{meta: newMeta(15000, 16000, map[string]string{"cluster": "us1", "replica": "a"})},
+		{meta: newMeta(15000, 16000, map[string]string{"replica": "a"})},
+		{meta: newMeta(15000, 16000, map[string]string{"cluster": "eu2", "replica": "a"}), selected: true},
+		{meta: newMeta(15000, 16000, map[string]string{"cluster": "eu2", "replica": "b"})},
+	} {
+		testutil.Equals(t, tcase.selected, f.selects(tcase.meta))
+	}
+
+	// Labels modified by relabelling are matched by the following configs.
+	relabelConfigs, err = ParseRelabelConfigs([]byte(`
+- action: replace
+  source_labels: [cluster]
+  regex: (eu|us)[0-9]+
+  target_label: region
+  replacement: $1
+- action: keep
+  source_labels: [region]
+  regex: eu
+`))
+	testutil.Ok(t, err)
+
+	f = &FilterConfig{RelabelConfigs: relabelConfigs}
+	testutil.Assert(t, f.selects(newMeta(0, 1, eu1)), "block of region eu must be selected")
+	testutil.Assert(t, !f.selects(newMeta(0, 1, map[string]string{"cluster": "us1"})), "block of region us must not be selected")
+
+	// No filter selects all blocks.
+	f = nil
+	testutil.Assert(t, f.selects(newMeta(0, 1, nil)), "nil filter must select all blocks")
+
+	// Relative times are evaluated on each call.
+	f = &FilterConfig{}
+	testutil.Ok(t, f.MinTime.Set("-2h"))
+	now := timestamp.FromTime(time.Now())
+	testutil.Assert(t, !f.selects(newMeta(now-3*3600*1000, now-2*3600*1000-1000, eu1)), "block before relative min time must not be selected")
+	testutil.Assert(t, f.selects(newMeta(now-3*3600*1000, now-3600*1000, eu1)), "block overlapping relative min time must be selected")
+}
+
+func TestParseRelabelConfigs(t *testing.T) {
+	for _, c := range []string{
+		`- action: replace
+  source_labels: [cluster]`,
+		`- source_labels: [cluster]
+  regex: "("`,
+		`- source_labels: [cluster]
+  unknown: field`,
+	} {
+		_, err := ParseRelabelConfigs([]byte(c))
+		testutil.NotOk(t, err)
+	}
+}
@@ -17,6 +17,7 @@
 		LabelNamesResponse
 		LabelValuesRequest
 		LabelValuesResponse
+		TimeRange
 		Label
 		Chunk
 		Series
@@ -104,6 +105,11 @@ type SeriesRequest struct {
 	Matchers            []LabelMatcher `protobuf:"bytes,3,rep,name=matchers" json:"matchers"`
 	MaxResolutionWindow int64          `protobuf:"varint,4,opt,name=max_resolution_window,json=maxResolutionWindow,proto3" json:"max_resolution_window,omitempty"`
 	Aggregates          []Aggr         `protobuf:"varint,5,rep,packed,name=aggregates,enum=thanos.Aggr" json:"aggregates,omitempty"`
+	// If true, the request fails if any of the queried stores fails instead of
+	// returning the partial result with warnings.
+	PartialResponseDisabled bool `protobuf:"varint,6,opt,name=partial_response_disabled,json=partialResponseDisabled,proto3" json:"partial_response_disabled,omitempty"`
+	// If true, only the label sets of the matching series are returned, without chunks.
+	SkipChunks bool `protobuf:"varint,7,opt,name=skip_chunks,json=skipChunks,proto3" json:"skip_chunks,omitempty"`
 }
 
 func (m *SeriesRequest) Reset()                    { *m = SeriesRequest{} }
@@ -231,6 +237,10 @@ func _SeriesResponse_OneofSizer(msg proto.Message) (n int) {
 }
 
 type LabelNamesRequest struct {
+	// Optional matchers restricting the stores and blocks the label names are fetched from.
+	Matchers []LabelMatcher `protobuf:"bytes,1,rep,name=matchers" json:"matchers"`
+	// Optional time range the label names are requested for. No time restriction if not set.
+	TimeRange *TimeRange `protobuf:"bytes,2,opt,name=time_range,json=timeRange" json:"time_range,omitempty"`
 }
 
 func (m *LabelNamesRequest) Reset()                    { *m = LabelNamesRequest{} }
@@ -250,6 +260,10 @@ func (*LabelNamesResponse) Descriptor() ([]byte, []int) { return fileDescriptorR
 
 type LabelValuesRequest struct {
 	Label string `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	// Optional matchers restricting the stores and blocks the label values are fetched from.
+	Matchers []LabelMatcher `protobuf:"bytes,2,rep,name=matchers" json:"matchers"`
+	// Optional time range the label values are requested for. No time restriction if not set.
+	TimeRange *TimeRange `protobuf:"bytes,3,opt,name=time_range,json=timeRange" json:"time_range,omitempty"`
 }
 
 func (m *LabelValuesRequest) Reset()                    { *m = LabelValuesRequest{} }
@@ -267,6 +281,17 @@ func (m *LabelValuesResponse) String() string            { return proto.CompactT
 func (*LabelValuesResponse) ProtoMessage()               {}
 func (*LabelValuesResponse) Descriptor() ([]byte, []int) { return fileDescriptorRpc, []int{7} }
 
+// TimeRange is an inclusive time range in milliseconds.
+type TimeRange struct {
+	MinTime int64 `protobuf:"varint,1,opt,name=min_time,json=minTime,proto3" json:"min_time,omitempty"`
+	MaxTime int64 `protobuf:"varint,2,opt,name=max_time,json=maxTime,proto3" json:"max_time,omitempty"`
+}
+
+func (m *TimeRange) Reset()                    { *m = TimeRange{} }
+func (m *TimeRange) String() string            { return proto.CompactTextString(m) }
+func (*TimeRange) ProtoMessage()               {}
+func (*TimeRange) Descriptor() ([]byte, []int) { return fileDescriptorRpc, []int{8} }
+
 func init() {
 	proto.RegisterType((*InfoRequest)(nil), "thanos.InfoRequest")
 	proto.RegisterType((*InfoResponse)(nil), "thanos.InfoResponse")
@@ -276,6 +301,7 @@ func init() {
 	proto.RegisterType((*LabelNamesResponse)(nil), "thanos.LabelNamesResponse")
 	proto.RegisterType((*LabelValuesRequest)(nil), "thanos.LabelValuesRequest")
 	proto.RegisterType((*LabelValuesResponse)(nil), "thanos.LabelValuesResponse")
+	proto.RegisterType((*TimeRange)(nil), "thanos.TimeRange")
 	proto.RegisterEnum("thanos.Aggr", Aggr_name, Aggr_value)
 }
 
@@ -597,6 +623,26 @@ func (m *SeriesRequest) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintRpc(dAtA, i, uint64(j1))
 		i += copy(dAtA[i:], dAtA2[:j1])
 	}
+	if m.PartialResponseDisabled {
+		dAtA[i] = 0x30
+		i++
+		if m.PartialResponseDisabled {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.SkipChunks {
+		dAtA[i] = 0x38
+		i++
+		if m.SkipChunks {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
 	return i, nil
 }
 
@@ -662,6 +708,28 @@ func (m *LabelNamesRequest) MarshalTo(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Matchers) > 0 {
+		for _, msg := range m.Matchers {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintRpc(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.TimeRange != nil {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintRpc(dAtA, i, uint64(m.TimeRange.Size()))
+		n5, err := m.TimeRange.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n5
+	}
 	return i, nil
 }
 
@@ -734,6 +802,28 @@ func (m *LabelValuesRequest) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintRpc(dAtA, i, uint64(len(m.Label)))
 		i += copy(dAtA[i:], m.Label)
 	}
+	if len(m.Matchers) > 0 {
+		for _, msg := range m.Matchers {
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintRpc(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.TimeRange != nil {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintRpc(dAtA, i, uint64(m.TimeRange.Size()))
+		n6, err := m.TimeRange.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n6
+	}
 	return i, nil
 }
 
@@ -785,6 +875,34 @@ func (m *LabelValuesResponse) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *TimeRange) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TimeRange) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.MinTime != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintRpc(dAtA, i, uint64(m.MinTime))
+	}
+	if m.MaxTime != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintRpc(dAtA, i, uint64(m.MaxTime))
+	}
+	return i, nil
+}
+
 func encodeVarintRpc(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -843,6 +961,12 @@ func (m *SeriesRequest) Size() (n int) {
 		}
 		n += 1 + sovRpc(uint64(l)) + l
 	}
+	if m.PartialResponseDisabled {
+		n += 2
+	}
+	if m.SkipChunks {
+		n += 2
+	}
 	return n
 }
 
@@ -874,6 +998,16 @@ func (m *SeriesResponse_Warning) Size() (n int) {
 func (m *LabelNamesRequest) Size() (n int) {
 	var l int
 	_ = l
+	if len(m.Matchers) > 0 {
+		for _, e := range m.Matchers {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if m.TimeRange != nil {
+		l = m.TimeRange.Size()
+		n += 1 + l + sovRpc(uint64(l))
+	}
 	return n
 }
 
@@ -902,6 +1036,16 @@ func (m *LabelValuesRequest) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovRpc(uint64(l))
 	}
+	if len(m.Matchers) > 0 {
+		for _, e := range m.Matchers {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if m.TimeRange != nil {
+		l = m.TimeRange.Size()
+		n += 1 + l + sovRpc(uint64(l))
+	}
 	return n
 }
 
@@ -923,6 +1067,18 @@ func (m *LabelValuesResponse) Size() (n int) {
 	return n
 }
 
+func (m *TimeRange) Size() (n int) {
+	var l int
+	_ = l
+	if m.MinTime != 0 {
+		n += 1 + sovRpc(uint64(m.MinTime))
+	}
+	if m.MaxTime != 0 {
+		n += 1 + sovRpc(uint64(m.MaxTime))
+	}
+	return n
+}
+
 func sovRpc(x uint64) (n int) {
 	for {
 		n++
@@ -1284,6 +1440,46 @@ func (m *SeriesRequest) Unmarshal(dAtA []byte) error {
 			} else {
 				return fmt.Errorf("proto: wrong wireType = %d for field Aggregates", wireType)
 			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PartialResponseDisabled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.PartialResponseDisabled = bool(v != 0)
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SkipChunks", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.SkipChunks = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -1445,6 +1641,70 @@ func (m *LabelNamesRequest) Unmarshal(dAtA []byte) error {
 			return fmt.Errorf("proto: LabelNamesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Matchers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Matchers = append(m.Matchers, LabelMatcher{})
+			if err := m.Matchers[len(m.Matchers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TimeRange", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TimeRange == nil {
+				m.TimeRange = &TimeRange{}
+			}
+			if err := m.TimeRange.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -1632,6 +1892,70 @@ func (m *LabelValuesRequest) Unmarshal(dAtA []byte) error {
 			}
 			m.Label = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Matchers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Matchers = append(m.Matchers, LabelMatcher{})
+			if err := m.Matchers[len(m.Matchers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TimeRange", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TimeRange == nil {
+				m.TimeRange = &TimeRange{}
+			}
+			if err := m.TimeRange.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -1761,6 +2085,94 @@ func (m *LabelValuesResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *TimeRange) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TimeRange: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TimeRange: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MinTime", wireType)
+			}
+			m.MinTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MinTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxTime", wireType)
+			}
+			m.MaxTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxTime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipRpc(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -1869,40 +2281,47 @@ var (
 func init() { proto.RegisterFile("rpc.proto", fileDescriptorRpc) }
 
 var fileDescriptorRpc = []byte{
-	// 555 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x54, 0x5d, 0x6f, 0xd2, 0x50,
-	0x18, 0xa6, 0x94, 0x16, 0x78, 0x3b, 0x48, 0x3d, 0xb0, 0xa5, 0xd4, 0x04, 0x49, 0xaf, 0xc8, 0x34,
-	0xa8, 0x98, 0x98, 0x78, 0x09, 0x8b, 0xcb, 0x48, 0x04, 0x93, 0xc3, 0xe6, 0x8c, 0x37, 0xb3, 0xcc,
-	0x63, 0xd7, 0x84, 0xf6, 0xb0, 0x9e, 0x22, 0x78, 0xeb, 0xaf, 0xe3, 0xd2, 0x5f, 0xe0, 0x07, 0xbf,
-	0xc4, 0x9c, 0x8f, 0x32, 0x6a, 0xa6, 0x77, 0xef, 0xfb, 0x3c, 0x6f, 0x9f, 0xf7, 0xe3, 0x39, 0x29,
-	0x54, 0x93, 0xc5, 0x75, 0x6f, 0x91, 0xd0, 0x94, 0x22, 0x33, 0xbd, 0xf1, 0x63, 0xca, 0x5c, 0x2b,
-	0xfd, 0xba, 0x20, 0x4c, 0x82, 0x6e, 0x33, 0xa0, 0x01, 0x15, 0xe1, 0x53, 0x1e, 0x49, 0xd4, 0xab,
-	0x81, 0x35, 0x8a, 0x3f, 0x53, 0x4c, 0x6e, 0x97, 0x84, 0xa5, 0xde, 0x2d, 0x1c, 0xc8, 0x94, 0x2d,
-	0x68, 0xcc, 0x08, 0x7a, 0x0c, 0xe6, 0xdc, 0x9f, 0x91, 0x39, 0x73, 0xb4, 0x8e, 0xde, 0xb5, 0xfa,
-	0xb5, 0x9e, 0x94, 0xee, 0xbd, 0xe1, 0xe8, 0xb0, 0xb4, 0xf9, 0xf1, 0xa8, 0x80, 0x55, 0x09, 0x6a,
-	0x41, 0x25, 0x0a, 0xe3, 0xab, 0x34, 0x8c, 0x88, 0x53, 0xec, 0x68, 0x5d, 0x1d, 0x97, 0xa3, 0x30,
-	0x3e, 0x0f, 0x23, 0x22, 0x28, 0x7f, 0x2d, 0x29, 0x5d, 0x51, 0xfe, 0x9a, 0x53, 0xde, 0x4f, 0x0d,
-	0x6a, 0x53, 0x92, 0x84, 0x84, 0xa9, 0x21, 0x72, 0x3a, 0xda, 0xbf, 0x75, 0x8a, 0x39, 0x1d, 0xf4,
-	0x92, 0x53, 0xe9, 0xf5, 0x0d, 0x49, 0x98, 0xa3, 0x8b, 0x61, 0x9b, 0xb9, 0x61, 0xc7, 0x92, 0x54,
-	0x33, 0xef, 0x6a, 0x51, 0x1f, 0x0e, 0xb9, 0x64, 0x42, 0x18, 0x9d, 0x2f, 0xd3, 0x90, 0xc6, 0x57,
-	0xab, 0x30, 0xfe, 0x44, 0x57, 0x4e, 0x49, 0xe8, 0x37, 0x22, 0x7f, 0x8d, 0x77, 0xdc, 0xa5, 0xa0,
-	0xd0, 0x13, 0x00, 0x3f, 0x08, 0x12, 0x12, 0xf8, 0x29, 0x61, 0x8e, 0xd1, 0xd1, 0xbb, 0xf5, 0xfe,
-	0x41, 0xd6, 0x6d, 0x10, 0x04, 0x09, 0xde, 0xe3, 0xbd, 0x8f, 0x50, 0xcf, 0x16, 0x54, 0x67, 0xed,
-	0x82, 0xc9, 0x04, 0x22, 0xf6, 0xb3, 0xfa, 0xf5, 0xec, 0x5b, 0x59, 0x77, 0x56, 0xc0, 0x8a, 0x47,
-	0x2e, 0x94, 0x57, 0x7e, 0x12, 0x87, 0x71, 0x20, 0xf6, 0xad, 0x9e, 0x15, 0x70, 0x06, 0x0c, 0x2b,
-	0x60, 0x26, 0x84, 0x2d, 0xe7, 0xa9, 0xd7, 0x80, 0x07, 0x62, 0xc7, 0x89, 0x1f, 0xed, 0xce, 0xe8,
-	0x9d, 0x02, 0xda, 0x07, 0x55, 0xeb, 0x26, 0x18, 0x31, 0x07, 0x84, 0xa1, 0x55, 0x2c, 0x13, 0xe4,
-	0x42, 0x45, 0xa9, 0x32, 0xa7, 0x28, 0x88, 0x5d, 0xee, 0x1d, 0x2b, 0x9d, 0x77, 0xfe, 0x7c, 0x79,
-	0x67, 0x52, 0x13, 0x0c, 0x61, 0xbb, 0xd8, 0xa0, 0x8a, 0x65, 0xe2, 0x8d, 0xa0, 0x91, 0xab, 0x55,
-	0x4d, 0x8f, 0xc0, 0xfc, 0x22, 0x10, 0xd5, 0x55, 0x65, 0xff, 0x6b, 0x7b, 0x3c, 0x84, 0x12, 0xbf,
-	0x24, 0x2a, 0x83, 0x8e, 0x07, 0x97, 0x76, 0x01, 0x55, 0xc1, 0x38, 0x79, 0x7b, 0x31, 0x39, 0xb7,
-	0x35, 0x8e, 0x4d, 0x2f, 0xc6, 0x76, 0x91, 0x07, 0xe3, 0xd1, 0xc4, 0xd6, 0x45, 0x30, 0x78, 0x6f,
-	0x97, 0x90, 0x05, 0x65, 0x51, 0xf5, 0x1a, 0xdb, 0x46, 0xff, 0x5b, 0x11, 0x8c, 0x69, 0x4a, 0x13,
-	0x82, 0x9e, 0x43, 0x89, 0x3f, 0x6c, 0xd4, 0xc8, 0x2e, 0xbd, 0xf7, 0xea, 0xdd, 0x66, 0x1e, 0x54,
-	0x43, 0xbf, 0x02, 0x53, 0xda, 0x81, 0x0e, 0xf3, 0xf6, 0x64, 0x9f, 0x1d, 0xfd, 0x0d, 0xcb, 0x0f,
-	0x9f, 0x69, 0xe8, 0x04, 0xe0, 0xee, 0xf4, 0xa8, 0x95, 0x7b, 0x87, 0xfb, 0x1e, 0xb9, 0xee, 0x7d,
-	0x94, 0xea, 0x7f, 0x0a, 0xd6, 0xde, 0x2d, 0x51, 0xbe, 0x34, 0x67, 0x86, 0xfb, 0xf0, 0x5e, 0x4e,
-	0xea, 0x0c, 0x5b, 0x9b, 0xdf, 0xed, 0xc2, 0x66, 0xdb, 0xd6, 0xbe, 0x6f, 0xdb, 0xda, 0xaf, 0x6d,
-	0x5b, 0xfb, 0x50, 0x66, 0xfc, 0x26, 0x8b, 0xd9, 0xcc, 0x14, 0x3f, 0x81, 0x17, 0x7f, 0x02, 0x00,
-	0x00, 0xff, 0xff, 0x4c, 0xf3, 0x8b, 0x29, 0x3c, 0x04, 0x00, 0x00,
+	// 661 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x54, 0x5f, 0x4f, 0xd3, 0x50,
+	0x14, 0x5f, 0xd7, 0xad, 0x5b, 0x4f, 0x81, 0x8c, 0xcb, 0xc0, 0xae, 0x26, 0x63, 0xe9, 0xd3, 0xa2,
+	0x06, 0x71, 0x26, 0x26, 0xfa, 0xb6, 0xa1, 0x04, 0x12, 0xc1, 0xe4, 0x02, 0x62, 0x7c, 0x99, 0x77,
+	0x70, 0x2d, 0x0d, 0x5d, 0x5b, 0xee, 0xed, 0x04, 0x1f, 0x7c, 0xf1, 0x33, 0xf8, 0x71, 0xfc, 0x00,
+	0x3c, 0xfa, 0x09, 0x8c, 0xf2, 0x49, 0xcc, 0xfd, 0xd3, 0xb1, 0x1a, 0x34, 0xca, 0xdb, 0x39, 0xbf,
+	0xdf, 0xe9, 0xf9, 0xf3, 0x3b, 0xa7, 0x17, 0x6c, 0x96, 0x1e, 0xad, 0xa5, 0x2c, 0xc9, 0x12, 0x64,
+	0x65, 0x27, 0x24, 0x4e, 0xb8, 0xe7, 0x64, 0x1f, 0x53, 0xca, 0x15, 0xe8, 0x35, 0x83, 0x24, 0x48,
+	0xa4, 0xf9, 0x50, 0x58, 0x0a, 0xf5, 0xe7, 0xc1, 0xd9, 0x8e, 0xdf, 0x27, 0x98, 0x9e, 0x4d, 0x28,
+	0xcf, 0xfc, 0x33, 0x98, 0x53, 0x2e, 0x4f, 0x93, 0x98, 0x53, 0x74, 0x1f, 0xac, 0x88, 0x8c, 0x68,
+	0xc4, 0x5d, 0xa3, 0x63, 0x76, 0x9d, 0xde, 0xfc, 0x9a, 0x4a, 0xbd, 0xf6, 0x52, 0xa0, 0x83, 0xca,
+	0xe5, 0xf7, 0xd5, 0x12, 0xd6, 0x21, 0xa8, 0x05, 0xf5, 0x71, 0x18, 0x0f, 0xb3, 0x70, 0x4c, 0xdd,
+	0x72, 0xc7, 0xe8, 0x9a, 0xb8, 0x36, 0x0e, 0xe3, 0xfd, 0x70, 0x4c, 0x25, 0x45, 0x2e, 0x14, 0x65,
+	0x6a, 0x8a, 0x5c, 0x08, 0xca, 0xff, 0x5a, 0x86, 0xf9, 0x3d, 0xca, 0x42, 0xca, 0x75, 0x13, 0x85,
+	0x3c, 0xc6, 0x9f, 0xf3, 0x94, 0x0b, 0x79, 0xd0, 0x13, 0x41, 0x65, 0x47, 0x27, 0x94, 0x71, 0xd7,
+	0x94, 0xcd, 0x36, 0x0b, 0xcd, 0xee, 0x28, 0x52, 0xf7, 0x3c, 0x8d, 0x45, 0x3d, 0x58, 0x16, 0x29,
+	0x19, 0xe5, 0x49, 0x34, 0xc9, 0xc2, 0x24, 0x1e, 0x9e, 0x87, 0xf1, 0x71, 0x72, 0xee, 0x56, 0x64,
+	0xfe, 0xa5, 0x31, 0xb9, 0xc0, 0x53, 0xee, 0x50, 0x52, 0xe8, 0x01, 0x00, 0x09, 0x02, 0x46, 0x03,
+	0x92, 0x51, 0xee, 0x56, 0x3b, 0x66, 0x77, 0xa1, 0x37, 0x97, 0x57, 0xeb, 0x07, 0x01, 0xc3, 0x33,
+	0x3c, 0x7a, 0x06, 0xad, 0x94, 0xb0, 0x2c, 0x24, 0xd1, 0x90, 0x69, 0x61, 0x87, 0xc7, 0x21, 0x27,
+	0xa3, 0x88, 0x1e, 0xbb, 0x56, 0xc7, 0xe8, 0xd6, 0xf1, 0x1d, 0x1d, 0x90, 0x0b, 0xff, 0x5c, 0xd3,
+	0x68, 0x15, 0x1c, 0x7e, 0x1a, 0xa6, 0xc3, 0xa3, 0x93, 0x49, 0x7c, 0xca, 0xdd, 0x9a, 0x8c, 0x06,
+	0x01, 0x6d, 0x48, 0xc4, 0x7f, 0x07, 0x0b, 0xb9, 0x7a, 0x7a, 0x67, 0x5d, 0xb0, 0xb8, 0x44, 0xa4,
+	0x78, 0x4e, 0x6f, 0x21, 0x6f, 0x4c, 0xc5, 0x6d, 0x95, 0xb0, 0xe6, 0x91, 0x07, 0xb5, 0x73, 0xc2,
+	0xe2, 0x30, 0x0e, 0xa4, 0x98, 0xf6, 0x56, 0x09, 0xe7, 0xc0, 0xa0, 0x0e, 0x16, 0xa3, 0x7c, 0x12,
+	0x65, 0xfe, 0x27, 0x58, 0x94, 0x02, 0xee, 0x92, 0xf1, 0xf5, 0x8e, 0x66, 0xd5, 0x36, 0xfe, 0x43,
+	0xed, 0x75, 0x00, 0xb1, 0xbc, 0x21, 0x23, 0x71, 0xa0, 0x56, 0xe8, 0xf4, 0x16, 0xf3, 0x2f, 0xc5,
+	0x1e, 0xb1, 0x20, 0xb0, 0x9d, 0xe5, 0xa6, 0xbf, 0x09, 0x68, 0xb6, 0xbc, 0x1e, 0xb2, 0x09, 0xd5,
+	0x58, 0x00, 0xb2, 0xb8, 0x8d, 0x95, 0x83, 0x3c, 0xa8, 0xeb, 0xfe, 0xb9, 0x5b, 0x96, 0xc4, 0xd4,
+	0xf7, 0xbf, 0x18, 0x3a, 0xd1, 0x6b, 0x12, 0x4d, 0xae, 0x07, 0x69, 0x42, 0x55, 0x9e, 0xaf, 0x14,
+	0xcb, 0xc6, 0xca, 0x29, 0x8c, 0x57, 0xbe, 0xf5, 0x78, 0xe6, 0x3f, 0x8c, 0xb7, 0x0d, 0x4b, 0x85,
+	0xae, 0xf4, 0x7c, 0x2b, 0x60, 0x7d, 0x90, 0x88, 0x1e, 0x50, 0x7b, 0x7f, 0x9d, 0xb0, 0x0f, 0xf6,
+	0xb4, 0xc4, 0xed, 0x7e, 0xa2, 0x7b, 0x03, 0xa8, 0x88, 0xf3, 0x45, 0x35, 0x30, 0x71, 0xff, 0xb0,
+	0x51, 0x42, 0x36, 0x54, 0x37, 0x5e, 0x1d, 0xec, 0xee, 0x37, 0x0c, 0x81, 0xed, 0x1d, 0xec, 0x34,
+	0xca, 0xc2, 0xd8, 0xd9, 0xde, 0x6d, 0x98, 0xd2, 0xe8, 0xbf, 0x69, 0x54, 0x90, 0x03, 0x35, 0x19,
+	0xf5, 0x02, 0x37, 0xaa, 0xbd, 0xcf, 0x65, 0xa8, 0xee, 0x65, 0x09, 0xa3, 0xe8, 0x11, 0x54, 0xc4,
+	0x6b, 0x82, 0x96, 0x72, 0x05, 0x66, 0x9e, 0x1a, 0xaf, 0x59, 0x04, 0xf5, 0xdc, 0x4f, 0xc1, 0x52,
+	0x67, 0x8a, 0x96, 0x8b, 0x67, 0x9b, 0x7f, 0xb6, 0xf2, 0x3b, 0xac, 0x3e, 0x5c, 0x37, 0xd0, 0x06,
+	0xc0, 0xf5, 0xa1, 0xa0, 0x56, 0x61, 0x5f, 0xb3, 0xb7, 0xeb, 0x79, 0x37, 0x51, 0xba, 0xfe, 0x26,
+	0x38, 0x33, 0xeb, 0x40, 0xc5, 0xd0, 0xc2, 0xe5, 0x78, 0x77, 0x6f, 0xe4, 0x54, 0x9e, 0x41, 0xeb,
+	0xf2, 0x67, 0xbb, 0x74, 0x79, 0xd5, 0x36, 0xbe, 0x5d, 0xb5, 0x8d, 0x1f, 0x57, 0x6d, 0xe3, 0x6d,
+	0x8d, 0x0b, 0x4d, 0xd2, 0xd1, 0xc8, 0x92, 0x2f, 0xef, 0xe3, 0x5f, 0x03, 0x00, 0xd4, 0x02, 0x8d,
+	0x66, 0xb1, 0x05, 0x00, 0x00,
 }
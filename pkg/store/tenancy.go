@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+
+	"github.com/improbable-eng/thanos/pkg/tenancy"
+	"github.com/prometheus/tsdb/labels"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// requestTenant returns the tenant of the request. Stores enforcing tenancy with a tenant label
+// reject requests without a tenant.
+func requestTenant(ctx context.Context, tenantLabel string) (string, error) {
+	if tenantLabel == "" {
+		return "", nil
+	}
+	tenant, ok := tenancy.FromContext(ctx)
+	if !ok || tenant == "" {
+		return "", status.Error(codes.PermissionDenied, "request has no tenant")
+	}
+	return tenant, nil
+}
+
+// tenantMatches returns true if data with the given external labels can be served to the tenant. Data without
+// the tenant label is not served to any tenant. All data is served if tenancy is not enforced.
+func tenantMatches(tenantLabel, tenant string, lset labels.Labels) bool {
+	return tenantLabel == "" || lset.Get(tenantLabel) == tenant
+}
+
+// withTenant propagates the tenant of the request, if any, to StoreAPI requests sent with the returned context.
+func withTenant(ctx context.Context) context.Context {
+	if tenant, ok := tenancy.FromContext(ctx); ok {
+		return tenancy.ContextWithTenant(ctx, tenant)
+	}
+	return ctx
+}
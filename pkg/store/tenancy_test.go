@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/improbable-eng/thanos/pkg/store/storepb"
+	"github.com/improbable-eng/thanos/pkg/tenancy"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/prometheus/tsdb/labels"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tenantRecordingClient records the tenant in the gRPC metadata of outgoing requests.
+type tenantRecordingClient struct {
+	storeClient
+	tenants chan []string
+}
+
+func (c *tenantRecordingClient) record(ctx context.Context) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	c.tenants <- md.Get(tenancy.MetadataKey)
+}
+
+func (c *tenantRecordingClient) Series(ctx context.Context, req *storepb.SeriesRequest, opts ...grpc.CallOption) (storepb.Store_SeriesClient, error) {
+	c.record(ctx)
+	return c.storeClient.Series(ctx, req, opts...)
+}
+
+func (c *tenantRecordingClient) LabelNames(ctx context.Context, req *storepb.LabelNamesRequest, opts ...grpc.CallOption) (*storepb.LabelNamesResponse, error) {
+	c.record(ctx)
+	return c.storeClient.LabelNames(ctx, req, opts...)
+}
+
+func TestProxyStore_TenantPropagation(t *testing.T) {
+	cl := &tenantRecordingClient{tenants: make(chan []string, 1)}
+	q := NewProxyStore(nil, nil,
+		func(context.Context) ([]Client, error) {
+			return []Client{&testClient{StoreClient: cl, minTime: 0, maxTime: 300}}, nil
+		},
+		nil, 0, 0,
+	)
+	req := &storepb.SeriesRequest{
+		MinTime:  1,
+		MaxTime:  300,
+		Matchers: []storepb.LabelMatcher{{Name: "a", Value: "a", Type: storepb.LabelMatcher_EQ}},
+	}
+
+	// Tenant of gRPC requests is taken from the incoming metadata.
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenancy.MetadataKey, "team-a"))
+	testutil.Ok(t, q.Series(req, newStoreSeriesServer(ctx)))
+	testutil.Equals(t, []string{"team-a"}, <-cl.tenants)
+
+	// Tenant of in-process requests is taken from the context.
+	ctx = tenancy.ContextWithTenant(context.Background(), "team-b")
+	testutil.Ok(t, q.Series(req, newStoreSeriesServer(ctx)))
+	testutil.Equals(t, []string{"team-b"}, <-cl.tenants)
+
+	_, err := q.LabelNames(ctx, &storepb.LabelNamesRequest{})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"team-b"}, <-cl.tenants)
+
+	testutil.Ok(t, q.Series(req, newStoreSeriesServer(context.Background())))
+	testutil.Equals(t, []string(nil), <-cl.tenants)
+}
+
+func TestPrometheusStore_Tenancy(t *testing.T) {
+	// Requests of other tenants must be answered without reaching Prometheus.
+	u, err := url.Parse("http://127.0.0.1:1")
+	testutil.Ok(t, err)
+
+	p, err := NewPrometheusStore(nil, nil, u,
+		func() labels.Labels { return labels.FromStrings("region", "eu-west", "tenant", "team-a") },
+		nil,
+		"tenant",
+	)
+	testutil.Ok(t, err)
+
+	req := &storepb.SeriesRequest{
+		MinTime:  1,
+		MaxTime:  300,
+		Matchers: []storepb.LabelMatcher{{Name: "a", Value: "a", Type: storepb.LabelMatcher_EQ}},
+	}
+	srv := newStoreSeriesServer(tenancy.ContextWithTenant(context.Background(), "team-b"))
+	testutil.Ok(t, p.Series(req, srv))
+	testutil.Equals(t, 0, len(srv.SeriesSet))
+
+	names, err := p.LabelNames(tenancy.ContextWithTenant(context.Background(), "team-b"), &storepb.LabelNamesRequest{})
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(names.Names))
+
+	// Requests without tenant are rejected.
+	err = p.Series(req, newStoreSeriesServer(context.Background()))
+	testutil.NotOk(t, err)
+	testutil.Equals(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = p.LabelValues(context.Background(), &storepb.LabelValuesRequest{Label: "a"})
+	testutil.NotOk(t, err)
+	testutil.Equals(t, codes.PermissionDenied, status.Code(err))
+}
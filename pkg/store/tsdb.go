@@ -2,21 +2,28 @@ package store
 
 import (
 	"context"
+	"io"
 	"math"
 	"sort"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/improbable-eng/thanos/pkg/runutil"
 	"github.com/improbable-eng/thanos/pkg/store/storepb"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/tsdb"
 	"github.com/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/tsdb/chunks"
 	"github.com/prometheus/tsdb/labels"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// maxBlockReadersRetries is the maximum number of times blocks of a request are listed again, because
+// a listed block was closed before its readers were opened.
+const maxBlockReadersRetries = 3
+
 // TSDBStore implements the store API against a local TSDB instance.
 // It attaches the provided external labels to all results. It only responds with raw data
 // and does not support downsampling.
@@ -57,8 +64,8 @@ func (s *TSDBStore) Info(ctx context.Context, r *storepb.InfoRequest) (*storepb.
 	return res, nil
 }
 
-// Series returns all series for a requested time range and label matcher. The returned data may
-// exceed the requested time bounds.
+// Series returns all series for a requested time range and label matcher. Chunks of the TSDB are
+// passed through as they are, only chunks crossing the requested time range are cut.
 func (s *TSDBStore) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesServer) error {
 	match, newMatchers, err := labelsMatches(s.labels, r.Matchers)
 	if err != nil {
@@ -72,106 +79,361 @@ func (s *TSDBStore) Series(r *storepb.SeriesRequest, srv storepb.Store_SeriesSer
 		return status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	// TODO(fabxc): An improvement over this trivial approach would be to directly
-	// use the chunks provided by TSDB in the response.
-	// But since the sidecar has a similar approach, optimizing here has only
-	// limited benefit for now.
-	q, err := s.db.Querier(r.MinTime, r.MaxTime)
+	var (
+		sets     []storepb.SeriesSet
+		closeFns []func()
+	)
+	err = s.withBlockReaders(r.MinTime, r.MaxTime, func(readers []tsdb.BlockReader, h tsdb.BlockReader) error {
+		sets, closeFns = sets[:0], closeFns[:0]
+
+		for _, b := range readers {
+			set, closeFn, err := s.blockSeries(b, b == h, r.MinTime, r.MaxTime, r.SkipChunks, matchers)
+			if err != nil {
+				for _, fn := range closeFns {
+					fn()
+				}
+				return err
+			}
+			closeFns = append(closeFns, closeFn)
+			sets = append(sets, set)
+		}
+		return nil
+	})
 	if err != nil {
 		return status.Error(codes.Internal, err.Error())
 	}
-	defer runutil.CloseWithLogOnErr(s.logger, q, "close tsdb querier series")
+	defer func() {
+		for _, fn := range closeFns {
+			fn()
+		}
+	}()
 
-	set, err := q.Select(matchers...)
+	set := storepb.MergeSeriesSets(sets...)
+	for set.Next() {
+		lset, chks := set.At()
+		series := storepb.Series{
+			Labels: extendLset(s.withoutExternalLabels(lset), s.labels),
+			Chunks: chks,
+		}
+		if err := srv.Send(storepb.NewSeriesResponse(&series)); err != nil {
+			return status.Error(codes.Aborted, err.Error())
+		}
+	}
+	if set.Err() != nil {
+		return status.Error(codes.Internal, set.Err().Error())
+	}
+	return nil
+}
+
+// withBlockReaders calls f with all blocks overlapping the time range, including the head, which is passed
+// separately as well. Blocks are listed without holding the lock of the DB, so a block can be closed by a reload,
+// e.g. once it is compacted or deleted by retention, before f opens its readers. Blocks are listed again in that
+// case, which yields their replacements.
+func (s *TSDBStore) withBlockReaders(mint, maxt int64, f func(readers []tsdb.BlockReader, head tsdb.BlockReader) error) error {
+	for i := 0; ; i++ {
+		var readers []tsdb.BlockReader
+		for _, b := range s.db.Blocks() {
+			if m := b.Meta(); m.MinTime <= maxt && mint < m.MaxTime {
+				readers = append(readers, b)
+			}
+		}
+		h := s.db.Head()
+		if h.MinTime() <= maxt && mint <= h.MaxTime() {
+			readers = append(readers, h)
+		}
+
+		err := f(readers, h)
+		if errors.Cause(err) != tsdb.ErrClosing || i == maxBlockReadersRetries {
+			return err
+		}
+		level.Debug(s.logger).Log("msg", "block closed while opening its readers, listing blocks again", "err", err)
+	}
+}
+
+// blockSeries returns a series set of chunks of the given block matching the matchers. If skipChunks is true,
+// the series are returned without chunks. The returned function must be called once the series set is
+// not used anymore.
+func (s *TSDBStore) blockSeries(b tsdb.BlockReader, head bool, mint, maxt int64, skipChunks bool, matchers []labels.Matcher) (
+	storepb.SeriesSet, func(), error,
+) {
+	var closers []io.Closer
+	closeFn := func() {
+		for _, c := range closers {
+			runutil.CloseWithLogOnErr(s.logger, c, "close tsdb block reader series")
+		}
+	}
+
+	ir, err := b.Index()
 	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+		return nil, nil, errors.Wrap(err, "open index reader")
 	}
+	closers = append(closers, ir)
 
-	var respSeries storepb.Series
+	cr, err := b.Chunks()
+	if err != nil {
+		closeFn()
+		return nil, nil, errors.Wrap(err, "open chunk reader")
+	}
+	closers = append(closers, cr)
 
-	for set.Next() {
-		series := set.At()
+	tr, err := b.Tombstones()
+	if err != nil {
+		closeFn()
+		return nil, nil, errors.Wrap(err, "open tombstone reader")
+	}
+	closers = append(closers, tr)
 
-		c, err := s.encodeChunk(series.Iterator())
-		if err != nil {
-			return status.Errorf(codes.Internal, "encode chunk: %s", err)
+	set, err := tsdb.LookupChunkSeries(ir, tr, matchers...)
+	if err != nil {
+		closeFn()
+		return nil, nil, errors.Wrap(err, "lookup series")
+	}
+	return &tsdbSeriesSet{set: set, chunkr: cr, mint: mint, maxt: maxt, head: head, skipChunks: skipChunks}, closeFn, nil
+}
+
+// withoutExternalLabels returns the label set without labels overwritten by external labels.
+func (s *TSDBStore) withoutExternalLabels(lset []storepb.Label) []storepb.Label {
+	res := make([]storepb.Label, 0, len(lset)+len(s.labels))
+	for _, l := range lset {
+		if s.labels.Get(l.Name) != "" {
+			continue
 		}
+		res = append(res, l)
+	}
+	return res
+}
 
-		respSeries.Labels = s.translateAndExtendLabels(series.Labels(), s.labels)
-		respSeries.Chunks = append(respSeries.Chunks[:0], c)
+// tsdbSeriesSet is a storepb.SeriesSet of chunks of a single TSDB block or head. Its series are
+// ordered by their original labels, so sets of multiple blocks can be merged before external
+// labels are attached.
+type tsdbSeriesSet struct {
+	set        tsdb.ChunkSeriesSet
+	chunkr     tsdb.ChunkReader
+	mint, maxt int64
+	// head is true for the head block, whose last chunk of a series may still be appended to.
+	head bool
+	// skipChunks is true if only the label sets of series with data in the time range are needed.
+	skipChunks bool
 
-		if err := srv.Send(storepb.NewSeriesResponse(&respSeries)); err != nil {
-			return status.Error(codes.Aborted, err.Error())
+	lset []storepb.Label
+	chks []storepb.AggrChunk
+	err  error
+}
+
+func (s *tsdbSeriesSet) Next() bool {
+	for s.set.Next() {
+		lset, metas, dranges := s.set.At()
+
+		if s.skipChunks {
+			if !overlapsChunks(metas, s.mint, s.maxt) {
+				continue
+			}
+			s.chks = nil
+			s.setLabels(lset)
+			return true
 		}
+
+		// A new slice is needed as merged series sets keep the previous one.
+		s.chks = make([]storepb.AggrChunk, 0, len(metas))
+		for i, m := range metas {
+			if m.MaxTime < s.mint || m.MinTime > s.maxt {
+				continue
+			}
+			chk, err := s.chunkr.Chunk(m.Ref)
+			if err == tsdb.ErrNotFound {
+				// The chunk was garbage collected from the head in the meantime.
+				continue
+			}
+			if err != nil {
+				s.err = errors.Wrapf(err, "get chunk %d of series %s", m.Ref, lset)
+				return false
+			}
+
+			var c storepb.AggrChunk
+			// Chunks within the time range are passed as they are. Chunks crossing it, chunks with deleted
+			// samples and the open head chunk, which is being appended to, are re-encoded.
+			if chk.Encoding() == chunkenc.EncXOR && m.MinTime >= s.mint && m.MaxTime <= s.maxt &&
+				!(s.head && i == len(metas)-1) && !overlapsIntervals(m.MinTime, m.MaxTime, dranges) {
+				c = storepb.AggrChunk{
+					MinTime: m.MinTime,
+					MaxTime: m.MaxTime,
+					Raw:     &storepb.Chunk{Type: storepb.Chunk_XOR, Data: chk.Bytes()},
+				}
+			} else {
+				var ok bool
+				c, ok, err = cutChunk(chk.Iterator(), s.mint, s.maxt, dranges)
+				if err != nil {
+					s.err = errors.Wrapf(err, "encode chunk %d of series %s", m.Ref, lset)
+					return false
+				}
+				if !ok {
+					continue
+				}
+			}
+			s.chks = append(s.chks, c)
+		}
+		if len(s.chks) == 0 {
+			continue
+		}
+		s.setLabels(lset)
+		return true
 	}
-	return nil
+	return false
+}
+
+func (s *tsdbSeriesSet) setLabels(lset labels.Labels) {
+	s.lset = make([]storepb.Label, 0, len(lset))
+	for _, l := range lset {
+		s.lset = append(s.lset, storepb.Label{Name: l.Name, Value: l.Value})
+	}
+}
+
+func (s *tsdbSeriesSet) At() ([]storepb.Label, []storepb.AggrChunk) {
+	return s.lset, s.chks
+}
+
+func (s *tsdbSeriesSet) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.set.Err()
 }
 
-func (s *TSDBStore) encodeChunk(it tsdb.SeriesIterator) (storepb.AggrChunk, error) {
+// cutChunk encodes samples of the iterator within the time range that are not deleted into a new chunk.
+// It returns false if there are no such samples.
+func cutChunk(it chunkenc.Iterator, mint, maxt int64, dranges tsdb.Intervals) (storepb.AggrChunk, bool, error) {
 	chk := chunkenc.NewXORChunk()
 
 	app, err := chk.Appender()
 	if err != nil {
-		return storepb.AggrChunk{}, err
+		return storepb.AggrChunk{}, false, err
 	}
-	var mint int64
+	var cmint, cmaxt int64
 
-	for i := 0; it.Next(); i++ {
-		if i == 0 {
-			mint, _ = it.At()
+	n := 0
+	for it.Next() {
+		t, v := it.At()
+		if t < mint || overlapsIntervals(t, t, dranges) {
+			continue
+		}
+		if t > maxt {
+			break
+		}
+		if n == 0 {
+			cmint = t
 		}
-		app.Append(it.At())
+		cmaxt = t
+		app.Append(t, v)
+		n++
 	}
 	if it.Err() != nil {
-		return storepb.AggrChunk{}, errors.Wrap(it.Err(), "read series")
+		return storepb.AggrChunk{}, false, errors.Wrap(it.Err(), "read chunk")
+	}
+	if n == 0 {
+		return storepb.AggrChunk{}, false, nil
 	}
-	maxt, _ := it.At()
-
 	return storepb.AggrChunk{
-		MinTime: mint,
-		MaxTime: maxt,
+		MinTime: cmint,
+		MaxTime: cmaxt,
 		Raw:     &storepb.Chunk{Type: storepb.Chunk_XOR, Data: chk.Bytes()},
-	}, nil
+	}, true, nil
 }
 
-// translateAndExtendLabels transforms a metrics into a protobuf label set. It additionally
-// attaches the given labels to it, overwriting existing ones on collision.
-func (s *TSDBStore) translateAndExtendLabels(m, extend labels.Labels) []storepb.Label {
-	lset := make([]storepb.Label, 0, len(m)+len(extend))
-
-	for _, l := range m {
-		if extend.Get(l.Name) != "" {
-			continue
+// overlapsChunks returns true if the closed time range overlaps any of the chunks.
+func overlapsChunks(metas []chunks.Meta, mint, maxt int64) bool {
+	for _, m := range metas {
+		if m.MinTime <= maxt && mint <= m.MaxTime {
+			return true
 		}
-		lset = append(lset, storepb.Label{
-			Name:  l.Name,
-			Value: l.Value,
-		})
 	}
-	for _, l := range extend {
-		lset = append(lset, storepb.Label{
-			Name:  l.Name,
-			Value: l.Value,
-		})
+	return false
+}
+
+// overlapsIntervals returns true if the closed time range overlaps any of the intervals.
+func overlapsIntervals(mint, maxt int64, ivs tsdb.Intervals) bool {
+	for _, iv := range ivs {
+		if iv.Mint <= maxt && mint <= iv.Maxt {
+			return true
+		}
 	}
-	sort.Slice(lset, func(i, j int) bool {
-		return lset[i].Name < lset[j].Name
-	})
-	return lset
+	return false
 }
 
-// LabelNames returns all known label names.
+// LabelNames returns all known label names including the external ones.
 func (s *TSDBStore) LabelNames(ctx context.Context, r *storepb.LabelNamesRequest) (
 	*storepb.LabelNamesResponse, error,
 ) {
-	return nil, status.Error(codes.Unimplemented, "not implemented")
+	match, _, err := labelsMatches(s.labels, r.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !match {
+		return &storepb.LabelNamesResponse{}, nil
+	}
+
+	mint, maxt := labelsRequestTimeRange(r.TimeRange)
+
+	var uniq map[string]struct{}
+	err = s.withBlockReaders(mint, maxt, func(readers []tsdb.BlockReader, _ tsdb.BlockReader) error {
+		uniq = map[string]struct{}{}
+		for _, l := range s.labels {
+			uniq[l.Name] = struct{}{}
+		}
+		for _, b := range readers {
+			if err := s.addLabelNames(uniq, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	names := make([]string, 0, len(uniq))
+	for n := range uniq {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return &storepb.LabelNamesResponse{Names: names}, nil
+}
+
+// addLabelNames adds names of all label indices of the given block to the set.
+func (s *TSDBStore) addLabelNames(set map[string]struct{}, b tsdb.BlockReader) error {
+	ir, err := b.Index()
+	if err != nil {
+		return errors.Wrap(err, "open index reader")
+	}
+	defer runutil.CloseWithLogOnErr(s.logger, ir, "close tsdb index reader label names")
+
+	tpls, err := ir.LabelIndices()
+	if err != nil {
+		return errors.Wrap(err, "get label indices")
+	}
+	for _, tpl := range tpls {
+		for _, n := range tpl {
+			set[n] = struct{}{}
+		}
+	}
+	return nil
 }
 
 // LabelValues returns all known label values for a given label name.
 func (s *TSDBStore) LabelValues(ctx context.Context, r *storepb.LabelValuesRequest) (
 	*storepb.LabelValuesResponse, error,
 ) {
-	q, err := s.db.Querier(math.MinInt64, math.MaxInt64)
+	match, _, err := labelsMatches(s.labels, r.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !match {
+		return &storepb.LabelValuesResponse{}, nil
+	}
+
+	mint, maxt := labelsRequestTimeRange(r.TimeRange)
+
+	q, err := s.db.Querier(mint, maxt)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/improbable-eng/thanos/pkg/store/storepb"
+	"github.com/improbable-eng/thanos/pkg/testutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/tsdb"
+	"github.com/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/tsdb/labels"
+)
+
+// newTestTSDB returns a TSDB with a block of the given series in [0, 2h) and the same series
+// in the head within [2h, 3h).
+func newTestTSDB(t testing.TB, series []labels.Labels) (*tsdb.DB, func()) {
+	dir, err := ioutil.TempDir("", "test_tsdb_store")
+	testutil.Ok(t, err)
+
+	_, err = testutil.CreateBlock(dir, series, 480, 0, 2*3600*1000, labels.Labels{}, 0)
+	testutil.Ok(t, err)
+
+	db, err := tsdb.Open(dir, nil, nil, &tsdb.Options{
+		BlockRanges:       []int64{2 * 3600 * 1000},
+		RetentionDuration: math.MaxInt64,
+	})
+	testutil.Ok(t, err)
+
+	app := db.Appender()
+	for ts := int64(2 * 3600 * 1000); ts < 3*3600*1000; ts += 15000 {
+		for i, lset := range series {
+			_, err := app.Add(lset, ts, float64(ts)+float64(i))
+			testutil.Ok(t, err)
+		}
+	}
+	testutil.Ok(t, app.Commit())
+
+	return db, func() {
+		testutil.Ok(t, db.Close())
+		testutil.Ok(t, os.RemoveAll(dir))
+	}
+}
+
+// seriesSamples returns samples of all series of the response by their labels.
+func seriesSamples(t testing.TB, set []storepb.Series) map[string][]sample {
+	res := map[string][]sample{}
+	for _, s := range set {
+		var samples []sample
+		for _, c := range s.Chunks {
+			testutil.Equals(t, storepb.Chunk_XOR, c.Raw.Type)
+			chk, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+			testutil.Ok(t, err)
+			samples = append(samples, expandChunk(chk.Iterator())...)
+		}
+		res[fmt.Sprintf("%v", s.Labels)] = samples
+	}
+	return res
+}
+
+func TestTSDBStore_Series(t *testing.T) {
+	series := []labels.Labels{
+		labels.FromStrings("a", "1", "b", "1"),
+		labels.FromStrings("a", "1", "b", "2"),
+		labels.FromStrings("a", "2", "b", "1"),
+		labels.FromStrings("a", "2", "region", "us-east"),
+	}
+	db, cleanup := newTestTSDB(t, series)
+	defer cleanup()
+
+	testutil.Ok(t, db.Delete(3600*1000, 3600*1000+60*1000, labels.NewEqualMatcher("b", "2")))
+
+	tsdbStore := NewTSDBStore(nil, nil, db, labels.FromStrings("region", "eu-west"))
+
+	for _, tcase := range []struct {
+		mint, maxt int64
+		matchers   []storepb.LabelMatcher
+	}{
+		{mint: 0, maxt: math.MaxInt64},
+		{mint: 1800*1000 + 7, maxt: 2*3600*1000 + 1800*1000 + 3},
+		{mint: 2 * 3600 * 1000, maxt: 2 * 3600 * 1000},
+		{mint: 0, maxt: 3600 * 1000, matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "a", Value: "1"}}},
+		{mint: 0, maxt: math.MaxInt64, matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "region", Value: "us-east"}}},
+	} {
+		t.Run(fmt.Sprintf("%d-%d-%v", tcase.mint, tcase.maxt, tcase.matchers), func(t *testing.T) {
+			req := &storepb.SeriesRequest{MinTime: tcase.mint, MaxTime: tcase.maxt, Matchers: tcase.matchers}
+			if len(req.Matchers) == 0 {
+				req.Matchers = []storepb.LabelMatcher{{Type: storepb.LabelMatcher_RE, Name: "a", Value: ".+"}}
+			}
+
+			srv := newStoreSeriesServer(context.Background())
+			testutil.Ok(t, tsdbStore.Series(req, srv))
+
+			expSrv := newStoreSeriesServer(context.Background())
+			testutil.Ok(t, reencodedSeries(tsdbStore, req, expSrv))
+
+			testutil.Equals(t, len(expSrv.SeriesSet), len(srv.SeriesSet))
+			for i := range srv.SeriesSet {
+				testutil.Equals(t, expSrv.SeriesSet[i].Labels, srv.SeriesSet[i].Labels)
+			}
+			testutil.Equals(t, seriesSamples(t, expSrv.SeriesSet), seriesSamples(t, srv.SeriesSet))
+
+			for _, s := range srv.SeriesSet {
+				for _, c := range s.Chunks {
+					testutil.Assert(t, c.MinTime >= tcase.mint && c.MaxTime <= tcase.maxt,
+						"chunk [%d, %d] outside of requested range", c.MinTime, c.MaxTime)
+				}
+			}
+
+			// Skipping chunks must return the same label sets without any chunks.
+			req.SkipChunks = true
+			srv = newStoreSeriesServer(context.Background())
+			testutil.Ok(t, tsdbStore.Series(req, srv))
+
+			testutil.Equals(t, len(expSrv.SeriesSet), len(srv.SeriesSet))
+			for i := range srv.SeriesSet {
+				testutil.Equals(t, expSrv.SeriesSet[i].Labels, srv.SeriesSet[i].Labels)
+				testutil.Equals(t, 0, len(srv.SeriesSet[i].Chunks))
+			}
+		})
+	}
+}
+
+func TestTSDBStore_withBlockReaders(t *testing.T) {
+	db, cleanup := newTestTSDB(t, []labels.Labels{labels.FromStrings("a", "1")})
+	defer cleanup()
+
+	tsdbStore := NewTSDBStore(nil, nil, db, nil)
+
+	// Blocks are listed again if one of them was closed before its readers were opened.
+	calls := 0
+	testutil.Ok(t, tsdbStore.withBlockReaders(0, math.MaxInt64, func(readers []tsdb.BlockReader, h tsdb.BlockReader) error {
+		calls++
+		testutil.Equals(t, 2, len(readers))
+		testutil.Assert(t, readers[1] == h, "expected head to be the last reader")
+
+		if calls == 1 {
+			return errors.Wrap(tsdb.ErrClosing, "open index reader")
+		}
+		return nil
+	}))
+	testutil.Equals(t, 2, calls)
+
+	// Other errors are returned right away, closed blocks only after a few attempts.
+	calls = 0
+	testutil.NotOk(t, tsdbStore.withBlockReaders(0, math.MaxInt64, func([]tsdb.BlockReader, tsdb.BlockReader) error {
+		calls++
+		return errors.New("other")
+	}))
+	testutil.Equals(t, 1, calls)
+
+	calls = 0
+	testutil.NotOk(t, tsdbStore.withBlockReaders(0, math.MaxInt64, func([]tsdb.BlockReader, tsdb.BlockReader) error {
+		calls++
+		return tsdb.ErrClosing
+	}))
+	testutil.Equals(t, maxBlockReadersRetries+1, calls)
+}
+
+// reencodedSeries is the former implementation of TSDBStore.Series, which re-encodes all samples of a series
+// into a single chunk. It is kept as a reference for tests and benchmarks.
+func reencodedSeries(s *TSDBStore, r *storepb.SeriesRequest, srv storepb.Store_SeriesServer) error {
+	match, newMatchers, err := labelsMatches(s.labels, r.Matchers)
+	if err != nil || !match {
+		return err
+	}
+	matchers, err := translateMatchers(newMatchers)
+	if err != nil {
+		return err
+	}
+	q, err := s.db.Querier(r.MinTime, r.MaxTime)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = q.Close() }()
+
+	set, err := q.Select(matchers...)
+	if err != nil {
+		return err
+	}
+	for set.Next() {
+		series := set.At()
+
+		lset := make([]storepb.Label, 0, len(series.Labels()))
+		for _, l := range series.Labels() {
+			lset = append(lset, storepb.Label{Name: l.Name, Value: l.Value})
+		}
+		c, ok, err := cutChunk(series.Iterator(), math.MinInt64, math.MaxInt64, nil)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		resp := &storepb.Series{
+			Labels: extendLset(s.withoutExternalLabels(lset), s.labels),
+			Chunks: []storepb.AggrChunk{c},
+		}
+		if err := srv.Send(storepb.NewSeriesResponse(resp)); err != nil {
+			return err
+		}
+	}
+	return set.Err()
+}
+
+func BenchmarkTSDBStore_Series(b *testing.B) {
+	var series []labels.Labels
+	for i := 0; i < 500; i++ {
+		series = append(series, labels.FromStrings("a", fmt.Sprintf("%d", i%5), "i", fmt.Sprintf("%d", i)))
+	}
+	db, cleanup := newTestTSDB(b, series)
+	defer cleanup()
+
+	tsdbStore := NewTSDBStore(nil, nil, db, labels.FromStrings("region", "eu-west"))
+	req := &storepb.SeriesRequest{
+		MinTime:  1800 * 1000,
+		MaxTime:  math.MaxInt64,
+		Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_RE, Name: "a", Value: ".+"}},
+	}
+
+	b.Run("reencode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			testutil.Ok(b, reencodedSeries(tsdbStore, req, newStoreSeriesServer(context.Background())))
+		}
+	})
+	b.Run("chunks", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			testutil.Ok(b, tsdbStore.Series(req, newStoreSeriesServer(context.Background())))
+		}
+	})
+}
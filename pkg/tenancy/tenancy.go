@@ -0,0 +1,44 @@
+// Package tenancy propagates the tenant of a request from the HTTP APIs through the StoreAPI.
+// The tenant is carried in an HTTP header on the HTTP APIs and in gRPC metadata on the StoreAPI.
+package tenancy
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// DefaultTenantHeader is the default HTTP header carrying the tenant of a request.
+	DefaultTenantHeader = "THANOS-TENANT"
+	// MetadataKey is the gRPC metadata key carrying the tenant of StoreAPI requests.
+	MetadataKey = "thanos-tenant"
+)
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a context carrying the tenant for in-process StoreAPI requests and
+// for StoreAPI requests sent with the context.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	if t, ok := ctx.Value(tenantKey{}).(string); ok && t == tenant {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, tenantKey{}, tenant)
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, tenant)
+}
+
+// FromContext returns the tenant of the request. The tenant is taken from the context set by ContextWithTenant
+// or from the metadata of the incoming gRPC request.
+func FromContext(ctx context.Context) (string, bool) {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		return tenant, true
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	if v := md.Get(MetadataKey); len(v) > 0 {
+		return v[0], true
+	}
+	return "", false
+}